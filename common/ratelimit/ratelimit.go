@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶, rate为每秒补充的令牌数, burst为桶容量.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建令牌桶, 初始为满桶.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst <= 0 {
+		burst = int(rate)
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// SetRate 修改令牌补充速率及桶容量.
+func (b *TokenBucket) SetRate(rate float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+
+	if burst <= 0 {
+		burst = int(rate)
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	b.rate = rate
+	b.burst = float64(burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// GetBurst 桶容量.
+func (b *TokenBucket) GetBurst() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return int(b.burst)
+}
+
+// Allow 尝试取n个令牌, 不足时不扣除并返回false.
+func (b *TokenBucket) Allow(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+
+	if b.tokens < float64(n) {
+		return false
+	}
+
+	b.tokens -= float64(n)
+	return true
+}
+
+// Reserve 预取n个令牌, 返回需要等待的时长.
+// 等待时长超过maxWait时不扣除令牌并返回false.
+func (b *TokenBucket) Reserve(n int, maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+
+	lack := float64(n) - b.tokens
+	if lack <= 0 {
+		b.tokens -= float64(n)
+		return 0, true
+	}
+
+	if b.rate <= 0 {
+		return 0, false
+	}
+
+	wait := time.Duration(lack / b.rate * float64(time.Second))
+	if wait > maxWait {
+		return 0, false
+	}
+
+	b.tokens -= float64(n)
+	return wait, true
+}
+
+// Refund 归还已取出的n个令牌, 不超过桶容量.
+func (b *TokenBucket) Refund(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+
+	b.tokens += float64(n)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *TokenBucket) advance(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	b.last = now
+	b.tokens += elapsed.Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAllow(t *testing.T) {
+	b := NewTokenBucket(10, 5)
+
+	for i := 0; i < 5; i++ {
+		if !b.Allow(1) {
+			t.Errorf("fail: allow %d", i)
+		}
+	}
+
+	if b.Allow(1) {
+		t.Errorf("fail: bucket should be empty")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if !b.Allow(1) {
+		t.Errorf("fail: bucket should refill")
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	b := NewTokenBucket(100, 1)
+
+	if wait, ok := b.Reserve(1, 0); !ok || wait != 0 {
+		t.Errorf("fail: first reserve")
+	}
+
+	wait, ok := b.Reserve(1, time.Second)
+	if !ok || wait <= 0 || wait > 20*time.Millisecond {
+		t.Errorf("fail: reserve wait %v", wait)
+	}
+
+	if _, ok := b.Reserve(100, time.Millisecond); ok {
+		t.Errorf("fail: reserve should exceed max wait")
+	}
+}
+
+func TestTokenBucketRefund(t *testing.T) {
+	b := NewTokenBucket(0.001, 2)
+
+	if !b.Allow(2) {
+		t.Fatalf("fail: allow full bucket")
+	}
+
+	b.Refund(5)
+	if !b.Allow(2) || b.Allow(1) {
+		t.Errorf("fail: refund should be capped by burst")
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/nearmeng/mango-go/proto/csproto"
 	"github.com/nearmeng/mango-go/server_base/app"
 	msgHandler "github.com/nearmeng/mango-go/server_base/msg"
-	"google.golang.org/protobuf/proto"
 
 	pb "github.com/nearmeng/mango-go/example/statelesssvr/proto/echo"
 )
@@ -24,7 +23,7 @@ func (m *TestModule) Init() error {
 	r := plugin.GetPluginInst("rpc", "trpc").(*trpc.TrpcServer)
 	pb.RegisterEchoService(r.GetServer(), &echoServiceImpl{})
 
-	msgHandler.RegisterClientMsgHandler(int32(csproto.CSMessageID_cs_login), m.OnLogin)
+	msgHandler.RegisterTypedHandler(m.OnLogin)
 	msgHandler.RegisterConnMsgHandler(msgHandler.CONN_EVENT_START, m.OnStart)
 	msgHandler.RegisterConnMsgHandler(msgHandler.CONN_EVENT_STOP, m.OnStop)
 
@@ -54,22 +53,13 @@ func (m *TestModule) OnStop(conn transport.Conn) {
 	log.Info("conn %d is stop active %d, remote %s", conn.GetConnID(), conn.GetRemoteAddr().String())
 }
 
-func (m *TestModule) OnLogin(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+func (m *TestModule) OnLogin(conn transport.Conn, header *csproto.CSHead, req *csproto.CS_LOGIN) *csproto.SC_LOGIN {
 	log.Info("header msg_id %d seq_id %d", header.Msgid, header.Seqid)
+	log.Info("login msg is %s", req.Name)
 
-	loginMsg := msg.(*csproto.CS_LOGIN)
-	log.Info("login msg is %s", loginMsg.Name)
-
-	rspHeader := &csproto.SCHead{
-		Msgid: header.Msgid,
-		Seqid: header.Seqid + 1,
-	}
-
-	rsp := &csproto.SC_LOGIN{
+	return &csproto.SC_LOGIN{
 		Success: 1,
 	}
-
-	msgHandler.SendToClient(conn, rspHeader, rsp)
 }
 
 func (m *TestModule) GetName() string {
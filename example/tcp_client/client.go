@@ -3,9 +3,13 @@ package main
 
 import (
 	"encoding/binary"
+	"encoding/hex"
+	"flag"
 	"fmt"
+	"io"
 	"net"
 
+	"github.com/nearmeng/mango-go/plugin/transport/secure"
 	"github.com/nearmeng/mango-go/proto/csproto"
 	"google.golang.org/protobuf/proto"
 )
@@ -96,6 +100,21 @@ func main() {
 
 	*/
 
+	secureFlag := flag.Bool("secure", false, "use secure channel")
+	serverKey := flag.String("server_key", "", "server static public key in hex")
+	genKey := flag.Bool("genkey", false, "generate server static key and exit")
+	flag.Parse()
+
+	if *genKey {
+		key, err := secure.GenerateStaticKey()
+		if err != nil {
+			fmt.Printf("generate key failed for %v\n", err)
+			return
+		}
+		fmt.Printf("static_key: %s\nserver_key: %s\n", hex.EncodeToString(key.PrivateKey()), hex.EncodeToString(key.PublicKey()))
+		return
+	}
+
 	conn, err := net.Dial("tcp", "127.0.0.1:8888")
 	if err != nil {
 		fmt.Printf("connect server failed")
@@ -104,6 +123,15 @@ func main() {
 
 	defer conn.Close()
 
+	var ch *secure.Channel
+	if *secureFlag {
+		ch, err = handshake(conn, *serverKey)
+		if err != nil {
+			fmt.Printf("handshake failed for %v\n", err)
+			return
+		}
+	}
+
 	header := &csproto.CSHead{
 		Msgid: int32(csproto.CSMessageID_cs_login),
 		Seqid: 1,
@@ -132,42 +160,132 @@ func main() {
 		return
 	}
 
-	sendBuff := make([]byte, 8+len(sendHeaderData)+len(sendData))
+	appData := make([]byte, 4+len(sendHeaderData)+len(sendData))
 	sendHeaderSize := len(sendHeaderData)
 	sendBodySize := len(sendData)
 
-	binary.LittleEndian.PutUint32(sendBuff[0:4], uint32(sendHeaderSize))
-	binary.LittleEndian.PutUint32(sendBuff[4:8], uint32(sendBodySize))
-	//_ = append(sendBuff[8:], sendData...)
-	copy(sendBuff[8:], sendHeaderData)
-	copy(sendBuff[8+sendHeaderSize:], sendData)
+	binary.LittleEndian.PutUint32(appData[0:4], uint32(sendHeaderSize))
+	copy(appData[4:], sendHeaderData)
+	copy(appData[4+sendHeaderSize:], sendData)
 
 	fmt.Printf("send header_size %d body_size %d\n", sendHeaderSize, sendBodySize)
 
-	conn.Write(sendBuff)
+	if ch != nil {
+		record, err := ch.Seal(appData)
+		if err != nil {
+			fmt.Printf("seal failed for %v\n", err)
+			return
+		}
+		appData = secure.WrapRecord(record)
+	}
+
+	err = writeFrame(conn, appData)
+	if err != nil {
+		fmt.Printf("write failed for %v\n", err)
+		return
+	}
+
+	recvData, err := readFrame(conn)
+	if err != nil {
+		fmt.Printf("read failed for %v\n", err)
+		return
+	}
 
-	recvBuff := [512]byte{}
-	n, _ := conn.Read(recvBuff[:])
+	if ch != nil {
+		record, err := secure.UnwrapRecord(recvData)
+		if err != nil {
+			fmt.Printf("unwrap failed for %v\n", err)
+			return
+		}
 
-	recvHeaderSize := binary.LittleEndian.Uint32(recvBuff[0:4])
-	recvBodySize := binary.LittleEndian.Uint32(recvBuff[4:8])
+		recvData, err = ch.Open(record)
+		if err != nil {
+			fmt.Printf("open failed for %v\n", err)
+			return
+		}
+	}
+
+	recvHeaderSize := binary.LittleEndian.Uint32(recvData[0:4])
+	recvBodySize := len(recvData) - 4 - int(recvHeaderSize)
 
 	recvHeader := &csproto.SCHead{}
-	err = proto.Unmarshal(recvBuff[8:8+recvHeaderSize], recvHeader)
+	err = proto.Unmarshal(recvData[4:4+recvHeaderSize], recvHeader)
 	if err != nil {
 		fmt.Printf("unmarshal failed")
 		return
 	}
 
 	recvMsg := &csproto.SC_LOGIN{}
-	err = proto.Unmarshal(recvBuff[8+recvHeaderSize:8+recvHeaderSize+recvBodySize], recvMsg)
+	err = proto.Unmarshal(recvData[4+recvHeaderSize:], recvMsg)
 	if err != nil {
 		fmt.Printf("unmarshal failed")
 		return
 	}
 
-	fmt.Printf("recv data from server header_size %d body_size %d read_len %d\n", recvHeaderSize, recvBodySize, n)
+	fmt.Printf("recv data from server header_size %d body_size %d\n", recvHeaderSize, recvBodySize)
 	fmt.Printf("header msgid %d seqid %d\n", recvHeader.Msgid, recvHeader.Seqid)
 	fmt.Printf("msg sc login success %d\n", recvMsg.Success)
 
 }
+
+// writeFrame 将app data(|headerSize|header|body|)加上8字节的帧头后发送.
+func writeFrame(conn net.Conn, appData []byte) error {
+	headerSize := binary.LittleEndian.Uint32(appData[0:4])
+
+	frame := make([]byte, 4+len(appData))
+	binary.LittleEndian.PutUint32(frame[0:4], headerSize)
+	binary.LittleEndian.PutUint32(frame[4:8], uint32(len(appData)-4-int(headerSize)))
+	copy(frame[8:], appData[4:])
+
+	_, err := conn.Write(frame)
+	return err
+}
+
+// readFrame 读取一帧并返回app data.
+func readFrame(conn net.Conn) ([]byte, error) {
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return nil, err
+	}
+
+	headerSize := binary.LittleEndian.Uint32(head[0:4])
+	bodySize := binary.LittleEndian.Uint32(head[4:8])
+
+	appData := make([]byte, 4+headerSize+bodySize)
+	binary.LittleEndian.PutUint32(appData[0:4], headerSize)
+
+	if _, err := io.ReadFull(conn, appData[4:]); err != nil {
+		return nil, err
+	}
+
+	return appData, nil
+}
+
+func handshake(conn net.Conn, serverKey string) (*secure.Channel, error) {
+	serverPub, err := hex.DecodeString(serverKey)
+	if err != nil {
+		return nil, err
+	}
+
+	hs, hello, err := secure.NewClientHandshake(serverPub)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeFrame(conn, secure.WrapRecord(hello))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := secure.UnwrapRecord(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return hs.Finish(record)
+}
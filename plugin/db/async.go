@@ -0,0 +1,228 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin"
+	"github.com/nearmeng/mango-go/plugin/log"
+	"go.uber.org/atomic"
+)
+
+const (
+	_defaultAsyncWorkers   = 8
+	_defaultAsyncQueueSize = 10000
+	_defaultAsyncTimeoutMs = 5000
+)
+
+var (
+	ErrAsyncQueueFull = errors.New("db: async queue full")
+	ErrAsyncTimeout   = errors.New("db: async request timeout")
+	ErrAsyncClosed    = errors.New("db: async executor closed")
+)
+
+// AsyncCfg 异步请求配置, 为0时使用默认值.
+type AsyncCfg struct {
+	// 工作goroutine数量
+	Workers int `mapstructure:"workers"`
+	// 已提交但回调还没有执行的请求数上限, 超过时提交失败
+	QueueSize int `mapstructure:"queuesize"`
+	// 请求超时时间, 包括排队时间
+	TimeoutMs int `mapstructure:"timeoutms"`
+}
+
+// AsyncTask 在工作goroutine上执行的数据库操作, ctx带有请求的超时时间.
+type AsyncTask func(ctx context.Context) ([]Record, error)
+
+type asyncRequest struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	task    AsyncTask
+	ret     AsyncResult
+	records []Record
+	err     error
+}
+
+// AsyncExecutor 异步请求执行器, 请求在有限的工作goroutine上执行, 结果由OnTick在逻辑goroutine上回调.
+// key相同的请求在同一个工作goroutine上按提交顺序执行.
+type AsyncExecutor struct {
+	ctx     context.Context
+	timeout time.Duration
+	workers []chan *asyncRequest
+	wg      sync.WaitGroup
+
+	maxPending int64
+	pending    atomic.Int64
+	next       atomic.Uint32
+
+	closeMutex sync.RWMutex
+	closed     bool
+
+	mutex   sync.Mutex
+	results []*asyncRequest
+}
+
+// NewAsyncExecutor 创建执行器并启动工作goroutine, ctx为所有请求的父context.
+func NewAsyncExecutor(ctx context.Context, cfg *AsyncCfg) *AsyncExecutor {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = _defaultAsyncWorkers
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = _defaultAsyncQueueSize
+	}
+
+	timeoutMs := cfg.TimeoutMs
+	if timeoutMs <= 0 {
+		timeoutMs = _defaultAsyncTimeoutMs
+	}
+
+	e := &AsyncExecutor{
+		ctx:        ctx,
+		timeout:    time.Duration(timeoutMs) * time.Millisecond,
+		workers:    make([]chan *asyncRequest, workers),
+		maxPending: int64(queueSize),
+	}
+
+	// 每个队列的容量都等于请求数上限, 提交时不会阻塞
+	for i := range e.workers {
+		e.workers[i] = make(chan *asyncRequest, queueSize)
+
+		e.wg.Add(1)
+		go e.work(e.workers[i])
+	}
+
+	return e
+}
+
+// Submit 提交请求, 失败时返回错误且不会回调. key为空时不保证顺序.
+func (e *AsyncExecutor) Submit(key string, ret AsyncResult, task AsyncTask) error {
+	if ret == nil {
+		return errors.New("db: async result callback nil")
+	}
+
+	e.closeMutex.RLock()
+	defer e.closeMutex.RUnlock()
+
+	if e.closed {
+		return ErrAsyncClosed
+	}
+
+	if e.pending.Inc() > e.maxPending {
+		e.pending.Dec()
+		return fmt.Errorf("%w: pending %d", ErrAsyncQueueFull, e.maxPending)
+	}
+
+	r := &asyncRequest{task: task, ret: ret}
+	r.ctx, r.cancel = context.WithTimeout(e.ctx, e.timeout)
+
+	e.workers[e.shard(key)] <- r
+
+	return nil
+}
+
+// OnTick 在逻辑goroutine上执行已完成请求的回调, 返回执行的回调数.
+func (e *AsyncExecutor) OnTick() int {
+	e.mutex.Lock()
+	results := e.results
+	e.results = nil
+	e.mutex.Unlock()
+
+	for _, r := range results {
+		e.pending.Dec()
+		callback(r)
+	}
+
+	return len(results)
+}
+
+// GetPending 已提交但回调还没有执行的请求数.
+func (e *AsyncExecutor) GetPending() int64 {
+	return e.pending.Load()
+}
+
+// Close 停止接收请求, 等待执行中的请求完成, 还在排队的请求以ErrAsyncClosed结束, 回调需要再调用一次OnTick.
+func (e *AsyncExecutor) Close() {
+	e.closeMutex.Lock()
+	if e.closed {
+		e.closeMutex.Unlock()
+		return
+	}
+	e.closed = true
+	e.closeMutex.Unlock()
+
+	for _, ch := range e.workers {
+		close(ch)
+	}
+	e.wg.Wait()
+}
+
+func (e *AsyncExecutor) shard(key string) int {
+	if key == "" {
+		return int(e.next.Inc() % uint32(len(e.workers)))
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(e.workers)))
+}
+
+func (e *AsyncExecutor) work(ch chan *asyncRequest) {
+	defer e.wg.Done()
+
+	for r := range ch {
+		e.closeMutex.RLock()
+		closed := e.closed
+		e.closeMutex.RUnlock()
+
+		if closed {
+			r.err = ErrAsyncClosed
+		} else {
+			execute(r)
+		}
+		r.cancel()
+
+		e.mutex.Lock()
+		e.results = append(e.results, r)
+		e.mutex.Unlock()
+
+		plugin.NotifyTick()
+	}
+}
+
+// execute 排队已经超时的请求不再执行.
+func execute(r *asyncRequest) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.err = fmt.Errorf("db: async task panic: %v", v)
+			log.Error("db async task panic: %v\n%s", v, debug.Stack())
+		}
+	}()
+
+	if err := r.ctx.Err(); err != nil {
+		r.err = fmt.Errorf("%w: %v", ErrAsyncTimeout, err)
+		return
+	}
+
+	r.records, r.err = r.task(r.ctx)
+	if r.err != nil && errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+		r.err = fmt.Errorf("%w: %v", ErrAsyncTimeout, r.err)
+	}
+}
+
+func callback(r *asyncRequest) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Error("db async callback panic: %v\n%s", v, debug.Stack())
+		}
+	}()
+
+	r.ret(r.records, r.err)
+}
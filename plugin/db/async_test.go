@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitTick(e *AsyncExecutor, n int) int {
+	got := 0
+	deadline := time.Now().Add(2 * time.Second)
+	for got < n && time.Now().Before(deadline) {
+		got += e.OnTick()
+		time.Sleep(time.Millisecond)
+	}
+	return got
+}
+
+func TestAsyncExecutorOrder(t *testing.T) {
+	e := NewAsyncExecutor(context.Background(), &AsyncCfg{Workers: 4, QueueSize: 100})
+	defer e.Close()
+
+	var executed, called []int
+	for i := 0; i < 50; i++ {
+		i := i
+		err := e.Submit("player:1", func(records []Record, err error) {
+			called = append(called, i)
+		}, func(ctx context.Context) ([]Record, error) {
+			executed = append(executed, i)
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+
+	if n := waitTick(e, 50); n != 50 || e.GetPending() != 0 {
+		t.Fatalf("fail: callback %d pending %d", n, e.GetPending())
+	}
+
+	for i := 0; i < 50; i++ {
+		if executed[i] != i || called[i] != i {
+			t.Fatalf("fail: order executed %v called %v", executed, called)
+		}
+	}
+}
+
+func TestAsyncExecutorLimit(t *testing.T) {
+	e := NewAsyncExecutor(context.Background(), &AsyncCfg{Workers: 1, QueueSize: 2, TimeoutMs: 50})
+
+	block := make(chan struct{})
+	var errs []error
+	ret := func(records []Record, err error) { errs = append(errs, err) }
+
+	_ = e.Submit("k", ret, func(ctx context.Context) ([]Record, error) {
+		<-block
+		return nil, nil
+	})
+	_ = e.Submit("k", ret, func(ctx context.Context) ([]Record, error) {
+		return nil, nil
+	})
+
+	if err := e.Submit("k", ret, nil); !errors.Is(err, ErrAsyncQueueFull) {
+		t.Errorf("fail: expect queue full, err %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(block)
+
+	if n := waitTick(e, 2); n != 2 || errs[0] != nil || !errors.Is(errs[1], ErrAsyncTimeout) {
+		t.Errorf("fail: callback %d errs %v", n, errs)
+	}
+
+	e.Close()
+	if err := e.Submit("k", ret, nil); !errors.Is(err, ErrAsyncClosed) {
+		t.Errorf("fail: expect closed, err %v", err)
+	}
+}
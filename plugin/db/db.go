@@ -98,7 +98,7 @@ type IDBAsyncExecutor interface {
 	//  @return err
 	AsyncGet(ret AsyncResult, record Record, fields []string) (err error)
 
-	// AsyncBatchGet 批量获取数据.
+	// AsyncBatchGet 批量获取数据, 只与第一个record的key相同的请求保持提交顺序.
 	//  @param model 需要传入slice，获取的数据也自动赋值上去
 	//  @return error
 	AsyncBatchGet(ret AsyncResult, record []Record) error
@@ -1,11 +1,22 @@
 package mysql // nolint
 
-import "github.com/nearmeng/mango-go/plugin/db"
+import (
+	"context"
 
-// TODO: 代码暂时不需要实现功能。
+	"github.com/nearmeng/mango-go/plugin/db"
+	"github.com/nearmeng/mango-go/plugin/db/pbsupport"
+)
+
+// 异步接口在工作goroutine上执行, 回调前不能再读写传入的record, 回调时records为传入的record.
+// key相同的record按提交顺序执行, 批量接口按第一个record的key排序.
 
 // OnTick 执行异步读取数据库回包的调用.
 func (t *DB) OnTick() {
+	t.async.OnTick()
+}
+
+func recordKey(record Record) string {
+	return pbsupport.BuildPrimaryKeyString(record)
 }
 
 // AsyncGet 获取数据，支持指定fields.
@@ -13,14 +24,29 @@ func (t *DB) OnTick() {
 //  @param fields 如果为nil，表示全量拉取
 //  @return err
 func (t *DB) AsyncGet(ret db.AsyncResult, record Record, fields []string) error {
-	return nil
+	return t.async.Submit(recordKey(record), ret, func(ctx context.Context) ([]Record, error) {
+		return []Record{record}, t.get(ctx, record, fields)
+	})
 }
 
-// AsyncBatchGet 批量获取数据
+// AsyncBatchGet 批量获取数据, 只与第一个record的key相同的请求保持提交顺序,
+// 其他record可能先于或晚于同key的单条写请求执行, 需要读到写入结果时应在写请求回调后再提交.
 //  @param model 需要传入slice []proto.Message
 //  @return error
 func (t *DB) AsyncBatchGet(ret db.AsyncResult, record []Record) error {
-	return nil
+	key := ""
+	if len(record) > 0 {
+		key = recordKey(record[0])
+	}
+
+	return t.async.Submit(key, ret, func(ctx context.Context) ([]Record, error) {
+		for _, r := range record {
+			if err := t.get(ctx, r, nil); err != nil {
+				return record, err
+			}
+		}
+		return record, nil
+	})
 }
 
 // AsyncUpdate 更新数据，如果不存在会失败，支持指定fields.
@@ -28,21 +54,27 @@ func (t *DB) AsyncBatchGet(ret db.AsyncResult, record []Record) error {
 //  @param fields 如果为nil，表示全量更新
 //  @return err
 func (t *DB) AsyncUpdate(ret db.AsyncResult, record Record, fields []string) error {
-	return nil
+	return t.async.Submit(recordKey(record), ret, func(ctx context.Context) ([]Record, error) {
+		return []Record{record}, t.update(ctx, record, fields)
+	})
 }
 
 // AsyncInsert 插入新数据，如果已存在会失败.
 //  @param model
 //  @return error
 func (t *DB) AsyncInsert(ret db.AsyncResult, record Record) error {
-	return nil
+	return t.async.Submit(recordKey(record), ret, func(ctx context.Context) ([]Record, error) {
+		return []Record{record}, t.insert(ctx, record)
+	})
 }
 
 // AsyncReplace 更新数据（如果没有就创建）.
 //  @param model 传入的数据模型
 //  @return err
 func (t *DB) AsyncReplace(ret db.AsyncResult, record Record) error {
-	return nil
+	return t.async.Submit(recordKey(record), ret, func(ctx context.Context) ([]Record, error) {
+		return []Record{record}, t.replace(ctx, record)
+	})
 }
 
 // AsyncDelete 删除指定key的数据.
@@ -51,7 +83,9 @@ func (t *DB) AsyncReplace(ret db.AsyncResult, record Record) error {
 //  @param resultFlag 指定0表示不需要返回数据，3表示从model传出删除的数据
 //  @return error
 func (t *DB) AsyncDelete(ret db.AsyncResult, record Record, resultFlag int) error {
-	return nil
+	return t.async.Submit(recordKey(record), ret, func(ctx context.Context) ([]Record, error) {
+		return []Record{record}, t.del(ctx, record, resultFlag)
+	})
 }
 
 // AsyncIncrease 自增指定整形字段.
@@ -59,5 +93,7 @@ func (t *DB) AsyncDelete(ret db.AsyncResult, record Record, resultFlag int) erro
 //  @param fields 指定字段集合，需要在model中有赋值
 //  @return err
 func (t *DB) AsyncIncrease(ret db.AsyncResult, record Record, fields []string) error {
-	return nil
+	return t.async.Submit(recordKey(record), ret, func(ctx context.Context) ([]Record, error) {
+		return []Record{record}, t.increase(ctx, record, fields)
+	})
 }
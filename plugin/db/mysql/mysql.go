@@ -54,7 +54,11 @@ func (f *factory) Setup(v *viper.Viper) (interface{}, error) {
 }
 
 // Destory tcaplus插件Destory方法.
-func (f *factory) Destroy(interface{}) error {
+func (f *factory) Destroy(ins interface{}) error {
+	// 只关闭执行器, 剩余的回调由app在逻辑goroutine上调用plugin.Tick执行
+	if d, ok := ins.(*DB); ok {
+		d.async.Close()
+	}
 	return nil
 }
 
@@ -72,6 +76,8 @@ type dbCfg struct {
 	DataSource  string `mapstructure:"datasource"`
 	IdleConns   int    `mapstructure:"idleconns"`
 	MaxLifeTime uint32 `mapstructure:"maxlifetime"`
+	// 异步接口的工作goroutine和队列配置
+	Async db.AsyncCfg `mapstructure:"async"`
 }
 
 // DB 实现IDatabase接口.
@@ -79,11 +85,13 @@ type DB struct {
 	sql    *sql.DB
 	ctx    context.Context
 	cancel context.CancelFunc
+	async  *db.AsyncExecutor
 }
 
 // 类型断言.
 var (
 	_ db.IDBSimpleExecutor = (*DB)(nil)
+	_ db.IDBAsyncExecutor  = (*DB)(nil)
 )
 
 // DBOpen 传入参数，初始化一个tcaplusDB.
@@ -118,6 +126,7 @@ func (t *DB) Open(config interface{}) error {
 	}
 	t.sql = dbsql
 	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.async = db.NewAsyncExecutor(t.ctx, &cfg.Async)
 	return nil
 }
 
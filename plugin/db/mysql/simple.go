@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -17,13 +18,17 @@ type Record = proto.Message
 //  @param fields 如果为nil，表示全量拉取
 //  @return err
 func (t *DB) SimpleGet(record Record, fields []string) error {
+	return t.get(t.ctx, record, fields)
+}
+
+func (t *DB) get(ctx context.Context, record Record, fields []string) error {
 	rf := record.ProtoReflect()
 	meta := GetDBProtoMeta(rf.Descriptor())
 	if meta == nil {
 		return fmt.Errorf("get meta nil fullname=%s", rf.Descriptor().FullName())
 	}
 	sqlPkg := meta.SelectFieldsPkg(rf, fields)
-	rows, err := t.sql.QueryContext(t.ctx, sqlPkg.str, sqlPkg.params...)
+	rows, err := t.sql.QueryContext(ctx, sqlPkg.str, sqlPkg.params...)
 	if err != nil {
 		return err
 	}
@@ -69,6 +74,10 @@ func (t *DB) SimpleBatchGet(record []Record) error {
 //  @param fields 如果为nil，表示全量更新
 //  @return err
 func (t *DB) SimpleUpdate(record Record, fields []string) error {
+	return t.update(t.ctx, record, fields)
+}
+
+func (t *DB) update(ctx context.Context, record Record, fields []string) error {
 	rf := record.ProtoReflect()
 	m, err := pbsupport.MarshalToMap(record, fields)
 	if err != nil {
@@ -82,7 +91,7 @@ func (t *DB) SimpleUpdate(record Record, fields []string) error {
 	if sqlPkg == nil {
 		return fmt.Errorf("build sql fullname:%s", rf.Descriptor().FullName())
 	}
-	r, e := t.sql.ExecContext(t.ctx, sqlPkg.str, sqlPkg.params...)
+	r, e := t.sql.ExecContext(ctx, sqlPkg.str, sqlPkg.params...)
 	if e != nil {
 		return fmt.Errorf("mysql exec err:%w", e)
 	}
@@ -100,6 +109,10 @@ func (t *DB) SimpleUpdate(record Record, fields []string) error {
 //  @param model
 //  @return error
 func (t *DB) SimpleInsert(record Record) error {
+	return t.insert(t.ctx, record)
+}
+
+func (t *DB) insert(ctx context.Context, record Record) error {
 	rf := record.ProtoReflect()
 	m, err := pbsupport.MarshalToMap(record, nil)
 	if err != nil {
@@ -113,7 +126,7 @@ func (t *DB) SimpleInsert(record Record) error {
 	if sqlPkg == nil {
 		return fmt.Errorf("build sql fullname:%s", rf.Descriptor().FullName())
 	}
-	_, e := t.sql.ExecContext(t.ctx, sqlPkg.str, sqlPkg.params...)
+	_, e := t.sql.ExecContext(ctx, sqlPkg.str, sqlPkg.params...)
 	if e != nil {
 		return fmt.Errorf("mysql exec err:%w", e)
 	}
@@ -124,6 +137,10 @@ func (t *DB) SimpleInsert(record Record) error {
 //  @param model 传入的数据模型
 //  @return err
 func (t *DB) SimpleReplace(record Record) error {
+	return t.replace(t.ctx, record)
+}
+
+func (t *DB) replace(ctx context.Context, record Record) error {
 	rf := record.ProtoReflect()
 	m, err := pbsupport.MarshalToMap(record, nil)
 	if err != nil {
@@ -134,7 +151,7 @@ func (t *DB) SimpleReplace(record Record) error {
 		return fmt.Errorf("get meta nil fullname:%s", rf.Descriptor().FullName())
 	}
 	sqlPkg := meta.ReplacePkg(m)
-	_, e := t.sql.ExecContext(t.ctx, sqlPkg.str, sqlPkg.params...)
+	_, e := t.sql.ExecContext(ctx, sqlPkg.str, sqlPkg.params...)
 	if e != nil {
 		return fmt.Errorf("mysql exec err:%w", e)
 	}
@@ -146,15 +163,19 @@ func (t *DB) SimpleReplace(record Record) error {
 //  @param resultFlag 指定0表示不需要返回数据，3表示从model传出删除的数据
 //  @return error
 func (t *DB) SimpleDelete(record Record, resultFlag int) error {
+	return t.del(t.ctx, record, resultFlag)
+}
+
+func (t *DB) del(ctx context.Context, record Record, resultFlag int) error {
 	// nolint
 	if resultFlag == 3 {
-		t.SimpleGet(record, nil)
+		t.get(ctx, record, nil)
 	}
 	rf := record.ProtoReflect()
 	meta := GetDBProtoMeta(rf.Descriptor())
 	sqlPkg := meta.DeleteSQLPkg(rf)
 
-	_, e := t.sql.ExecContext(t.ctx, sqlPkg.str, sqlPkg.params...)
+	_, e := t.sql.ExecContext(ctx, sqlPkg.str, sqlPkg.params...)
 	if e != nil {
 		return fmt.Errorf("mysql exec err:%w", e)
 	}
@@ -166,6 +187,10 @@ func (t *DB) SimpleDelete(record Record, resultFlag int) error {
 //  @param fields 指定字段集合，需要在model中有赋值
 //  @return err
 func (t *DB) SimpleIncrease(record Record, fields []string) error {
+	return t.increase(t.ctx, record, fields)
+}
+
+func (t *DB) increase(ctx context.Context, record Record, fields []string) error {
 	rf := record.ProtoReflect()
 	meta := GetDBProtoMeta(rf.Descriptor())
 	if meta == nil {
@@ -175,7 +200,7 @@ func (t *DB) SimpleIncrease(record Record, fields []string) error {
 	if sqlPkg == nil {
 		return fmt.Errorf("increase sql build fullname:%s", rf.Descriptor().FullName())
 	}
-	_, e := t.sql.ExecContext(t.ctx, sqlPkg.str, sqlPkg.params...)
+	_, e := t.sql.ExecContext(ctx, sqlPkg.str, sqlPkg.params...)
 	if e != nil {
 		return fmt.Errorf("mysql exec err:%w", e)
 	}
@@ -360,3 +360,19 @@ func BuildIncreaseableFieldsMap(desc protoreflect.MessageDescriptor) map[string]
 	}
 	return ret
 }
+
+// BuildPrimaryKeyString build string of message name and primary key values, like TBAcntInfo:{1123-xxx}.
+func BuildPrimaryKeyString(msg proto.Message) string {
+	rf := msg.ProtoReflect()
+	desc := rf.Descriptor()
+	fds := FindFds(desc, FindPrimaryKey(desc))
+
+	values := make([]string, 0, len(fds))
+	for _, fd := range fds {
+		if fd == nil {
+			continue
+		}
+		values = append(values, marshalScalar(fd, rf.Get(fd)))
+	}
+	return fmt.Sprintf("%s:{%s}", desc.Name(), strings.Join(values, "-"))
+}
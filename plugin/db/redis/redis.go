@@ -34,7 +34,11 @@ func (f *factory) Type() string {
 }
 
 // Destory tcaplus插件Destory方法.
-func (f *factory) Destroy(interface{}) error {
+func (f *factory) Destroy(ins interface{}) error {
+	// 只关闭执行器, 剩余的回调由app在逻辑goroutine上调用plugin.Tick执行
+	if d, ok := ins.(*DB); ok {
+		d.async.Close()
+	}
 	return nil
 }
 
@@ -68,6 +72,8 @@ type dbCfg struct {
 	PoolSize    int    `mapstructure:"poolsize"`
 	ConnTimeout uint32 `mapstructure:"conntimeout"`
 	Password    string `mapstructure:"password"`
+	// 异步接口的工作goroutine和队列配置
+	Async db.AsyncCfg `mapstructure:"async"`
 }
 
 // DB 实现IDatabase接口.
@@ -75,11 +81,13 @@ type DB struct {
 	client *redisApi.Client
 	ctx    context.Context
 	cancel context.CancelFunc
+	async  *db.AsyncExecutor
 }
 
 // 类型断言.
 var (
 	_ db.IDBSimpleExecutor = (*DB)(nil)
+	_ db.IDBAsyncExecutor  = (*DB)(nil)
 )
 
 // Open 初始化一个tcaplusDB.
@@ -117,6 +125,7 @@ func (t *DB) Open(config interface{}) error {
 	if t.client == nil {
 		return errors.New("new redis client nil")
 	}
+	t.async = db.NewAsyncExecutor(t.ctx, &cfg.Async)
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -21,13 +22,17 @@ const (
 //  @param fields 如果为nil，表示全量拉取
 //  @return err
 func (t *DB) SimpleGet(record Record, fields []string) error {
+	return t.get(t.ctx, record, fields)
+}
+
+func (t *DB) get(ctx context.Context, record Record, fields []string) error {
 	//sw := metrics.StartStopwatchWithGroup("bingo.RedisCmd", "bingodb")
 	//defer sw.RecordWithDim([]*metrics.Dimension{
 	//{Name: "cmd", Value: "Get"},
 	//})
 	k := BuildKey(record)
 	if len(fields) == 0 {
-		ret, err := t.client.HGetAll(t.ctx, k).Result()
+		ret, err := t.client.HGetAll(ctx, k).Result()
 		if err != nil {
 			return fmt.Errorf("redis ret err:%w", err)
 		}
@@ -37,7 +42,7 @@ func (t *DB) SimpleGet(record Record, fields []string) error {
 		return pbsupport.UnmarshalFromMap(record, ret)
 	}
 
-	ret, err := t.client.HMGet(t.ctx, k, fields...).Result()
+	ret, err := t.client.HMGet(ctx, k, fields...).Result()
 	if err != nil || len(ret) != len(fields) {
 		return fmt.Errorf("redis ret err:%w", err)
 	}
@@ -65,6 +70,10 @@ func (t *DB) SimpleBatchGet(record []Record) error {
 //  @param fields 如果为nil，表示全量更新
 //  @return err
 func (t *DB) SimpleUpdate(record Record, fields []string) (err error) {
+	return t.update(t.ctx, record, fields)
+}
+
+func (t *DB) update(ctx context.Context, record Record, fields []string) (err error) {
 	//sw := metrics.StartStopwatchWithGroup("bingo.RedisCmd", "bingodb")
 	//defer sw.RecordWithDim([]*metrics.Dimension{
 	//{Name: "cmd", Value: "Update"},
@@ -89,7 +98,7 @@ func (t *DB) SimpleUpdate(record Record, fields []string) (err error) {
 		args = append(args, k, v)
 	}
 	k := BuildKey(record)
-	ret, err := t.client.Eval(t.ctx, script, []string{k}, args...).Result()
+	ret, err := t.client.Eval(ctx, script, []string{k}, args...).Result()
 	if err != nil {
 		return fmt.Errorf("redis ret err:%w", err)
 	}
@@ -107,6 +116,10 @@ func (t *DB) SimpleUpdate(record Record, fields []string) (err error) {
 //  @param model
 //  @return error
 func (t *DB) SimpleInsert(record Record) error {
+	return t.insert(t.ctx, record)
+}
+
+func (t *DB) insert(ctx context.Context, record Record) error {
 	//sw := metrics.StartStopwatchWithGroup("bingo.RedisCmd", "bingodb")
 	//defer sw.RecordWithDim([]*metrics.Dimension{
 	//{Name: "cmd", Value: "Insert"},
@@ -131,7 +144,7 @@ func (t *DB) SimpleInsert(record Record) error {
 		insertargs = append(insertargs, k, v)
 	}
 	k := BuildKey(record)
-	result, err := t.client.Eval(t.ctx, script, []string{k}, insertargs...).Result()
+	result, err := t.client.Eval(ctx, script, []string{k}, insertargs...).Result()
 	if err != nil {
 		return fmt.Errorf("redis ret err:%w", err)
 	}
@@ -149,6 +162,10 @@ func (t *DB) SimpleInsert(record Record) error {
 //  @param model 传入的数据模型
 //  @return err
 func (t *DB) SimpleReplace(record Record) (err error) {
+	return t.replace(t.ctx, record)
+}
+
+func (t *DB) replace(ctx context.Context, record Record) (err error) {
 	//sw := metrics.StartStopwatchWithGroup("bingo.RedisCmd", "bingodb")
 	//defer sw.RecordWithDim([]*metrics.Dimension{
 	//{Name: "cmd", Value: "Replace"},
@@ -158,7 +175,7 @@ func (t *DB) SimpleReplace(record Record) (err error) {
 		err = errors.New("marshal map failed")
 		return
 	}
-	ret, err := t.client.HMSet(t.ctx, BuildKey(record), m).Result()
+	ret, err := t.client.HMSet(ctx, BuildKey(record), m).Result()
 	if err != nil {
 		err = fmt.Errorf("redis ret err:%w", err)
 		return
@@ -175,15 +192,19 @@ func (t *DB) SimpleReplace(record Record) (err error) {
 //  @param resultFlag 指定0表示不需要返回数据，3表示从model传出删除的数据
 //  @return error
 func (t *DB) SimpleDelete(record Record, resultFlag int) error {
+	return t.del(t.ctx, record, resultFlag)
+}
+
+func (t *DB) del(ctx context.Context, record Record, resultFlag int) error {
 	//sw := metrics.StartStopwatchWithGroup("bingo.RedisCmd", "bingodb")
 	//defer sw.RecordWithDim([]*metrics.Dimension{
 	//{Name: "cmd", Value: "Delete"},
 	//})
 	// nolint
 	if resultFlag == 3 {
-		t.SimpleGet(record, nil)
+		t.get(ctx, record, nil)
 	}
-	_, err := t.client.Del(t.ctx, BuildKey(record)).Result()
+	_, err := t.client.Del(ctx, BuildKey(record)).Result()
 	if err != nil {
 		return fmt.Errorf("redis ret err:%w", err)
 	}
@@ -195,6 +216,10 @@ func (t *DB) SimpleDelete(record Record, resultFlag int) error {
 //  @param fields 指定字段集合，需要在model中有赋值
 //  @return err
 func (t *DB) SimpleIncrease(record Record, fields []string) error {
+	return t.increase(t.ctx, record, fields)
+}
+
+func (t *DB) increase(ctx context.Context, record Record, fields []string) error {
 	//sw := metrics.StartStopwatchWithGroup("bingo.RedisCmd", "bingodb")
 	//defer sw.RecordWithDim([]*metrics.Dimension{
 	//{Name: "cmd", Value: "Increase"},
@@ -205,7 +230,7 @@ func (t *DB) SimpleIncrease(record Record, fields []string) error {
 		return fmt.Errorf("cannot increase fields=%s", strings.Join(fields, ","))
 	}
 	if len(fields) == 1 {
-		_, e1 := t.client.HIncrBy(t.ctx, BuildKey(record), fields[0], 1).Result()
+		_, e1 := t.client.HIncrBy(ctx, BuildKey(record), fields[0], 1).Result()
 		if e1 != nil {
 			return fmt.Errorf("redis ret err:%w", e1)
 		}
@@ -215,8 +240,8 @@ func (t *DB) SimpleIncrease(record Record, fields []string) error {
 	pip := t.client.Pipeline()
 	k := BuildKey(record)
 	for _, f := range fields {
-		pip.HIncrBy(t.ctx, k, f, 1)
+		pip.HIncrBy(ctx, k, f, 1)
 	}
-	_, err := pip.Exec(t.ctx)
+	_, err := pip.Exec(ctx)
 	return err
 }
@@ -126,6 +126,7 @@ type BingoLogger struct {
 	fileCreateTime    time.Time          // cur file create time.
 	generation        int                // file split count.
 	isInited          bool               // bingo logger inited.
+	isClosed          bool               // bingo logger closed, drop writes.
 	lock              sync.Mutex         // write lock.
 	bufChan           chan []byte        // log buf channel queue.
 	ntfChan           chan chan struct{} // Notification of immediate write or closing of write concurrent.
@@ -276,11 +277,34 @@ func (l *BingoLogger) Sync() error {
 	return nil
 }
 
+// Close Write back cached logs and close the file, later writes are dropped.
+func (l *BingoLogger) Close() error {
+	if err := l.Sync(); err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.isClosed = true
+	if l.fileFd == nil {
+		return nil
+	}
+
+	err := l.fileFd.Close()
+	l.fileFd = nil
+	return err
+}
+
 // writeSync Synchronized log writing.
 func (l *BingoLogger) writeSync(buf []byte) (n int, err error) {
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
+	if l.isClosed {
+		return 0, errors.New("logger closed")
+	}
+
 	if err := l.updateFileFd(); err != nil {
 		return 0, fmt.Errorf("Write err:%w", err)
 	}
@@ -280,6 +280,20 @@ func TestBingoLogger_Sync(t *testing.T) {
 	})
 }
 
+func TestBingoLogger_Close(t *testing.T) {
+	got, err := NewBingoLogger(LogCfg{LogPath: "./logs/close.log", FileSplitMB: 1})
+	assert.NoError(t, err)
+
+	_, err = got.Write([]byte("before close"))
+	assert.NoError(t, err)
+	assert.NoError(t, got.Close())
+	assert.Nil(t, got.fileFd)
+
+	_, err = got.Write([]byte("after close"))
+	assert.Error(t, err)
+	assert.Nil(t, got.fileFd)
+}
+
 func TestBingoLogger_updateOldFileFd(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		v := viper.New()
@@ -1,6 +1,8 @@
 package kafka
 
 import (
+	"fmt"
+
 	"github.com/mitchellh/mapstructure"
 	"github.com/nearmeng/mango-go/plugin"
 	"github.com/nearmeng/mango-go/plugin/mq"
@@ -45,7 +47,10 @@ func (f *factory) Reload(i interface{}, conf map[string]interface{}) error {
 		return err
 	}
 
-	cli := i.(KafkaClient)
+	cli, ok := i.(*KafkaClient)
+	if !ok {
+		return fmt.Errorf("reload plugin inst type %T not *KafkaClient", i)
+	}
 	cli.SetConfig(&config)
 
 	return nil
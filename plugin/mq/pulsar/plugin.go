@@ -1,6 +1,8 @@
 package pulsar
 
 import (
+	"fmt"
+
 	"github.com/mitchellh/mapstructure"
 	"github.com/nearmeng/mango-go/plugin"
 	"github.com/nearmeng/mango-go/plugin/mq"
@@ -41,7 +43,10 @@ func (f *factory) Reload(i interface{}, conf map[string]interface{}) error {
 		return err
 	}
 
-	cli := i.(PulsarClient)
+	cli, ok := i.(*PulsarClient)
+	if !ok {
+		return fmt.Errorf("reload plugin inst type %T not *PulsarClient", i)
+	}
 	cli.SetConfig(&config)
 
 	return nil
@@ -23,8 +23,14 @@ var (
 	_pluginFactoryMgr  = make(map[string]PluginFactory)
 	_pluginFactoryLock = sync.RWMutex{}
 	_pluginMgr         = make(map[string]interface{})
+	_tickNotify        = make(chan struct{}, 1)
 )
 
+// Ticker 需要在逻辑goroutine上执行回调的插件, 如数据库的异步请求.
+type Ticker interface {
+	OnTick()
+}
+
 func RegisterPluginFactory(f PluginFactory) {
 	_pluginFactoryLock.Lock()
 	defer _pluginFactoryLock.Unlock()
@@ -105,8 +111,41 @@ func Init(v *viper.Viper) error {
 	return nil
 }
 
+// Reload 将最新配置下发给已初始化的插件, 配置中新增的插件不会在reload时创建.
 func Reload(v *viper.Viper) error {
-	return nil
+	var cfg PluginConfig
+
+	err := v.Unmarshal(&cfg)
+	if err != nil {
+		return fmt.Errorf("unmarshal failed for %w", err)
+	}
+
+	var lastErr error
+	for t, s := range cfg {
+		for n, _ := range s {
+			key := constructPluginKey(t, n)
+
+			p, ok := _pluginMgr[key]
+			if !ok {
+				log.Error("plugin %s not init, skip reload", key)
+				continue
+			}
+
+			conf := make(map[string]interface{})
+			if sub := v.Sub(t).Sub(n); sub != nil {
+				conf = sub.AllSettings()
+			}
+
+			f := getPluginFactoryByKey(key)
+			err = f.Reload(p, conf)
+			if err != nil {
+				log.Error("plugin %s reload failed for %s", key, err.Error())
+				lastErr = fmt.Errorf("plugin reload failed, type %s name %s: %w", t, n, err)
+			}
+		}
+	}
+
+	return lastErr
 }
 
 func Mainloop() {
@@ -116,6 +155,28 @@ func Mainloop() {
 	}
 }
 
+// Tick 由主循环调用, 在逻辑goroutine上执行所有Ticker插件的OnTick.
+func Tick() {
+	for _, p := range _pluginMgr {
+		if t, ok := p.(Ticker); ok {
+			t.OnTick()
+		}
+	}
+}
+
+// NotifyTick 插件有待执行的回调时调用, 可以在任意goroutine上调用.
+func NotifyTick() {
+	select {
+	case _tickNotify <- struct{}{}:
+	default:
+	}
+}
+
+// TickNotify 有插件调用NotifyTick后可读, 主循环在select中等待后调用Tick.
+func TickNotify() <-chan struct{} {
+	return _tickNotify
+}
+
 func Destroy() error {
 	for k, p := range _pluginMgr {
 		log.Info("begin destroy plugin %s", k)
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+type reloadInst struct {
+	conf map[string]interface{}
+}
+
+type reloadFactory struct{}
+
+func (f *reloadFactory) Type() string { return "test" }
+func (f *reloadFactory) Name() string { return "reload" }
+
+func (f *reloadFactory) Setup(*viper.Viper) (interface{}, error) { return &reloadInst{}, nil }
+func (f *reloadFactory) Destroy(interface{}) error               { return nil }
+func (f *reloadFactory) Mainloop(interface{})                    {}
+
+func (f *reloadFactory) Reload(i interface{}, conf map[string]interface{}) error {
+	inst, ok := i.(*reloadInst)
+	if !ok {
+		return errors.New("inst type mismatch")
+	}
+	inst.conf = conf
+	return nil
+}
+
+func TestReload(t *testing.T) {
+	RegisterPluginFactory(&reloadFactory{})
+
+	inst := &reloadInst{}
+	key := constructPluginKey("test", "reload")
+	registerPluginInst(key, inst)
+	defer delete(_pluginMgr, key)
+
+	v := viper.New()
+	v.Set("test.reload.addr", "127.0.0.1:9000")
+	v.Set("test.missing.addr", "127.0.0.1:9001")
+
+	if err := Reload(v); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	if inst.conf["addr"] != "127.0.0.1:9000" {
+		t.Errorf("fail: reload conf %v", inst.conf)
+	}
+}
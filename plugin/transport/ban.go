@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// 进程内所有transport共享的IP封禁表.
+var (
+	_banMutex sync.Mutex
+	_banIPs   = make(map[string]time.Time)
+)
+
+// BanIP 封禁ip一段时间, 对新建立的连接生效.
+func BanIP(ip net.IP, d time.Duration) {
+	if ip == nil || d <= 0 {
+		return
+	}
+
+	_banMutex.Lock()
+	defer _banMutex.Unlock()
+
+	expire := time.Now().Add(d)
+	if old, ok := _banIPs[ip.String()]; ok && old.After(expire) {
+		return
+	}
+
+	_banIPs[ip.String()] = expire
+}
+
+// UnbanIP 解除封禁.
+func UnbanIP(ip net.IP) {
+	_banMutex.Lock()
+	defer _banMutex.Unlock()
+
+	delete(_banIPs, ip.String())
+}
+
+// IsIPBanned 检查ip是否处于封禁中, 顺带清理过期的记录.
+func IsIPBanned(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	_banMutex.Lock()
+	defer _banMutex.Unlock()
+
+	key := ip.String()
+
+	expire, ok := _banIPs[key]
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(expire) {
+		delete(_banIPs, key)
+		return false
+	}
+
+	return true
+}
+
+// GetBannedIPs 获取当前封禁列表及过期时间.
+func GetBannedIPs() map[string]time.Time {
+	_banMutex.Lock()
+	defer _banMutex.Unlock()
+
+	now := time.Now()
+	result := make(map[string]time.Time, len(_banIPs))
+	for ip, expire := range _banIPs {
+		if now.After(expire) {
+			delete(_banIPs, ip)
+			continue
+		}
+		result[ip] = expire
+	}
+
+	return result
+}
+
+// GetRemoteIP 获取连接对端ip, 非ip地址(如unix socket)返回nil.
+func GetRemoteIP(c Conn) net.IP {
+	switch addr := c.GetRemoteAddr().(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
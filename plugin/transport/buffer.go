@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	_minBufferClassBits = 6  // 64B
+	_maxBufferClassBits = 20 // 1MB
+)
+
+// Buffer 池化的收发缓冲区, B为当前有效数据.
+type Buffer struct {
+	B     []byte
+	raw   []byte
+	class int
+}
+
+var (
+	_bufferPools [_maxBufferClassBits - _minBufferClassBits + 1]sync.Pool
+	_preHeadPool = sync.Pool{
+		New: func() interface{} {
+			return new([8]byte)
+		},
+	}
+)
+
+func init() {
+	for i := range _bufferPools {
+		size := 1 << (i + _minBufferClassBits)
+		class := i
+		_bufferPools[i].New = func() interface{} {
+			return &Buffer{raw: make([]byte, size), class: class}
+		}
+	}
+}
+
+func bufferClass(size int) int {
+	if size <= 1<<_minBufferClassBits {
+		return 0
+	}
+
+	n := bits.Len(uint(size - 1))
+	if n > _maxBufferClassBits {
+		return -1
+	}
+
+	return n - _minBufferClassBits
+}
+
+// AcquireBuffer 从池中获取长度为size的缓冲区, 超过1MB时直接分配.
+func AcquireBuffer(size int) *Buffer {
+	class := bufferClass(size)
+	if class < 0 {
+		raw := make([]byte, size)
+		return &Buffer{B: raw, raw: raw, class: -1}
+	}
+
+	b := _bufferPools[class].Get().(*Buffer)
+	b.B = b.raw[:size]
+
+	return b
+}
+
+// ReleaseBuffer 归还缓冲区, 归还后不能再访问B.
+func ReleaseBuffer(b *Buffer) {
+	if b == nil || b.class < 0 {
+		return
+	}
+
+	b.B = nil
+	_bufferPools[b.class].Put(b)
+}
@@ -3,6 +3,8 @@ package transport
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 
 	"github.com/nearmeng/mango-go/plugin/log"
@@ -13,10 +15,28 @@ type Codec interface {
 	Decode(c Conn) ([]byte, error)
 }
 
+// FrameHeadroom 应用层写入Buffer时需要在头部预留的字节数, 供codec原地写入帧头.
+const FrameHeadroom = 4
+
+// FrameCodec 可选接口, 基于池化Buffer原地编解码, 避免逐包分配和拷贝.
+type FrameCodec interface {
+	// EncodeFrame buf.B[FrameHeadroom:]为应用层数据, 调用后buf的所有权转移给codec,
+	// 返回的Buffer(可能就是buf)中B为可以直接写出的完整帧, 由调用方归还.
+	EncodeFrame(c Conn, buf *Buffer) (*Buffer, error)
+	// DecodeFrame 返回的Buffer中B为应用层数据, 使用完后需要ReleaseBuffer.
+	DecodeFrame(c Conn) (*Buffer, error)
+}
+
+// FrameKeyCodec 可选接口, FrameKey相同的连接EncodeFrame的结果相同, 组播时可以共享同一帧.
+type FrameKeyCodec interface {
+	FrameKey(c Conn) uint32
+}
+
 var (
 	_codec Codec = &DefaultCodec{}
 )
 
+// GetCodec 默认codec, 监听器没有指定codec且没有帧格式配置时使用.
 func GetCodec() Codec {
 	return _codec
 }
@@ -27,82 +47,324 @@ func SetCodec(codec Codec) {
 
 //=====================================================
 
+var (
+	ErrHeaderTooLarge = errors.New("transport: header size too large")
+	ErrBodyTooLarge   = errors.New("transport: body size too large")
+	ErrTruncated      = errors.New("transport: frame truncated")
+)
+
+const (
+	DefaultMaxHeaderSize = 4 * 1024
+	DefaultMaxBodySize   = 1024 * 1024
+)
+
+// DefaultCodec 默认帧格式, MaxHeaderSize/MaxBodySize为0时使用默认上限.
+// CompressType不为COMPRESS_NONE时, 对端声明支持且包长不小于CompressThreshold的帧会被压缩.
 type DefaultCodec struct {
+	MaxHeaderSize     uint32
+	MaxBodySize       uint32
+	CompressType      uint8
+	CompressThreshold int
 }
 
 var (
 	_headSize = 8
 )
 
+const (
+	_headerSizeMask   = 0x00FFFFFF
+	_flagShift        = 24
+	_flagCompressMask = 0x03
+	_flagAcceptShift  = 4
+	_flagAcceptMask   = 0x70
+)
+
+// PreHead 帧头, HeaderSize字段的高8位为Flags.
+// Flags低2位为本帧的压缩算法, 4~6位为发送方可以接收的压缩算法.
+// 压缩帧的BodySize为压缩数据长度, 压缩数据前4字节为原始body长度.
 type PreHead struct {
 	HeaderSize uint32
 	BodySize   uint32
+	Flags      uint8
 }
 
-func (codec *DefaultCodec) Decode(c Conn) ([]byte, error) {
-	headBuff := make([]byte, _headSize)
+// AcceptCompressFlag 返回声明可以接收某种压缩算法的Flags位, 供客户端填入PreHead.
+func AcceptCompressFlag(compressType uint8) uint8 {
+	if compressType == COMPRESS_NONE {
+		return 0
+	}
+	return 1 << (_flagAcceptShift + compressType - 1)
+}
+
+type compressCtxKey struct{}
+
+func parsePreHead(buff []byte) PreHead {
+	v := binary.LittleEndian.Uint32(buff[0:4])
+
+	return PreHead{
+		HeaderSize: v & _headerSizeMask,
+		BodySize:   binary.LittleEndian.Uint32(buff[4:8]),
+		Flags:      uint8(v >> _flagShift),
+	}
+}
+
+func putPreHead(buff []byte, header *PreHead) {
+	binary.LittleEndian.PutUint32(buff[0:4], header.HeaderSize|uint32(header.Flags)<<_flagShift)
+	binary.LittleEndian.PutUint32(buff[4:8], header.BodySize)
+}
+
+func (codec *DefaultCodec) maxHeaderSize() uint32 {
+	if codec.MaxHeaderSize == 0 {
+		return DefaultMaxHeaderSize
+	}
+	return codec.MaxHeaderSize
+}
+
+func (codec *DefaultCodec) maxBodySize() uint32 {
+	if codec.MaxBodySize == 0 {
+		return DefaultMaxBodySize
+	}
+	return codec.MaxBodySize
+}
+
+// checkPreHead 校验帧头中的长度字段, 必须在按长度分配内存之前调用.
+func (codec *DefaultCodec) checkPreHead(header *PreHead) error {
+	if header.HeaderSize > codec.maxHeaderSize() {
+		return fmt.Errorf("%w: %d > %d", ErrHeaderTooLarge, header.HeaderSize, codec.maxHeaderSize())
+	}
 
-	n, err := c.Read(headBuff)
+	if header.BodySize > codec.maxBodySize() {
+		return fmt.Errorf("%w: %d > %d", ErrBodyTooLarge, header.BodySize, codec.maxBodySize())
+	}
+
+	return nil
+}
+
+// onRecvFlags 记录对端声明可以接收的压缩算法.
+func (codec *DefaultCodec) onRecvFlags(c Conn, flags uint8) {
+	ctxConn, ok := c.(ContextConn)
+	if !ok {
+		return
+	}
+
+	accept := flags & _flagAcceptMask
+	old, _ := ctxConn.GetContext(compressCtxKey{}).(uint8)
+	if old != accept {
+		ctxConn.SetContext(compressCtxKey{}, accept)
+	}
+}
+
+func (codec *DefaultCodec) peerAcceptCompress(c Conn) bool {
+	ctxConn, ok := c.(ContextConn)
+	if !ok {
+		return false
+	}
+
+	accept, _ := ctxConn.GetContext(compressCtxKey{}).(uint8)
+	return accept&AcceptCompressFlag(codec.CompressType) != 0
+}
+
+// FrameKey 帧内容只取决于对端是否接受压缩.
+func (codec *DefaultCodec) FrameKey(c Conn) uint32 {
+	if codec.CompressType != COMPRESS_NONE && codec.peerAcceptCompress(c) {
+		return 1
+	}
+	return 0
+}
+
+func readErr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrTruncated, err)
+	}
+	return err
+}
+
+func (codec *DefaultCodec) Decode(c Conn) ([]byte, error) {
+	buf, err := codec.DecodeFrame(c)
 	if err != nil {
 		var e net.Error
 		if errors.As(err, &e) && e.Timeout() {
 			log.Error("client %s is stopped for timeout", c.GetRemoteAddr().String())
-			return nil, err
 		} else {
 			log.Info("client %s is stopped for err %s", c.GetRemoteAddr().String(), err.Error())
-			return nil, err
 		}
+		return nil, err
+	}
+
+	data := make([]byte, len(buf.B))
+	copy(data, buf.B)
+	ReleaseBuffer(buf)
+
+	return data, nil
+}
+
+func (codec *DefaultCodec) Encode(c Conn, buff []byte) ([]byte, error) {
+	if len(buff) < 4 {
+		return nil, fmt.Errorf("%w: buff size %d", ErrTruncated, len(buff))
 	}
 
-	if n != _headSize {
-		log.Error("client %s head is not match %d err %v", c.GetRemoteAddr().String(), n, err)
-		return nil, errors.New("head not match")
+	buf := AcquireBuffer(FrameHeadroom + len(buff))
+	copy(buf.B[FrameHeadroom:], buff)
+
+	frame, err := codec.EncodeFrame(c, buf)
+	if err != nil {
+		return nil, err
 	}
 
-	header := PreHead{
-		HeaderSize: binary.LittleEndian.Uint32(headBuff),
-		BodySize:   binary.LittleEndian.Uint32(headBuff[4:8]),
+	result := make([]byte, len(frame.B))
+	copy(result, frame.B)
+	ReleaseBuffer(frame)
+
+	return result, nil
+}
+
+// EncodeFrame 在buf头部预留的空间上原地写入PreHead, 需要压缩时返回新的Buffer.
+func (codec *DefaultCodec) EncodeFrame(c Conn, buf *Buffer) (*Buffer, error) {
+	if len(buf.B) < _headSize {
+		ReleaseBuffer(buf)
+		return nil, fmt.Errorf("%w: buff size %d", ErrTruncated, len(buf.B))
 	}
 
-	log.Info("decode recv headbuff size %d header_size %d body_size %d", n, header.HeaderSize, header.BodySize)
+	headerSize := binary.LittleEndian.Uint32(buf.B[FrameHeadroom:])
+	if uint64(headerSize) > uint64(len(buf.B)-_headSize) {
+		ReleaseBuffer(buf)
+		return nil, fmt.Errorf("%w: header size %d buff size %d", ErrTruncated, headerSize, len(buf.B))
+	}
 
-	dataBuff := make([]byte, 4+header.HeaderSize+header.BodySize)
-	binary.LittleEndian.PutUint32(dataBuff[0:4], header.HeaderSize)
+	header := PreHead{
+		HeaderSize: headerSize,
+		BodySize:   uint32(len(buf.B) - _headSize - int(headerSize)),
+	}
 
-	n, err = c.Read(dataBuff[4:])
+	err := codec.checkPreHead(&header)
 	if err != nil {
-		var e net.Error
-		if errors.As(err, &e) && e.Timeout() {
-			log.Error("client %s is stopped for timeout", c.GetRemoteAddr().String())
-			return nil, err
-		} else {
-			log.Error("client %s is stopped for err %s", c.GetRemoteAddr().String(), err.Error())
-			return nil, err
+		ReleaseBuffer(buf)
+		return nil, err
+	}
+
+	if codec.CompressType != COMPRESS_NONE && len(buf.B)-_headSize >= codec.CompressThreshold &&
+		codec.peerAcceptCompress(c) {
+		frame := codec.compressFrame(buf, &header)
+		if frame != nil {
+			ReleaseBuffer(buf)
+			return frame, nil
 		}
 	}
 
-	if uint32(n) != (header.HeaderSize + header.BodySize) {
-		log.Error("client %s body not match", c.GetRemoteAddr().String())
-		return nil, errors.New("body not match")
+	putPreHead(buf.B, &header)
+
+	return buf, nil
+}
+
+// compressFrame 压缩header和body, 压缩无收益时返回nil.
+func (codec *DefaultCodec) compressFrame(buf *Buffer, header *PreHead) *Buffer {
+	compressor, err := getCompressor(codec.CompressType)
+	if err != nil {
+		return nil
+	}
+
+	payload := buf.B[_headSize:]
+	frame := AcquireBuffer(_headSize + 4 + compressor.MaxCompressedLen(len(payload)))
+
+	n, err := compressor.Compress(frame.B[_headSize+4:], payload)
+	if err != nil || n == 0 || 4+n >= len(payload) {
+		ReleaseBuffer(frame)
+		return nil
+	}
+
+	binary.LittleEndian.PutUint32(frame.B[_headSize:], header.BodySize)
+
+	compressHeader := PreHead{
+		HeaderSize: header.HeaderSize,
+		BodySize:   uint32(4 + n),
+		Flags:      codec.CompressType,
 	}
+	putPreHead(frame.B, &compressHeader)
+	frame.B = frame.B[:_headSize+4+n]
 
-	log.Info("decode recv bodyBuff size %d", n)
+	addCompressStats(len(payload), 4+n)
 
-	return dataBuff, nil
+	return frame
 }
 
-func (codec *DefaultCodec) Encode(c Conn, buff []byte) ([]byte, error) {
-	headerSize := binary.LittleEndian.Uint32(buff)
-	bodySize := len(buff) - 4 - int(headerSize)
+// DecodeFrame 将整帧读入池化的Buffer, 再把headerSize移到body前, 返回应用层数据视图.
+func (codec *DefaultCodec) DecodeFrame(c Conn) (*Buffer, error) {
+	headBuff := _preHeadPool.Get().(*[8]byte)
+	defer _preHeadPool.Put(headBuff)
 
-	result := make([]byte, _headSize+int(headerSize)+bodySize)
+	_, err := c.Read(headBuff[:])
+	if err != nil {
+		return nil, err
+	}
 
-	binary.LittleEndian.PutUint32(result[0:4], headerSize)
-	binary.LittleEndian.PutUint32(result[4:8], uint32(bodySize))
+	header := parsePreHead(headBuff[:])
 
-	copy(result[8:], buff[4:])
+	err = codec.checkPreHead(&header)
+	if err != nil {
+		return nil, err
+	}
 
-	return result, nil
+	codec.onRecvFlags(c, header.Flags)
+
+	compressType := header.Flags & _flagCompressMask
+	if compressType != COMPRESS_NONE {
+		return codec.decodeCompressed(c, &header, compressType)
+	}
+
+	buf := AcquireBuffer(_headSize + int(header.HeaderSize) + int(header.BodySize))
+
+	_, err = c.Read(buf.B[_headSize:])
+	if err != nil {
+		ReleaseBuffer(buf)
+		return nil, readErr(err)
+	}
+
+	binary.LittleEndian.PutUint32(buf.B[FrameHeadroom:_headSize], header.HeaderSize)
+	buf.B = buf.B[FrameHeadroom:]
+
+	return buf, nil
+}
+
+func (codec *DefaultCodec) decodeCompressed(c Conn, header *PreHead, compressType uint8) (*Buffer, error) {
+	compressor, err := getCompressor(compressType)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.BodySize < 4 {
+		return nil, fmt.Errorf("%w: compressed body size %d", ErrTruncated, header.BodySize)
+	}
+
+	src := AcquireBuffer(int(header.BodySize))
+	defer ReleaseBuffer(src)
+
+	_, err = c.Read(src.B)
+	if err != nil {
+		return nil, readErr(err)
+	}
+
+	rawHeader := PreHead{
+		HeaderSize: header.HeaderSize,
+		BodySize:   binary.LittleEndian.Uint32(src.B[0:4]),
+	}
+
+	err = codec.checkPreHead(&rawHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := AcquireBuffer(_headSize + int(rawHeader.HeaderSize) + int(rawHeader.BodySize))
+
+	err = compressor.Decompress(buf.B[_headSize:], src.B[4:], uint64(codec.maxHeaderSize())+uint64(codec.maxBodySize()))
+	if err != nil {
+		ReleaseBuffer(buf)
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint32(buf.B[FrameHeadroom:_headSize], rawHeader.HeaderSize)
+	buf.B = buf.B[FrameHeadroom:]
+
+	return buf, nil
 }
 
 // application data protocol
@@ -112,3 +374,7 @@ func (codec *DefaultCodec) Encode(c Conn, buff []byte) ([]byte, error) {
 // transport data protocol
 //0-----------------4----------------8----------------------------------------
 //|----headerSize---|----bodySize----|-----header-------|--------data--------|
+
+// transport compressed data protocol (flags in high 8 bits of headerSize)
+//0-----------------4----------------8----------------12----------------------
+//|-flags|headerSize|--compressSize--|--rawBodySize----|--compress(header+data)--|
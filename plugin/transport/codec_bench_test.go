@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"net"
+	"testing"
+)
+
+// loopConn 循环返回同一帧数据.
+type loopConn struct {
+	frame []byte
+	off   int
+}
+
+func (c *loopConn) GetConnID() uint64              { return 1 }
+func (c *loopConn) GetLocalAddr() (addr net.Addr)  { return nil }
+func (c *loopConn) GetRemoteAddr() (addr net.Addr) { return nil }
+func (c *loopConn) Send(data []byte) error         { return nil }
+func (c *loopConn) Close(active bool) error        { return nil }
+
+func (c *loopConn) Read(targetBuff []byte) (int, error) {
+	index := 0
+	for index < len(targetBuff) {
+		n := copy(targetBuff[index:], c.frame[c.off:])
+		index += n
+		c.off = (c.off + n) % len(c.frame)
+	}
+	return index, nil
+}
+
+func benchFrame() []byte {
+	payload := make([]byte, 16+1024)
+	return buildFrame(16, 1024, payload)
+}
+
+func BenchmarkDefaultCodecDecode(b *testing.B) {
+	codec := &DefaultCodec{}
+	conn := &loopConn{frame: benchFrame()}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Decode(conn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDefaultCodecDecodeFrame(b *testing.B) {
+	codec := &DefaultCodec{}
+	conn := &loopConn{frame: benchFrame()}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, err := codec.DecodeFrame(conn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ReleaseBuffer(buf)
+	}
+}
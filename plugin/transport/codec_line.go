@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrLineTooLong = errors.New("transport: json line too long")
+	ErrBadJSONLine = errors.New("transport: bad json line")
+)
+
+// JSONLineCodec 按行分隔的json帧格式, 不需要二进制帧头, 用于web工具和调试客户端直接收发json.
+// 每行为一个对象 {"head": {...}, "body": {...}}, body可以省略. 对应的应用层数据与DefaultCodec相同,
+// 即4字节header长度后接header和body, 需要配合msg的json codec使用.
+type JSONLineCodec struct {
+	// 单行最大长度, 为0时使用DefaultMaxHeaderSize+DefaultMaxBodySize
+	MaxLineSize uint32
+}
+
+type jsonLine struct {
+	Head json.RawMessage `json:"head"`
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+func (codec *JSONLineCodec) maxLineSize() int {
+	if codec.MaxLineSize == 0 {
+		return DefaultMaxHeaderSize + DefaultMaxBodySize
+	}
+	return int(codec.MaxLineSize)
+}
+
+// Encode 将应用层数据中的header和body拼成一行.
+func (codec *JSONLineCodec) Encode(c Conn, buff []byte) ([]byte, error) {
+	if len(buff) < 4 {
+		return nil, fmt.Errorf("%w: buff size %d", ErrTruncated, len(buff))
+	}
+
+	headerSize := binary.LittleEndian.Uint32(buff[0:4])
+	if uint64(headerSize) > uint64(len(buff)-4) {
+		return nil, fmt.Errorf("%w: header size %d buff size %d", ErrTruncated, headerSize, len(buff))
+	}
+
+	header := buff[4 : 4+headerSize]
+	body := buff[4+headerSize:]
+
+	line := make([]byte, 0, len(buff)+32)
+	line = append(line, `{"head":`...)
+	line = append(line, header...)
+	if len(body) > 0 {
+		line = append(line, `,"body":`...)
+		line = append(line, body...)
+	}
+	line = append(line, '}', '\n')
+
+	return line, nil
+}
+
+// Decode 读取一行并转换为应用层数据, 跳过空行.
+func (codec *JSONLineCodec) Decode(c Conn) ([]byte, error) {
+	var line []byte
+
+	for len(line) == 0 {
+		var err error
+		line, err = codec.readLine(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var v jsonLine
+	err := json.Unmarshal(line, &v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadJSONLine, err)
+	}
+
+	if len(v.Head) == 0 {
+		return nil, fmt.Errorf("%w: head missing", ErrBadJSONLine)
+	}
+
+	data := make([]byte, 4, 4+len(v.Head)+len(v.Body))
+	binary.LittleEndian.PutUint32(data, uint32(len(v.Head)))
+	data = append(data, v.Head...)
+	data = append(data, v.Body...)
+
+	return data, nil
+}
+
+// readLine 逐字节读取到换行符, 返回去掉行尾\r\n的内容.
+func (codec *JSONLineCodec) readLine(c Conn) ([]byte, error) {
+	var (
+		line []byte
+		b    [1]byte
+	)
+
+	for {
+		_, err := c.Read(b[:])
+		if err != nil {
+			return nil, err
+		}
+
+		if b[0] == '\n' {
+			break
+		}
+
+		if len(line) >= codec.maxLineSize() {
+			return nil, fmt.Errorf("%w: max %d", ErrLineTooLong, codec.maxLineSize())
+		}
+		line = append(line, b[0])
+	}
+
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+
+	return line, nil
+}
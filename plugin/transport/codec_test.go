@@ -0,0 +1,300 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+)
+
+type quietLogger struct {
+	log.MockLogger
+}
+
+func (*quietLogger) GetLevel() int {
+	return log.LogLevelFatal + 1
+}
+
+func TestMain(m *testing.M) {
+	log.SetLogger(&quietLogger{})
+	os.Exit(m.Run())
+}
+
+type bufConn struct {
+	r *bytes.Reader
+}
+
+func newBufConn(data []byte) *bufConn {
+	return &bufConn{r: bytes.NewReader(data)}
+}
+
+func (c *bufConn) GetConnID() uint64              { return 1 }
+func (c *bufConn) GetLocalAddr() (addr net.Addr)  { return &net.TCPAddr{} }
+func (c *bufConn) GetRemoteAddr() (addr net.Addr) { return &net.TCPAddr{} }
+func (c *bufConn) Send(data []byte) error         { return nil }
+func (c *bufConn) Close(active bool) error        { return nil }
+
+func (c *bufConn) Read(targetBuff []byte) (int, error) {
+	if len(targetBuff) == 0 {
+		return 0, nil
+	}
+
+	n, err := io.ReadFull(c.r, targetBuff)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func buildFrame(headerSize, bodySize uint32, payload []byte) []byte {
+	frame := make([]byte, 8+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], headerSize)
+	binary.LittleEndian.PutUint32(frame[4:8], bodySize)
+	copy(frame[8:], payload)
+	return frame
+}
+
+func TestDefaultCodecRoundTrip(t *testing.T) {
+	codec := &DefaultCodec{}
+
+	appData := make([]byte, 4+3+5)
+	binary.LittleEndian.PutUint32(appData[0:4], 3)
+	copy(appData[4:], "hdrbody!")
+
+	frame, err := codec.Encode(nil, appData)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	data, err := codec.Decode(newBufConn(frame))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	if !bytes.Equal(data, appData) {
+		t.Errorf("fail: round trip data mismatch")
+	}
+}
+
+func TestDefaultCodecOversize(t *testing.T) {
+	codec := &DefaultCodec{MaxHeaderSize: 16, MaxBodySize: 64}
+
+	_, err := codec.Decode(newBufConn(buildFrame(17, 0, nil)))
+	if !errors.Is(err, ErrHeaderTooLarge) {
+		t.Errorf("fail: expect header too large, got %v", err)
+	}
+
+	_, err = codec.Decode(newBufConn(buildFrame(0, 0xFFFFFFFF, nil)))
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("fail: expect body too large, got %v", err)
+	}
+}
+
+func TestDefaultCodecTruncated(t *testing.T) {
+	codec := &DefaultCodec{}
+
+	_, err := codec.Decode(newBufConn(buildFrame(4, 4, []byte("abc"))))
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("fail: expect truncated, got %v", err)
+	}
+
+	_, err = codec.Encode(nil, []byte{1, 0})
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("fail: expect truncated on short buff, got %v", err)
+	}
+
+	_, err = codec.Encode(nil, []byte{9, 0, 0, 0, 1})
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("fail: expect truncated on bad header size, got %v", err)
+	}
+}
+
+type ctxBufConn struct {
+	bufConn
+	values map[interface{}]interface{}
+}
+
+func (c *ctxBufConn) GetContext(key interface{}) interface{} {
+	return c.values[key]
+}
+
+func (c *ctxBufConn) SetContext(key interface{}, value interface{}) {
+	c.values[key] = value
+}
+
+func TestDefaultCodecCompress(t *testing.T) {
+	var ratio float64
+	SetCompressStatsHook(func(rawSize int, compressedSize int, total CompressStats) {
+		ratio = total.Ratio()
+	})
+	defer SetCompressStatsHook(nil)
+
+	appData := make([]byte, 4+8+4096)
+	binary.LittleEndian.PutUint32(appData[0:4], 8)
+	copy(appData[4:], bytes.Repeat([]byte("mango-go"), len(appData)/8))
+
+	for _, compressType := range []uint8{COMPRESS_SNAPPY, COMPRESS_ZSTD, COMPRESS_LZ4} {
+		codec := &DefaultCodec{CompressType: compressType, CompressThreshold: 128}
+		conn := &ctxBufConn{values: map[interface{}]interface{}{}}
+
+		// 对端未声明支持压缩时不压缩
+		frame, err := codec.Encode(conn, appData)
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+
+		if len(frame) != 4+len(appData) || frame[3] != 0 {
+			t.Errorf("fail: type %d frame should not compress", compressType)
+		}
+
+		conn.r = bytes.NewReader(buildFrame(uint32(AcceptCompressFlag(compressType))<<24, 0, nil))
+		if _, err := codec.Decode(conn); err != nil {
+			t.Fatalf("decode accept flag frame failed: %v", err)
+		}
+
+		frame, err = codec.Encode(conn, appData)
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+
+		if len(frame) >= len(appData) || frame[3]&_flagCompressMask != compressType {
+			t.Errorf("fail: type %d frame should compress, len %d", compressType, len(frame))
+		}
+
+		conn.r = bytes.NewReader(frame)
+		data, err := codec.Decode(conn)
+		if err != nil {
+			t.Fatalf("decode compressed frame failed: %v", err)
+		}
+
+		if !bytes.Equal(data, appData) {
+			t.Errorf("fail: type %d round trip data mismatch", compressType)
+		}
+	}
+
+	if GetCompressStats().Ratio() >= 1 || ratio != GetCompressStats().Ratio() {
+		t.Errorf("fail: compress ratio %v hook %v", GetCompressStats().Ratio(), ratio)
+	}
+}
+
+func TestZstdDecompressLimit(t *testing.T) {
+	c := newZstdCompressor()
+
+	src := bytes.Repeat([]byte("mango-go"), 1024)
+	dst := make([]byte, c.MaxCompressedLen(len(src)))
+	n, err := c.Compress(dst, src)
+	if err != nil || n == 0 {
+		t.Fatalf("compress failed: %v", err)
+	}
+
+	out := make([]byte, len(src))
+	if err := c.Decompress(out, dst[:n], uint64(len(src))); err != nil || !bytes.Equal(out, src) {
+		t.Errorf("fail: decompress within limit, err %v", err)
+	}
+
+	if err := c.Decompress(out, dst[:n], 1024); !errors.Is(err, ErrDecompressFailed) {
+		t.Errorf("fail: expect decompress over limit failed, err %v", err)
+	}
+}
+
+func TestZstdConcurrent(t *testing.T) {
+	c := newZstdCompressor()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			src := bytes.Repeat([]byte{byte('a' + i)}, 4096)
+			for j := 0; j < 100; j++ {
+				dst := make([]byte, c.MaxCompressedLen(len(src)))
+				n, err := c.Compress(dst, src)
+				out := make([]byte, len(src))
+				if err != nil || n == 0 || c.Decompress(out, dst[:n], uint64(len(src))) != nil || !bytes.Equal(out, src) {
+					t.Errorf("fail: concurrent round trip %d mismatch, err %v", i, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func FuzzDefaultCodecDecode(f *testing.F) {
+	f.Add(buildFrame(2, 2, []byte("hhbb")))
+	f.Add(buildFrame(0xFFFFFFFF, 0xFFFFFFFF, nil))
+	f.Add([]byte{1, 2, 3})
+
+	codec := &DefaultCodec{MaxHeaderSize: 256, MaxBodySize: 4096}
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		data, err := codec.Decode(newBufConn(frame))
+		if err != nil {
+			return
+		}
+
+		headerSize := binary.LittleEndian.Uint32(data[0:4])
+		if headerSize > 256 || len(data)-4-int(headerSize) > 4096 {
+			t.Fatalf("decoded frame exceeds limits, header %d len %d", headerSize, len(data))
+		}
+
+		reencoded, err := codec.Encode(nil, data)
+		if err != nil {
+			t.Fatalf("re-encode failed: %v", err)
+		}
+
+		if frame[3] != 0 {
+			return
+		}
+
+		if !bytes.Equal(reencoded, frame[:len(reencoded)]) {
+			t.Fatalf("re-encoded frame mismatch")
+		}
+	})
+}
+
+func FuzzDefaultCodecEncode(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0})
+	f.Add([]byte{2, 0, 0, 0, 'h', 'h', 'b'})
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+
+	codec := &DefaultCodec{}
+
+	f.Fuzz(func(t *testing.T, buff []byte) {
+		_, _ = codec.Encode(nil, buff)
+	})
+}
+
+func TestJSONLineCodec(t *testing.T) {
+	codec := &JSONLineCodec{MaxLineSize: 64}
+
+	data, err := codec.Decode(newBufConn([]byte("\r\n{\"head\": {\"msgid\": 1}, \"body\": {\"name\": \"a\"}}\r\n")))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	head := `{"msgid": 1}`
+	if binary.LittleEndian.Uint32(data[0:4]) != uint32(len(head)) || string(data[4:]) != head+`{"name": "a"}` {
+		t.Errorf("fail: decode app data %q", data)
+	}
+
+	line, err := codec.Encode(nil, data)
+	if err != nil || string(line) != `{"head":{"msgid": 1},"body":{"name": "a"}}`+"\n" {
+		t.Errorf("fail: encode line %q err %v", line, err)
+	}
+
+	if _, err := codec.Decode(newBufConn([]byte(`{"body": {}}` + "\n"))); !errors.Is(err, ErrBadJSONLine) {
+		t.Errorf("fail: expect bad line, got %v", err)
+	}
+
+	if _, err := codec.Decode(newBufConn(bytes.Repeat([]byte("x"), 100))); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("fail: expect line too long, got %v", err)
+	}
+}
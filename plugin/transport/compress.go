@@ -0,0 +1,250 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"go.uber.org/atomic"
+)
+
+// compress type, 保存在PreHead.Flags的低2位.
+const (
+	COMPRESS_NONE   = 0
+	COMPRESS_SNAPPY = 1
+	COMPRESS_ZSTD   = 2
+	COMPRESS_LZ4    = 3
+)
+
+var (
+	ErrCompressNotSupport = errors.New("transport: compress type not support")
+	ErrDecompressFailed   = errors.New("transport: decompress failed")
+)
+
+// Compressor 压缩算法.
+type Compressor interface {
+	// MaxCompressedLen 返回长度为n的数据压缩后的最大长度.
+	MaxCompressedLen(n int) int
+	// Compress 压缩src到dst, dst长度不小于MaxCompressedLen, 返回0表示数据不可压缩.
+	Compress(dst []byte, src []byte) (int, error)
+	// Decompress 解压src到dst, dst长度即为原始数据长度, maxMemory为解压允许使用的内存上限.
+	Decompress(dst []byte, src []byte, maxMemory uint64) error
+}
+
+// CompressStatsHook 每压缩一帧回调一次, 用于接入业务的监控系统, 在发送协程中执行.
+type CompressStatsHook func(rawSize int, compressedSize int, total CompressStats)
+
+type CompressStats struct {
+	Frames          int64
+	RawBytes        int64
+	CompressedBytes int64
+}
+
+// Ratio 压缩后与压缩前的字节比例.
+func (s CompressStats) Ratio() float64 {
+	if s.RawBytes == 0 {
+		return 1
+	}
+	return float64(s.CompressedBytes) / float64(s.RawBytes)
+}
+
+var (
+	_compressors = map[uint8]Compressor{
+		COMPRESS_SNAPPY: &snappyCompressor{},
+		COMPRESS_ZSTD:   newZstdCompressor(),
+		COMPRESS_LZ4:    &lz4Compressor{},
+	}
+	_compressNames = map[string]uint8{
+		"":       COMPRESS_NONE,
+		"none":   COMPRESS_NONE,
+		"snappy": COMPRESS_SNAPPY,
+		"zstd":   COMPRESS_ZSTD,
+		"lz4":    COMPRESS_LZ4,
+	}
+
+	_compressFrames          atomic.Int64
+	_compressRawBytes        atomic.Int64
+	_compressCompressedBytes atomic.Int64
+	_compressStatsHook       atomic.Value
+)
+
+// GetCompressType 根据配置中的名字获取压缩类型.
+func GetCompressType(name string) (uint8, error) {
+	t, ok := _compressNames[name]
+	if !ok {
+		return COMPRESS_NONE, fmt.Errorf("%w: %s", ErrCompressNotSupport, name)
+	}
+	return t, nil
+}
+
+// GetCompressStats 获取发送方向的压缩统计.
+func GetCompressStats() CompressStats {
+	return CompressStats{
+		Frames:          _compressFrames.Load(),
+		RawBytes:        _compressRawBytes.Load(),
+		CompressedBytes: _compressCompressedBytes.Load(),
+	}
+}
+
+// SetCompressStatsHook 设置压缩统计回调, 为nil时取消.
+func SetCompressStatsHook(hook CompressStatsHook) {
+	_compressStatsHook.Store(hook)
+}
+
+func getCompressor(t uint8) (Compressor, error) {
+	c, ok := _compressors[t]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrCompressNotSupport, t)
+	}
+	return c, nil
+}
+
+// addCompressStats 累计压缩统计, 设置了回调时同时通知.
+func addCompressStats(rawSize int, compressedSize int) {
+	total := CompressStats{
+		Frames:          _compressFrames.Inc(),
+		RawBytes:        _compressRawBytes.Add(int64(rawSize)),
+		CompressedBytes: _compressCompressedBytes.Add(int64(compressedSize)),
+	}
+
+	if hook, _ := _compressStatsHook.Load().(CompressStatsHook); hook != nil {
+		hook(rawSize, compressedSize, total)
+	}
+}
+
+//=====================================================
+
+type snappyCompressor struct {
+}
+
+func (*snappyCompressor) MaxCompressedLen(n int) int {
+	return snappy.MaxEncodedLen(n)
+}
+
+func (*snappyCompressor) Compress(dst []byte, src []byte) (int, error) {
+	return len(snappy.Encode(dst, src)), nil
+}
+
+func (*snappyCompressor) Decompress(dst []byte, src []byte, maxMemory uint64) error {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecompressFailed, err)
+	}
+
+	if n != len(dst) {
+		return fmt.Errorf("%w: size %d expect %d", ErrDecompressFailed, n, len(dst))
+	}
+
+	_, err = snappy.Decode(dst, src)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecompressFailed, err)
+	}
+
+	return nil
+}
+
+//=====================================================
+
+// zstdCompressor decoder的内存上限在创建时指定, 按codec配置的上限分别创建.
+// encoder和decoder按GOMAXPROCS创建内部实例, EncodeAll/DecodeAll可以被多个连接并发调用.
+type zstdCompressor struct {
+	encoder  *zstd.Encoder
+	mutex    sync.RWMutex
+	decoders map[uint64]*zstd.Decoder
+}
+
+func newZstdCompressor() *zstdCompressor {
+	encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(runtime.GOMAXPROCS(0)))
+
+	return &zstdCompressor{
+		encoder:  encoder,
+		decoders: make(map[uint64]*zstd.Decoder),
+	}
+}
+
+func (c *zstdCompressor) getDecoder(maxMemory uint64) (*zstd.Decoder, error) {
+	c.mutex.RLock()
+	decoder, ok := c.decoders[maxMemory]
+	c.mutex.RUnlock()
+
+	if ok {
+		return decoder, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	decoder, ok = c.decoders[maxMemory]
+	if ok {
+		return decoder, nil
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(runtime.GOMAXPROCS(0)), zstd.WithDecoderMaxMemory(maxMemory))
+	if err != nil {
+		return nil, err
+	}
+
+	c.decoders[maxMemory] = decoder
+	return decoder, nil
+}
+
+func (*zstdCompressor) MaxCompressedLen(n int) int {
+	return n + n>>8 + 64
+}
+
+func (c *zstdCompressor) Compress(dst []byte, src []byte) (int, error) {
+	out := c.encoder.EncodeAll(src, dst[:0])
+	if len(out) > len(dst) {
+		return 0, nil
+	}
+
+	return len(out), nil
+}
+
+func (c *zstdCompressor) Decompress(dst []byte, src []byte, maxMemory uint64) error {
+	decoder, err := c.getDecoder(maxMemory)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecompressFailed, err)
+	}
+
+	out, err := decoder.DecodeAll(src, dst[:0])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecompressFailed, err)
+	}
+
+	if len(out) != len(dst) {
+		return fmt.Errorf("%w: size %d expect %d", ErrDecompressFailed, len(out), len(dst))
+	}
+
+	return nil
+}
+
+//=====================================================
+
+type lz4Compressor struct {
+}
+
+func (*lz4Compressor) MaxCompressedLen(n int) int {
+	return lz4.CompressBlockBound(n)
+}
+
+func (*lz4Compressor) Compress(dst []byte, src []byte) (int, error) {
+	return lz4.CompressBlock(src, dst, nil)
+}
+
+func (*lz4Compressor) Decompress(dst []byte, src []byte, maxMemory uint64) error {
+	n, err := lz4.UncompressBlock(src, dst)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecompressFailed, err)
+	}
+
+	if n != len(dst) {
+		return fmt.Errorf("%w: size %d expect %d", ErrDecompressFailed, n, len(dst))
+	}
+
+	return nil
+}
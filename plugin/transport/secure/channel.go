@@ -0,0 +1,382 @@
+// Package secure 客户端加密通道, X25519密钥交换 + AES-256-GCM逐包加密.
+// 服务器持有长期静态密钥, 客户端预置其公钥, 握手同时使用临时密钥和静态密钥派生会话密钥,
+// 不持有静态私钥的中间人无法完成握手.
+package secure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// record type.
+const (
+	RECORD_CLIENT_HELLO = 1
+	RECORD_SERVER_HELLO = 2
+	RECORD_DATA         = 3
+)
+
+const (
+	DefaultRekeyPackets = 1 << 20
+	PublicKeySize       = 32
+
+	_keySize      = 32
+	_pubKeySize   = PublicKeySize
+	_finishedSize = sha256.Size
+	_seqSize      = 8
+	_dataHeadSize = 1 + _seqSize
+)
+
+var (
+	ErrHandshakeFailed  = errors.New("secure: handshake failed")
+	ErrHandshakeNotDone = errors.New("secure: handshake not done")
+	ErrBadRecord        = errors.New("secure: bad record")
+	ErrReplay           = errors.New("secure: unexpected sequence")
+	ErrAuthFailed       = errors.New("secure: message authentication failed")
+	ErrBadStaticKey     = errors.New("secure: bad static key")
+)
+
+// client hello: |type(1)|client pubkey(32)|
+// server hello: |type(1)|server ephemeral pubkey(32)|rekeyPackets(4)|finished(32)|
+// data:         |type(1)|seq(8)|aes-gcm(plaintext)|
+
+// halfChannel 单方向的加密状态, 每rekeyPackets个包根据当前密钥派生新密钥.
+type halfChannel struct {
+	mutex        sync.Mutex
+	key          []byte
+	aead         cipher.AEAD
+	seq          uint64
+	rekeyPackets uint64
+	direction    byte
+}
+
+func newHalfChannel(key []byte, rekeyPackets uint64, direction byte) (*halfChannel, error) {
+	h := &halfChannel{
+		key:          key,
+		rekeyPackets: rekeyPackets,
+		direction:    direction,
+	}
+
+	err := h.resetAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *halfChannel) resetAEAD() error {
+	block, err := aes.NewCipher(h.key)
+	if err != nil {
+		return err
+	}
+
+	h.aead, err = cipher.NewGCM(block)
+	return err
+}
+
+// advance 序号加一, 到达换钥间隔时派生新密钥.
+func (h *halfChannel) advance() error {
+	h.seq++
+
+	if h.rekeyPackets > 0 && h.seq%h.rekeyPackets == 0 {
+		h.key = hkdfExpand(h.key, []byte("mango rekey"), _keySize)
+		return h.resetAEAD()
+	}
+
+	return nil
+}
+
+func (h *halfChannel) nonce(seq uint64) []byte {
+	nonce := make([]byte, h.aead.NonceSize())
+	nonce[0] = h.direction
+	binary.BigEndian.PutUint64(nonce[len(nonce)-_seqSize:], seq)
+	return nonce
+}
+
+// Channel 握手完成后的双向加密通道.
+type Channel struct {
+	send *halfChannel
+	recv *halfChannel
+}
+
+// Seal 加密应用层数据, 返回data record.
+func (ch *Channel) Seal(plaintext []byte) ([]byte, error) {
+	h := ch.send
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	record := make([]byte, _dataHeadSize, _dataHeadSize+len(plaintext)+h.aead.Overhead())
+	record[0] = RECORD_DATA
+	binary.BigEndian.PutUint64(record[1:_dataHeadSize], h.seq)
+
+	record = h.aead.Seal(record, h.nonce(h.seq), plaintext, record[:_dataHeadSize])
+
+	err := h.advance()
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// Open 解密data record, 序号必须严格递增以防重放.
+func (ch *Channel) Open(record []byte) ([]byte, error) {
+	if len(record) < _dataHeadSize || record[0] != RECORD_DATA {
+		return nil, fmt.Errorf("%w: data record len %d", ErrBadRecord, len(record))
+	}
+
+	h := ch.recv
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	seq := binary.BigEndian.Uint64(record[1:_dataHeadSize])
+	if seq != h.seq {
+		return nil, fmt.Errorf("%w: seq %d expect %d", ErrReplay, seq, h.seq)
+	}
+
+	plaintext, err := h.aead.Open(nil, h.nonce(seq), record[_dataHeadSize:], record[:_dataHeadSize])
+	if err != nil {
+		return nil, fmt.Errorf("%w: seq %d", ErrAuthFailed, seq)
+	}
+
+	err = h.advance()
+	if err != nil {
+		return nil, err
+	}
+
+	return plaintext, nil
+}
+
+//=====================================================
+
+// StaticKey 服务器长期密钥对, 公钥预置在客户端用于认证服务器.
+type StaticKey struct {
+	priv []byte
+	pub  []byte
+}
+
+// GenerateStaticKey 生成新的服务器静态密钥.
+func GenerateStaticKey() (*StaticKey, error) {
+	priv, pub, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StaticKey{priv: priv, pub: pub}, nil
+}
+
+// NewStaticKey 从私钥恢复服务器静态密钥.
+func NewStaticKey(priv []byte) (*StaticKey, error) {
+	if len(priv) != curve25519.ScalarSize {
+		return nil, fmt.Errorf("%w: private key len %d", ErrBadStaticKey, len(priv))
+	}
+
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadStaticKey, err)
+	}
+
+	return &StaticKey{priv: append([]byte(nil), priv...), pub: pub}, nil
+}
+
+func (k *StaticKey) PrivateKey() []byte {
+	return k.priv
+}
+
+// PublicKey 需要分发给客户端的公钥.
+func (k *StaticKey) PublicKey() []byte {
+	return k.pub
+}
+
+// ClientHandshake 客户端握手状态.
+type ClientHandshake struct {
+	priv      []byte
+	pub       []byte
+	serverPub []byte
+}
+
+// NewClientHandshake 生成客户端密钥对, 返回需要发送给服务器的client hello.
+// serverPub为预置的服务器静态公钥.
+func NewClientHandshake(serverPub []byte) (*ClientHandshake, []byte, error) {
+	if len(serverPub) != _pubKeySize {
+		return nil, nil, fmt.Errorf("%w: public key len %d", ErrBadStaticKey, len(serverPub))
+	}
+
+	priv, pub, err := generateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hello := make([]byte, 0, 1+_pubKeySize)
+	hello = append(hello, RECORD_CLIENT_HELLO)
+	hello = append(hello, pub...)
+
+	hs := &ClientHandshake{
+		priv:      priv,
+		pub:       pub,
+		serverPub: append([]byte(nil), serverPub...),
+	}
+
+	return hs, hello, nil
+}
+
+// Finish 处理server hello, 校验服务器持有静态私钥后生成加密通道.
+func (hs *ClientHandshake) Finish(serverHello []byte) (*Channel, error) {
+	if len(serverHello) != 1+_pubKeySize+4+_finishedSize || serverHello[0] != RECORD_SERVER_HELLO {
+		return nil, fmt.Errorf("%w: bad server hello len %d", ErrHandshakeFailed, len(serverHello))
+	}
+
+	serverEph := serverHello[1 : 1+_pubKeySize]
+	rekey := serverHello[1+_pubKeySize : 1+_pubKeySize+4]
+	finished := serverHello[1+_pubKeySize+4:]
+
+	ee, err := curve25519.X25519(hs.priv, serverEph)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+	}
+
+	es, err := curve25519.X25519(hs.priv, hs.serverPub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+	}
+
+	keys := deriveKeys(ee, es, hs.pub, serverEph, hs.serverPub, rekey)
+	if !hmac.Equal(finished, keys.finished) {
+		return nil, fmt.Errorf("%w: server not authenticated", ErrHandshakeFailed)
+	}
+
+	return newChannel(keys.c2s, keys.s2c, uint64(binary.BigEndian.Uint32(rekey)), 'c', 's')
+}
+
+// serverHandshake 处理client hello, 返回server hello和加密通道.
+func serverHandshake(clientHello []byte, static *StaticKey, rekeyPackets uint32) ([]byte, *Channel, error) {
+	if len(clientHello) != 1+_pubKeySize || clientHello[0] != RECORD_CLIENT_HELLO {
+		return nil, nil, fmt.Errorf("%w: bad client hello len %d", ErrHandshakeFailed, len(clientHello))
+	}
+
+	clientPub := clientHello[1:]
+
+	priv, pub, err := generateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ee, err := curve25519.X25519(priv, clientPub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+	}
+
+	es, err := curve25519.X25519(static.priv, clientPub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+	}
+
+	hello := make([]byte, 1+_pubKeySize+4, 1+_pubKeySize+4+_finishedSize)
+	hello[0] = RECORD_SERVER_HELLO
+	copy(hello[1:], pub)
+	binary.BigEndian.PutUint32(hello[1+_pubKeySize:], rekeyPackets)
+
+	keys := deriveKeys(ee, es, clientPub, pub, static.pub, hello[1+_pubKeySize:])
+	hello = append(hello, keys.finished...)
+
+	ch, err := newChannel(keys.s2c, keys.c2s, uint64(rekeyPackets), 's', 'c')
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hello, ch, nil
+}
+
+// generateKey 生成X25519密钥对.
+func generateKey() ([]byte, []byte, error) {
+	priv := make([]byte, curve25519.ScalarSize)
+	_, err := rand.Read(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return priv, pub, nil
+}
+
+func newChannel(sendKey []byte, recvKey []byte, rekeyPackets uint64, sendDir byte, recvDir byte) (*Channel, error) {
+	send, err := newHalfChannel(sendKey, rekeyPackets, sendDir)
+	if err != nil {
+		return nil, err
+	}
+
+	recv, err := newHalfChannel(recvKey, rekeyPackets, recvDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Channel{send: send, recv: recv}, nil
+}
+
+type handshakeKeys struct {
+	c2s      []byte
+	s2c      []byte
+	finished []byte
+}
+
+// deriveKeys 根据临时密钥和静态密钥的两个ECDH结果派生两个方向的密钥,
+// finished是服务器对握手内容的MAC, 只有持有静态私钥才能算出.
+func deriveKeys(ee []byte, es []byte, clientPub []byte, serverEph []byte, serverPub []byte, rekey []byte) handshakeKeys {
+	salt := make([]byte, 0, 3*_pubKeySize)
+	salt = append(salt, clientPub...)
+	salt = append(salt, serverEph...)
+	salt = append(salt, serverPub...)
+
+	ikm := make([]byte, 0, len(ee)+len(es))
+	ikm = append(ikm, ee...)
+	ikm = append(ikm, es...)
+
+	prk := hkdfExtract(salt, ikm)
+
+	mac := hmac.New(sha256.New, hkdfExpand(prk, []byte("mango server finished"), _keySize))
+	mac.Write(salt)
+	mac.Write(rekey)
+
+	return handshakeKeys{
+		c2s:      hkdfExpand(prk, []byte("mango c2s"), _keySize),
+		s2c:      hkdfExpand(prk, []byte("mango s2c"), _keySize),
+		finished: mac.Sum(nil),
+	}
+}
+
+func hkdfExtract(salt []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+func hkdfExpand(prk []byte, info []byte, length int) []byte {
+	var (
+		result []byte
+		prev   []byte
+	)
+
+	for counter := byte(1); len(result) < length; counter++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		prev = mac.Sum(nil)
+		result = append(result, prev...)
+	}
+
+	return result[:length]
+}
@@ -0,0 +1,106 @@
+package secure
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newTestChannels(t *testing.T, rekeyPackets uint32) (*Channel, *Channel) {
+	static, err := GenerateStaticKey()
+	if err != nil {
+		t.Fatalf("generate static key failed: %v", err)
+	}
+
+	hs, clientHello, err := NewClientHandshake(static.PublicKey())
+	if err != nil {
+		t.Fatalf("client handshake failed: %v", err)
+	}
+
+	serverHello, server, err := serverHandshake(clientHello, static, rekeyPackets)
+	if err != nil {
+		t.Fatalf("server handshake failed: %v", err)
+	}
+
+	client, err := hs.Finish(serverHello)
+	if err != nil {
+		t.Fatalf("client finish failed: %v", err)
+	}
+
+	return client, server
+}
+
+func TestChannelSealOpen(t *testing.T) {
+	client, server := newTestChannels(t, 3)
+
+	for i := 0; i < 10; i++ {
+		plaintext := []byte("hello mango")
+
+		record, err := client.Seal(plaintext)
+		if err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+
+		opened, err := server.Open(record)
+		if err != nil {
+			t.Fatalf("open %d failed: %v", i, err)
+		}
+
+		if !bytes.Equal(opened, plaintext) {
+			t.Errorf("fail: plaintext mismatch")
+		}
+
+		record, _ = server.Seal(plaintext)
+		if _, err := client.Open(record); err != nil {
+			t.Fatalf("client open %d failed: %v", i, err)
+		}
+	}
+}
+
+func TestChannelReplayAndTamper(t *testing.T) {
+	client, server := newTestChannels(t, 0)
+
+	record, _ := client.Seal([]byte("first"))
+	if _, err := server.Open(record); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	if _, err := server.Open(record); !errors.Is(err, ErrReplay) {
+		t.Errorf("fail: expect replay, got %v", err)
+	}
+
+	record, _ = client.Seal([]byte("second"))
+	record[len(record)-1] ^= 0xFF
+	if _, err := server.Open(record); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("fail: expect auth failed, got %v", err)
+	}
+}
+
+func TestHandshakeServerAuth(t *testing.T) {
+	static, _ := GenerateStaticKey()
+	mitm, _ := GenerateStaticKey()
+
+	restored, err := NewStaticKey(static.PrivateKey())
+	if err != nil || !bytes.Equal(restored.PublicKey(), static.PublicKey()) {
+		t.Fatalf("fail: restore static key %v", err)
+	}
+
+	// 中间人用自己的静态密钥应答, 客户端预置的是真实服务器公钥
+	hs, clientHello, _ := NewClientHandshake(static.PublicKey())
+	serverHello, _, err := serverHandshake(clientHello, mitm, 0)
+	if err != nil {
+		t.Fatalf("server handshake failed: %v", err)
+	}
+
+	if _, err := hs.Finish(serverHello); !errors.Is(err, ErrHandshakeFailed) {
+		t.Errorf("fail: expect handshake failed, got %v", err)
+	}
+
+	// 篡改rekeyPackets
+	hs, clientHello, _ = NewClientHandshake(static.PublicKey())
+	serverHello, _, _ = serverHandshake(clientHello, static, 100)
+	serverHello[1+_pubKeySize+3] ^= 0x01
+	if _, err := hs.Finish(serverHello); !errors.Is(err, ErrHandshakeFailed) {
+		t.Errorf("fail: expect tampered hello rejected, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package secure
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+)
+
+// Codec 在内层codec之上提供加密通道, 对server_base/msg透明.
+// 内层app data的headerSize固定为0, body为record.
+type Codec struct {
+	inner        transport.Codec
+	static       *StaticKey
+	rekeyPackets uint32
+}
+
+type channelCtxKey struct{}
+
+// NewCodec 创建加密codec, static为服务器静态密钥, rekeyPackets为0时使用DefaultRekeyPackets.
+func NewCodec(inner transport.Codec, static *StaticKey, rekeyPackets uint32) *Codec {
+	if rekeyPackets == 0 {
+		rekeyPackets = DefaultRekeyPackets
+	}
+
+	return &Codec{
+		inner:        inner,
+		static:       static,
+		rekeyPackets: rekeyPackets,
+	}
+}
+
+// Inner 返回内层codec.
+func (s *Codec) Inner() transport.Codec {
+	return s.inner
+}
+
+// WrapRecord 将record包装为内层codec的app data.
+func WrapRecord(record []byte) []byte {
+	data := make([]byte, 4+len(record))
+	copy(data[4:], record)
+	return data
+}
+
+// UnwrapRecord 从内层codec的app data中取出record.
+func UnwrapRecord(data []byte) ([]byte, error) {
+	if len(data) < 4 || binary.LittleEndian.Uint32(data[0:4]) != 0 {
+		return nil, fmt.Errorf("%w: app data len %d", ErrBadRecord, len(data))
+	}
+	return data[4:], nil
+}
+
+func getChannel(c transport.Conn) (*Channel, error) {
+	ctxConn, ok := c.(transport.ContextConn)
+	if !ok {
+		return nil, ErrHandshakeNotDone
+	}
+
+	ch, ok := ctxConn.GetContext(channelCtxKey{}).(*Channel)
+	if !ok {
+		return nil, ErrHandshakeNotDone
+	}
+
+	return ch, nil
+}
+
+// Handshake 等待client hello并回复server hello.
+func (s *Codec) Handshake(c transport.Conn, write func(data []byte) error) error {
+	ctxConn, ok := c.(transport.ContextConn)
+	if !ok {
+		return fmt.Errorf("%w: conn not support context", ErrHandshakeFailed)
+	}
+
+	data, err := s.inner.Decode(c)
+	if err != nil {
+		return err
+	}
+
+	record, err := UnwrapRecord(data)
+	if err != nil {
+		return err
+	}
+
+	hello, ch, err := serverHandshake(record, s.static, s.rekeyPackets)
+	if err != nil {
+		return err
+	}
+
+	frame, err := s.inner.Encode(c, WrapRecord(hello))
+	if err != nil {
+		return err
+	}
+
+	err = write(frame)
+	if err != nil {
+		return err
+	}
+
+	ctxConn.SetContext(channelCtxKey{}, ch)
+
+	return nil
+}
+
+// ClientHandshake 主动连接对端时以客户端身份握手, serverPub为对端的静态公钥.
+func (s *Codec) ClientHandshake(c transport.Conn, write func(data []byte) error, serverPub []byte) error {
+	ctxConn, ok := c.(transport.ContextConn)
+	if !ok {
+		return fmt.Errorf("%w: conn not support context", ErrHandshakeFailed)
+	}
+
+	hs, hello, err := NewClientHandshake(serverPub)
+	if err != nil {
+		return err
+	}
+
+	frame, err := s.inner.Encode(c, WrapRecord(hello))
+	if err != nil {
+		return err
+	}
+
+	err = write(frame)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.inner.Decode(c)
+	if err != nil {
+		return err
+	}
+
+	record, err := UnwrapRecord(data)
+	if err != nil {
+		return err
+	}
+
+	ch, err := hs.Finish(record)
+	if err != nil {
+		return err
+	}
+
+	ctxConn.SetContext(channelCtxKey{}, ch)
+
+	return nil
+}
+
+func (s *Codec) Encode(c transport.Conn, buff []byte) ([]byte, error) {
+	ch, err := getChannel(c)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := ch.Seal(buff)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.inner.Encode(c, WrapRecord(record))
+}
+
+func (s *Codec) Decode(c transport.Conn) ([]byte, error) {
+	ch, err := getChannel(c)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.inner.Decode(c)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := UnwrapRecord(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return ch.Open(record)
+}
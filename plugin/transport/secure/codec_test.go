@@ -0,0 +1,85 @@
+package secure
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+)
+
+type pipeConn struct {
+	net.Conn
+	ctx map[interface{}]interface{}
+}
+
+func (c *pipeConn) GetConnID() uint64                      { return 0 }
+func (c *pipeConn) GetLocalAddr() net.Addr                 { return c.LocalAddr() }
+func (c *pipeConn) GetRemoteAddr() net.Addr                { return c.RemoteAddr() }
+func (c *pipeConn) Send(data []byte) error                 { _, err := c.Write(data); return err }
+func (c *pipeConn) Close(active bool) error                { return c.Conn.Close() }
+func (c *pipeConn) GetContext(key interface{}) interface{} { return c.ctx[key] }
+
+func (c *pipeConn) SetContext(key interface{}, value interface{}) {
+	c.ctx[key] = value
+}
+
+func newPipeConns() (*pipeConn, *pipeConn) {
+	a, b := net.Pipe()
+	return &pipeConn{Conn: a, ctx: map[interface{}]interface{}{}}, &pipeConn{Conn: b, ctx: map[interface{}]interface{}{}}
+}
+
+func TestCodecClientHandshake(t *testing.T) {
+	static, _ := GenerateStaticKey()
+	codec := NewCodec(&transport.DefaultCodec{}, static, 0)
+
+	client, server := newPipeConns()
+	defer client.Close(true)
+	defer server.Close(true)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- codec.Handshake(server, server.Send)
+	}()
+
+	if err := codec.ClientHandshake(client, client.Send, static.PublicKey()); err != nil {
+		t.Fatalf("client handshake failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("server handshake failed: %v", err)
+	}
+
+	frame, err := codec.Encode(client, []byte("hello mango"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	go func() { _ = client.Send(frame) }()
+
+	data, err := codec.Decode(server)
+	if err != nil || !bytes.Equal(data, []byte("hello mango")) {
+		t.Errorf("fail: decode %q err %v", data, err)
+	}
+}
+
+func TestCodecClientHandshakeWrongKey(t *testing.T) {
+	static, _ := GenerateStaticKey()
+	other, _ := GenerateStaticKey()
+	codec := NewCodec(&transport.DefaultCodec{}, static, 0)
+
+	client, server := newPipeConns()
+	defer client.Close(true)
+	defer server.Close(true)
+
+	go func() { _ = codec.Handshake(server, server.Send) }()
+
+	err := codec.ClientHandshake(client, client.Send, other.PublicKey())
+	if !errors.Is(err, ErrHandshakeFailed) {
+		t.Errorf("fail: expect handshake failed, got %v", err)
+	}
+
+	if _, err := codec.Encode(client, []byte("x")); !errors.Is(err, ErrHandshakeNotDone) {
+		t.Errorf("fail: expect handshake not done, got %v", err)
+	}
+}
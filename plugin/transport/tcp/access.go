@@ -0,0 +1,82 @@
+package tcp
+
+import (
+	"net"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"go.uber.org/atomic"
+)
+
+const (
+	_defaultRejectLogSample = 100
+)
+
+// reject reason.
+const (
+	REJECT_REASON_DENY = iota
+	REJECT_REASON_NOT_ALLOW
+	REJECT_REASON_BANNED
+	REJECT_REASON_MAX
+)
+
+var _rejectReasonNames = [REJECT_REASON_MAX]string{
+	REJECT_REASON_DENY:      "deny",
+	REJECT_REASON_NOT_ALLOW: "not_allow",
+	REJECT_REASON_BANNED:    "banned",
+}
+
+type rejectStats struct {
+	counts [REJECT_REASON_MAX]atomic.Uint64
+}
+
+// RejectStats 各原因拒绝的连接数.
+type RejectStats struct {
+	Deny     uint64
+	NotAllow uint64
+	Banned   uint64
+}
+
+// GetRejectStats 获取accept时拒绝连接的统计.
+func (l *TcpListener) GetRejectStats() RejectStats {
+	return RejectStats{
+		Deny:     l.rejectStats.counts[REJECT_REASON_DENY].Load(),
+		NotAllow: l.rejectStats.counts[REJECT_REASON_NOT_ALLOW].Load(),
+		Banned:   l.rejectStats.counts[REJECT_REASON_BANNED].Load(),
+	}
+}
+
+// isProxied 连接是否来自可信代理, 需要解析PROXY头后才能拿到真实地址.
+func (l *TcpListener) isProxied(ip net.IP) bool {
+	return l.getCfg().ProxyProtocol && containsIP(l.getTrustedProxies(), ip)
+}
+
+// checkAccess 按deny、allow、封禁表依次检查, 非ip地址不做限制.
+func (l *TcpListener) checkAccess(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+
+	cfg := l.getNetCfg()
+
+	reason := -1
+	switch {
+	case containsIP(cfg.deny, ip):
+		reason = REJECT_REASON_DENY
+	case len(cfg.allow) > 0 && !containsIP(cfg.allow, ip):
+		reason = REJECT_REASON_NOT_ALLOW
+	case transport.IsIPBanned(ip):
+		reason = REJECT_REASON_BANNED
+	}
+
+	if reason < 0 {
+		return true
+	}
+
+	count := l.rejectStats.counts[reason].Inc()
+	if cfg.logSample <= 1 || count%cfg.logSample == 1 {
+		log.Info("listener %s reject %s for %s, total %d", l.name, ip.String(), _rejectReasonNames[reason], count)
+	}
+
+	return false
+}
@@ -0,0 +1,95 @@
+package tcp
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+)
+
+type quietLogger struct {
+	log.MockLogger
+}
+
+func (*quietLogger) GetLevel() int {
+	return log.LogLevelFatal + 1
+}
+
+func TestMain(m *testing.M) {
+	log.SetLogger(&quietLogger{})
+	os.Exit(m.Run())
+}
+
+func TestCheckAccess(t *testing.T) {
+	trans, _ := NewTcpTransport(&TcpTransportCfg{
+		TcpListenerCfg: TcpListenerCfg{
+			AllowCidrs: []string{"10.0.0.0/8"},
+			DenyCidrs:  []string{"10.1.0.0/16"},
+		},
+	})
+
+	l := trans.GetListener(DefaultListenerName)
+	err := l.applyNetCfg(l.getCfg())
+	if err != nil {
+		t.Fatalf("apply net cfg failed: %v", err)
+	}
+
+	cases := []struct {
+		ip     string
+		expect bool
+	}{
+		{"10.0.0.1", true},
+		{"10.1.2.3", false},
+		{"192.168.0.1", false},
+	}
+
+	for _, c := range cases {
+		if l.checkAccess(net.ParseIP(c.ip)) != c.expect {
+			t.Errorf("fail: ip %s expect %v", c.ip, c.expect)
+		}
+	}
+
+	ip := net.ParseIP("10.0.0.2")
+	transport.BanIP(ip, time.Minute)
+	if l.checkAccess(ip) {
+		t.Errorf("fail: banned ip accepted")
+	}
+
+	transport.UnbanIP(ip)
+	if !l.checkAccess(ip) {
+		t.Errorf("fail: unbanned ip rejected")
+	}
+
+	stats := l.GetRejectStats()
+	if stats.Deny != 1 || stats.NotAllow != 1 || stats.Banned != 1 {
+		t.Errorf("fail: stats %+v", stats)
+	}
+
+	// reload后规则立即生效
+	err = trans.SetConfig(&TcpTransportCfg{})
+	if err != nil {
+		t.Fatalf("set config failed: %v", err)
+	}
+
+	if !l.checkAccess(net.ParseIP("192.168.0.1")) {
+		t.Errorf("fail: reload allow list not applied")
+	}
+}
+
+func TestBanExpire(t *testing.T) {
+	ip := net.ParseIP("10.0.0.3")
+	transport.BanIP(ip, 10*time.Millisecond)
+
+	if !transport.IsIPBanned(ip) {
+		t.Errorf("fail: ip not banned")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if transport.IsIPBanned(ip) {
+		t.Errorf("fail: ban not expired")
+	}
+}
@@ -0,0 +1,317 @@
+package tcp
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/plugin/transport/secure"
+)
+
+const (
+	_defaultMinBackoffMs  = 100
+	_defaultMaxBackoffMs  = 30 * 1000
+	_defaultDialTimeoutMs = 3 * 1000
+	_defaultQueueSize     = 1024
+	_backoffJitter        = 0.2
+)
+
+var (
+	ErrConnectorQueueFull = errors.New("tcp connector: send queue full")
+	ErrConnectorClosed    = errors.New("tcp connector: closed")
+)
+
+type TcpConnectorCfg struct {
+	Name          string `mapstructure:"name"`
+	Addr          string `mapstructure:"addr"`
+	Network       string `mapstructure:"network"`
+	MinBackoffMs  uint32 `mapstructure:"min_backoff_ms"`
+	MaxBackoffMs  uint32 `mapstructure:"max_backoff_ms"`
+	DialTimeoutMs uint32 `mapstructure:"dial_timeout_ms"`
+	QueueSize     int    `mapstructure:"queue_size"`
+	// 连接使用该监听器的codec和事件处理, 为空时使用顶层配置的监听器
+	Listener string `mapstructure:"listener"`
+	// 对端静态公钥(hex), 监听器开启secure时必填
+	ServerKey string `mapstructure:"server_key"`
+}
+
+// TcpConnector 主动连接对端, 断线后按指数退避加随机抖动重连.
+// 断线期间Send的数据进入有界队列, 连接建立后按序发出.
+type TcpConnector struct {
+	cfg      TcpConnectorCfg
+	trans    *TcpTransport
+	listener *TcpListener
+	mutex    sync.Mutex
+	conn     *tcpConn
+	queue    [][]byte
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	// 对端静态公钥, 监听器未开启secure时为nil
+	serverKey []byte
+	// 重连抖动的随机源, 每个连接器独立播种, 只在run协程中使用
+	rnd *rand.Rand
+}
+
+func newTcpConnector(ctx context.Context, t *TcpTransport, l *TcpListener, cfg *TcpConnectorCfg) *TcpConnector {
+	c := &TcpConnector{
+		cfg:      *cfg,
+		trans:    t,
+		listener: l,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	if c.cfg.Network == "" {
+		c.cfg.Network = "tcp"
+	}
+
+	if c.cfg.MinBackoffMs == 0 {
+		c.cfg.MinBackoffMs = _defaultMinBackoffMs
+	}
+
+	if c.cfg.MaxBackoffMs < c.cfg.MinBackoffMs {
+		c.cfg.MaxBackoffMs = _defaultMaxBackoffMs
+	}
+
+	if c.cfg.DialTimeoutMs == 0 {
+		c.cfg.DialTimeoutMs = _defaultDialTimeoutMs
+	}
+
+	if c.cfg.QueueSize <= 0 {
+		c.cfg.QueueSize = _defaultQueueSize
+	}
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	return c
+}
+
+func (c *TcpConnector) GetName() string {
+	return c.cfg.Name
+}
+
+func (c *TcpConnector) GetAddr() string {
+	return c.cfg.Addr
+}
+
+// GetConn 获取当前连接, 未连接时返回nil.
+func (c *TcpConnector) GetConn() transport.Conn {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn
+}
+
+func (c *TcpConnector) IsConnected() bool {
+	return c.GetConn() != nil
+}
+
+// Send 已连接时直接发送, 否则进入发送队列, data在调用后不能再修改.
+func (c *TcpConnector) Send(data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	select {
+	case <-c.ctx.Done():
+		return ErrConnectorClosed
+	default:
+	}
+
+	if c.conn != nil {
+		return c.conn.Send(data)
+	}
+
+	if len(c.queue) >= c.cfg.QueueSize {
+		return fmt.Errorf("%w: connector %s size %d", ErrConnectorQueueFull, c.cfg.Name, len(c.queue))
+	}
+
+	c.queue = append(c.queue, data)
+	return nil
+}
+
+// Close 停止重连并关闭当前连接.
+func (c *TcpConnector) Close() {
+	c.cancel()
+
+	conn := c.GetConn()
+	if conn != nil {
+		_ = conn.Close(true)
+	}
+}
+
+func (c *TcpConnector) run() {
+	backoff := time.Duration(c.cfg.MinBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(c.cfg.MaxBackoffMs) * time.Millisecond
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		default:
+		}
+
+		conn, err := c.dial()
+		if err == nil {
+			backoff = time.Duration(c.cfg.MinBackoffMs) * time.Millisecond
+			c.serve(conn)
+		} else {
+			log.Error("connector %s dial %s failed for %s", c.cfg.Name, c.cfg.Addr, err.Error())
+		}
+
+		wait := c.jitter(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// jitter 在退避时间上加减随机抖动, 避免大量连接器同时重连.
+func (c *TcpConnector) jitter(d time.Duration) time.Duration {
+	delta := (c.rnd.Float64()*2 - 1) * _backoffJitter * float64(d)
+	return d + time.Duration(delta)
+}
+
+func (c *TcpConnector) dial() (net.Conn, error) {
+	dialer := net.Dialer{Timeout: time.Duration(c.cfg.DialTimeoutMs) * time.Millisecond}
+
+	conn, err := dialer.DialContext(c.ctx, c.cfg.Network, c.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	setSockBuffer(conn)
+
+	return conn, nil
+}
+
+// handshake 监听器使用加密codec时以客户端身份握手, 完成前不发送任何数据.
+func (c *TcpConnector) handshake(tc *tcpConn) error {
+	codec, ok := tc.codec.(*secure.Codec)
+	if !ok {
+		return nil
+	}
+
+	return tc.withHandshakeDeadline(func() error {
+		return codec.ClientHandshake(tc, tc.writeRaw, c.serverKey)
+	})
+}
+
+// serve 发送积压队列后进入收包循环, 连接断开后返回.
+func (c *TcpConnector) serve(conn net.Conn) {
+	tc := NewTcpConn(c.ctx, c.listener, conn)
+	tc.outbound = true
+
+	err := c.handshake(tc)
+	if err != nil {
+		log.Error("connector %s handshake with %s failed for %s", c.cfg.Name, c.cfg.Addr, err.Error())
+		_ = conn.Close()
+		return
+	}
+
+	c.mutex.Lock()
+	for len(c.queue) > 0 {
+		err := tc.Send(c.queue[0])
+		if err != nil {
+			c.mutex.Unlock()
+			log.Error("connector %s flush queue failed for %s", c.cfg.Name, err.Error())
+			_ = conn.Close()
+			return
+		}
+
+		c.queue[0] = nil
+		c.queue = c.queue[1:]
+	}
+	c.conn = tc
+	c.mutex.Unlock()
+
+	log.Info("connector %s connected to %s", c.cfg.Name, c.cfg.Addr)
+
+	tc.Recv()
+
+	c.mutex.Lock()
+	c.conn = nil
+	c.mutex.Unlock()
+
+	log.Info("connector %s disconnected from %s", c.cfg.Name, c.cfg.Addr)
+}
+
+// Connect 创建到对端的连接器并开始连接, name重复时返回已有连接器.
+func (t *TcpTransport) Connect(cfg *TcpConnectorCfg) (*TcpConnector, error) {
+	if t.ctx == nil {
+		return nil, errors.New("tcp transport not init")
+	}
+
+	t.connMutex.Lock()
+	defer t.connMutex.Unlock()
+
+	c, ok := t.connectors[cfg.Name]
+	if ok {
+		return c, nil
+	}
+
+	listenerName := cfg.Listener
+	if listenerName == "" {
+		listenerName = t.defaultName
+	}
+
+	l, ok := t.listeners[listenerName]
+	if !ok {
+		return nil, fmt.Errorf("connector %s listener %s not exist", cfg.Name, listenerName)
+	}
+
+	var serverKey []byte
+	if _, ok := l.GetCodec().(*secure.Codec); ok {
+		key, err := hex.DecodeString(cfg.ServerKey)
+		if err != nil || len(key) != secure.PublicKeySize {
+			return nil, fmt.Errorf("connector %s listener %s is secure, invalid server key %q", cfg.Name, listenerName, cfg.ServerKey)
+		}
+		serverKey = key
+	}
+
+	c = newTcpConnector(t.ctx, t, l, cfg)
+	c.serverKey = serverKey
+	t.connectors[cfg.Name] = c
+
+	go c.run()
+
+	log.Info("tcp connector %s start connect to %s", cfg.Name, cfg.Addr)
+
+	return c, nil
+}
+
+// GetConnector 根据名字获取连接器.
+func (t *TcpTransport) GetConnector(name string) *TcpConnector {
+	t.connMutex.Lock()
+	defer t.connMutex.Unlock()
+
+	return t.connectors[name]
+}
+
+// Disconnect 关闭并移除连接器.
+func (t *TcpTransport) Disconnect(name string) {
+	t.connMutex.Lock()
+	c, ok := t.connectors[name]
+	delete(t.connectors, name)
+	t.connMutex.Unlock()
+
+	if ok {
+		c.Close()
+	}
+}
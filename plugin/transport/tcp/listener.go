@@ -0,0 +1,383 @@
+package tcp
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/plugin/transport/secure"
+)
+
+const (
+	DefaultListenerName = "default"
+	// unix传输层顶层配置对应的监听器名字, 与tcp区分以免按名字绑定的handler和codec冲突
+	DefaultUnixListenerName = "unix"
+)
+
+// listener framing.
+const (
+	FRAMING_BINARY = "binary"
+	// 按行分隔的json, 不需要二进制帧头, msg层需要为该监听器配置json或auto codec
+	FRAMING_NDJSON = "ndjson"
+)
+
+type TcpListenerCfg struct {
+	Name          string `mapstructure:"name"`
+	Addr          string `mapstructure:"addr"`
+	Network       string `mapstructure:"network"`
+	IdleTimeout   uint32 `mapstructure:"idletimeout"`
+	MaxHeaderSize uint32 `mapstructure:"max_header_size"`
+	MaxBodySize   uint32 `mapstructure:"max_body_size"`
+
+	Compress          string `mapstructure:"compress"`
+	CompressThreshold int    `mapstructure:"compress_threshold"`
+	// 帧格式, 为空时为binary
+	Framing string `mapstructure:"framing"`
+
+	// 与compress互斥, 都需要使用默认codec
+	Secure       bool   `mapstructure:"secure"`
+	RekeyPackets uint32 `mapstructure:"rekey_packets"`
+	// 服务器静态私钥(hex), 开启secure时必填, 对应公钥需要预置在客户端
+	StaticKey string `mapstructure:"static_key"`
+
+	// unix socket only
+	Perm string `mapstructure:"perm"`
+
+	// 开启后来自ProxyTrustedCidrs的连接需要携带PROXY protocol v1/v2头
+	ProxyProtocol     bool     `mapstructure:"proxy_protocol"`
+	ProxyTrustedCidrs []string `mapstructure:"proxy_trusted_cidrs"`
+
+	// 先匹配deny再匹配allow, allow为空表示不限制
+	AllowCidrs []string `mapstructure:"allow_cidrs"`
+	DenyCidrs  []string `mapstructure:"deny_cidrs"`
+	// 拒绝连接的日志每N次打印一次, 0使用默认值
+	RejectLogSample uint32 `mapstructure:"reject_log_sample"`
+}
+
+// TcpListener 命名监听器, 持有自己的codec、空闲超时和事件处理.
+// 没有配置addr的监听器不监听端口, 仅供connector的连接绑定.
+type TcpListener struct {
+	name         string
+	network      string
+	trans        *TcpTransport
+	eventHandler transport.EventHandler
+	customCodec  transport.Codec
+	listener     net.Listener
+	cfg          atomic.Value
+	codec        atomic.Value
+	netCfg       atomic.Value
+	rejectStats  rejectStats
+}
+
+// netCfg 由配置解析出的网络规则, reload时整体替换.
+type netCfg struct {
+	trustedProxies []*net.IPNet
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	logSample      uint64
+}
+
+type codecHolder struct {
+	codec transport.Codec
+}
+
+func newTcpListener(t *TcpTransport, cfg *TcpListenerCfg) *TcpListener {
+	l := &TcpListener{
+		name:    cfg.Name,
+		network: cfg.Network,
+		trans:   t,
+	}
+
+	if l.network == "" {
+		l.network = t.network
+	}
+
+	l.cfg.Store(cfg)
+
+	return l
+}
+
+func (l *TcpListener) GetName() string {
+	return l.name
+}
+
+// GetCodec 当前codec, reload后只对新连接生效.
+func (l *TcpListener) GetCodec() transport.Codec {
+	h, _ := l.codec.Load().(*codecHolder)
+	if h == nil {
+		return transport.GetCodec()
+	}
+	return h.codec
+}
+
+func (l *TcpListener) GetEventHandler() transport.EventHandler {
+	return l.eventHandler
+}
+
+// GetAddr 实际监听的地址, 未监听时返回nil.
+func (l *TcpListener) GetAddr() net.Addr {
+	if l.listener == nil {
+		return nil
+	}
+	return l.listener.Addr()
+}
+
+func (l *TcpListener) getCfg() *TcpListenerCfg {
+	return l.cfg.Load().(*TcpListenerCfg)
+}
+
+// setConfig 替换网络规则和codec, 监听地址的变化需要重启才能生效.
+func (l *TcpListener) setConfig(cfg *TcpListenerCfg) error {
+	err := l.applyNetCfg(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = l.applyCodecCfg(cfg)
+	if err != nil {
+		return err
+	}
+
+	if l.getCfg().Addr != cfg.Addr {
+		log.Error("listener %s addr change from %s to %s need restart", l.name, l.getCfg().Addr, cfg.Addr)
+	}
+
+	l.cfg.Store(cfg)
+
+	return nil
+}
+
+func (l *TcpListener) applyNetCfg(cfg *TcpListenerCfg) error {
+	trustedProxies, err := parseCIDRs(cfg.ProxyTrustedCidrs)
+	if err != nil {
+		return fmt.Errorf("parse proxy trusted cidrs failed for %w", err)
+	}
+
+	if cfg.ProxyProtocol && len(trustedProxies) == 0 {
+		log.Error("listener %s proxy protocol enabled but no trusted cidrs", l.name)
+	}
+
+	allow, err := parseCIDRs(cfg.AllowCidrs)
+	if err != nil {
+		return fmt.Errorf("parse allow cidrs failed for %w", err)
+	}
+
+	deny, err := parseCIDRs(cfg.DenyCidrs)
+	if err != nil {
+		return fmt.Errorf("parse deny cidrs failed for %w", err)
+	}
+
+	logSample := uint64(cfg.RejectLogSample)
+	if logSample == 0 {
+		logSample = _defaultRejectLogSample
+	}
+
+	l.netCfg.Store(&netCfg{
+		trustedProxies: trustedProxies,
+		allow:          allow,
+		deny:           deny,
+		logSample:      logSample,
+	})
+
+	return nil
+}
+
+func (l *TcpListener) getNetCfg() *netCfg {
+	cfg, _ := l.netCfg.Load().(*netCfg)
+	if cfg == nil {
+		return &netCfg{}
+	}
+	return cfg
+}
+
+func (l *TcpListener) getTrustedProxies() []*net.IPNet {
+	return l.getNetCfg().trustedProxies
+}
+
+// applyCodecCfg 依次使用业务指定的codec、业务替换的默认codec、按帧格式配置生成的codec.
+func (l *TcpListener) applyCodecCfg(cfg *TcpListenerCfg) error {
+	if l.customCodec != nil {
+		l.codec.Store(&codecHolder{codec: l.customCodec})
+		return nil
+	}
+
+	switch cfg.Framing {
+	case "", FRAMING_BINARY:
+	case FRAMING_NDJSON:
+		if cfg.Compress != "" || cfg.Secure {
+			return fmt.Errorf("listener %s framing %s not support compress or secure", l.name, cfg.Framing)
+		}
+
+		maxHeaderSize, maxBodySize := cfg.MaxHeaderSize, cfg.MaxBodySize
+		if maxHeaderSize == 0 {
+			maxHeaderSize = transport.DefaultMaxHeaderSize
+		}
+		if maxBodySize == 0 {
+			maxBodySize = transport.DefaultMaxBodySize
+		}
+
+		l.codec.Store(&codecHolder{codec: &transport.JSONLineCodec{MaxLineSize: maxHeaderSize + maxBodySize}})
+		return nil
+	default:
+		return fmt.Errorf("listener %s framing %s not support", l.name, cfg.Framing)
+	}
+
+	// 加密的record无法压缩, 两者同时配置时拒绝
+	if cfg.Compress != "" && cfg.Secure {
+		return fmt.Errorf("listener %s not support compress with secure", l.name)
+	}
+
+	_, isDefault := transport.GetCodec().(*transport.DefaultCodec)
+	if !isDefault && (cfg.Compress != "" || cfg.Secure) {
+		return fmt.Errorf("listener %s compress or secure need default codec, got %T", l.name, transport.GetCodec())
+	}
+
+	if !isDefault || (cfg.MaxHeaderSize == 0 && cfg.MaxBodySize == 0 && cfg.Compress == "" && !cfg.Secure) {
+		l.codec.Store(&codecHolder{codec: transport.GetCodec()})
+		return nil
+	}
+
+	compressType, err := transport.GetCompressType(cfg.Compress)
+	if err != nil {
+		return err
+	}
+
+	var codec transport.Codec = &transport.DefaultCodec{
+		MaxHeaderSize:     cfg.MaxHeaderSize,
+		MaxBodySize:       cfg.MaxBodySize,
+		CompressType:      compressType,
+		CompressThreshold: cfg.CompressThreshold,
+	}
+
+	if cfg.Secure {
+		raw, err := hex.DecodeString(cfg.StaticKey)
+		if err != nil {
+			return fmt.Errorf("listener %s static key: %w", l.name, err)
+		}
+
+		static, err := secure.NewStaticKey(raw)
+		if err != nil {
+			return fmt.Errorf("listener %s static key: %w", l.name, err)
+		}
+
+		codec = secure.NewCodec(codec, static, cfg.RekeyPackets)
+	}
+
+	l.codec.Store(&codecHolder{codec: codec})
+
+	return nil
+}
+
+func (l *TcpListener) init(o *transport.Options) error {
+	l.eventHandler = o.EventHandler
+	if h, ok := o.Handlers[l.name]; ok {
+		l.eventHandler = h
+	}
+
+	if l.eventHandler == nil {
+		return fmt.Errorf("listener %s has no event handler", l.name)
+	}
+
+	l.customCodec = o.Codecs[l.name]
+
+	cfg := l.getCfg()
+
+	err := l.applyNetCfg(cfg)
+	if err != nil {
+		return err
+	}
+
+	return l.applyCodecCfg(cfg)
+}
+
+func (l *TcpListener) listen(ctx context.Context) error {
+	var (
+		listener net.Listener
+		err      error
+	)
+
+	cfg := l.getCfg()
+
+	if l.network == "unix" {
+		listener, err = listenUnix(cfg.Addr, cfg.Perm)
+		if err != nil {
+			log.Error("listen unix fail for %s", err.Error())
+			return fmt.Errorf("listen unix fail, err:%w", err)
+		}
+	} else {
+		addr, err := net.ResolveTCPAddr("tcp", cfg.Addr)
+		if err != nil {
+			log.Error("resolve err: %s", err.Error())
+			return fmt.Errorf("resolve err:%w", err)
+		}
+
+		listener, err = net.ListenTCP("tcp", addr)
+		if err != nil {
+			log.Error("listen fail for %s", err.Error())
+			return fmt.Errorf("listen fail, err:%w", err)
+		}
+	}
+
+	l.listener = listener
+
+	go func() {
+		l.serve(ctx, listener)
+	}()
+
+	log.Info("%s listener %s listen on: %s, serving ...", l.network, l.name, listener.Addr().String())
+
+	return nil
+}
+
+func (l *TcpListener) serve(ctx context.Context, listener net.Listener) {
+	log.Info("%s listener %s begin to serve", l.network, l.name)
+
+	var once sync.Once
+	defer once.Do(func() {
+		listener.Close()
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			var e net.Error
+			if errors.As(err, &e) && e.Temporary() {
+				time.Sleep(2 * time.Millisecond)
+				continue
+			} else {
+				return
+			}
+		}
+
+		// 来自可信代理的连接在解析PROXY头后再按真实地址检查
+		ip := addrIP(conn.RemoteAddr())
+		if !l.isProxied(ip) && !l.checkAccess(ip) {
+			_ = conn.Close()
+			continue
+		}
+
+		setSockBuffer(conn)
+
+		tcpCtx := NewTcpConn(ctx, l, conn)
+		go tcpCtx.Recv()
+	}
+}
+
+func (l *TcpListener) close() {
+	if l.listener != nil {
+		_ = l.listener.Close()
+	}
+}
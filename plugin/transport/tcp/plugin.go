@@ -39,9 +39,7 @@ func (f *factory) Reload(i interface{}, conf map[string]interface{}) error {
 	}
 
 	tcpTransIns := i.(*TcpTransport)
-	tcpTransIns.SetConfig(&config)
-
-	return nil
+	return tcpTransIns.SetConfig(&config)
 }
 
 func (f *factory) Mainloop(interface{}) {
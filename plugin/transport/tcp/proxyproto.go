@@ -0,0 +1,218 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	_proxyV1MaxLen      = 107
+	_proxyHeaderTimeout = 3 * time.Second
+)
+
+var (
+	_proxyV1Prefix = []byte("PROXY ")
+	_proxyV2Sig    = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrProxyHeader = errors.New("tcp: invalid proxy protocol header")
+)
+
+// parseCIDRs 解析CIDR列表, 单个IP视为/32或/128.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, s := range cidrs {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %s", s)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	default:
+		return nil
+	}
+}
+
+// readProxyHeader 解析PROXY protocol v1/v2头, 返回nil表示头中没有携带地址(LOCAL/UNKNOWN).
+func readProxyHeader(reader *bufio.Reader) (net.Addr, error) {
+	sig, err := reader.Peek(len(_proxyV2Sig))
+	if err == nil && bytes.Equal(sig, _proxyV2Sig) {
+		return readProxyHeaderV2(reader)
+	}
+
+	prefix, err := reader.Peek(len(_proxyV1Prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(prefix, _proxyV1Prefix) {
+		return readProxyHeaderV1(reader)
+	}
+
+	return nil, fmt.Errorf("%w: missing signature", ErrProxyHeader)
+}
+
+// PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readProxyHeaderV1(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+
+	for len(line) < _proxyV1MaxLen {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: v1 line too long", ErrProxyHeader)
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("%w: v1 fields %d", ErrProxyHeader, len(fields))
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("%w: v1 protocol %s", ErrProxyHeader, fields[1])
+	}
+
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%w: v1 fields %d", ErrProxyHeader, len(fields))
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, fmt.Errorf("%w: v1 src ip %s", ErrProxyHeader, fields[2])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: v1 src port %s", ErrProxyHeader, fields[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyHeaderV2(reader *bufio.Reader) (net.Addr, error) {
+	head := make([]byte, 16)
+	_, err := io.ReadFull(reader, head)
+	if err != nil {
+		return nil, err
+	}
+
+	verCmd := head[12]
+	family := head[13]
+	length := int(binary.BigEndian.Uint16(head[14:16]))
+
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("%w: v2 version %d", ErrProxyHeader, verCmd>>4)
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// LOCAL命令为代理自身的健康检查等, 保留原始地址
+	if verCmd&0x0F == 0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 0x11, 0x12: // TCP4 UDP4
+		if length < 12 {
+			return nil, fmt.Errorf("%w: v2 ipv4 length %d", ErrProxyHeader, length)
+		}
+
+		ip := net.IP(append([]byte(nil), payload[0:4]...))
+		return &net.TCPAddr{IP: ip, Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x21, 0x22: // TCP6 UDP6
+		if length < 36 {
+			return nil, fmt.Errorf("%w: v2 ipv6 length %d", ErrProxyHeader, length)
+		}
+
+		ip := net.IP(append([]byte(nil), payload[0:16]...))
+		return &net.TCPAddr{IP: ip, Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// recoverProxyAddr 来自可信代理的连接, 从PROXY头中恢复真实的客户端地址.
+func (c *tcpConn) recoverProxyAddr() error {
+	if c.outbound || !c.listener.getCfg().ProxyProtocol {
+		return nil
+	}
+
+	if !containsIP(c.listener.getTrustedProxies(), addrIP(c.conn.RemoteAddr())) {
+		return nil
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(_proxyHeaderTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	addr, err := readProxyHeader(c.reader)
+	if err != nil {
+		return err
+	}
+
+	if addr != nil {
+		c.remoteAddr = addr
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	reader := bufio.NewReader(bytes.NewBufferString("PROXY TCP4 192.168.0.1 10.0.0.2 56324 8888\r\npayload"))
+
+	addr, err := readProxyHeader(reader)
+	if err != nil {
+		t.Fatalf("read v1 failed: %v", err)
+	}
+
+	if addr.String() != "192.168.0.1:56324" {
+		t.Errorf("fail: addr %s", addr.String())
+	}
+
+	rest, _ := reader.ReadString(0)
+	if rest != "payload" {
+		t.Errorf("fail: rest %s", rest)
+	}
+
+	addr, err = readProxyHeader(bufio.NewReader(bytes.NewBufferString("PROXY UNKNOWN\r\n")))
+	if err != nil || addr != nil {
+		t.Errorf("fail: unknown addr %v err %v", addr, err)
+	}
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(_proxyV2Sig)
+	buf.Write([]byte{0x21, 0x11, 0, 12})
+	buf.Write(net.ParseIP("203.0.113.7").To4())
+	buf.Write(net.ParseIP("10.0.0.2").To4())
+
+	port := make([]byte, 4)
+	binary.BigEndian.PutUint16(port[0:2], 40000)
+	binary.BigEndian.PutUint16(port[2:4], 8888)
+	buf.Write(port)
+
+	addr, err := readProxyHeader(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("read v2 failed: %v", err)
+	}
+
+	if addr.String() != "203.0.113.7:40000" {
+		t.Errorf("fail: addr %s", addr.String())
+	}
+}
+
+func TestReadProxyHeaderInvalid(t *testing.T) {
+	_, err := readProxyHeader(bufio.NewReader(bytes.NewBufferString("GET / HTTP/1.1\r\n")))
+	if !errors.Is(err, ErrProxyHeader) {
+		t.Errorf("fail: expect proxy header err, got %v", err)
+	}
+
+	_, err = readProxyHeader(bufio.NewReader(bytes.NewBufferString("PROXY TCP4 bad 10.0.0.2 1 2\r\n")))
+	if !errors.Is(err, ErrProxyHeader) {
+		t.Errorf("fail: expect bad ip err, got %v", err)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	if !containsIP(nets, net.ParseIP("10.1.2.3")) || !containsIP(nets, net.ParseIP("192.168.1.1")) ||
+		!containsIP(nets, net.ParseIP("::1")) || containsIP(nets, net.ParseIP("192.168.1.2")) {
+		t.Errorf("fail: contains check")
+	}
+
+	if _, err := parseCIDRs([]string{"bad"}); err == nil {
+		t.Errorf("fail: expect parse err")
+	}
+}
@@ -0,0 +1,119 @@
+package tcp
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/plugin/transport/secure"
+)
+
+func newSecureListener(t *testing.T) (*TcpListener, *secure.StaticKey) {
+	static, err := secure.GenerateStaticKey()
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+
+	trans, _ := NewTcpTransport(&TcpTransportCfg{
+		TcpListenerCfg: TcpListenerCfg{
+			Secure:    true,
+			StaticKey: hex.EncodeToString(static.PrivateKey()),
+		},
+	})
+
+	l := trans.GetListener(DefaultListenerName)
+	if err := l.applyCodecCfg(l.getCfg()); err != nil {
+		t.Fatalf("apply codec cfg failed: %v", err)
+	}
+
+	return l, static
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	old := _handshakeTimeout
+	_handshakeTimeout = 50 * time.Millisecond
+	defer func() { _handshakeTimeout = old }()
+
+	l, static := newSecureListener(t)
+
+	// 对端连接后不发送握手
+	server, peer := net.Pipe()
+	defer peer.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewTcpConn(context.Background(), l, server).handshake()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("fail: silent client handshake succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("fail: server handshake not timeout")
+	}
+
+	// 对端服务器不回复server hello
+	client, peer2 := net.Pipe()
+	defer peer2.Close()
+	go func() { _, _ = io.Copy(ioutil.Discard, peer2) }()
+
+	c := &TcpConnector{serverKey: static.PublicKey()}
+	tc := NewTcpConn(context.Background(), l, client)
+	tc.outbound = true
+
+	go func() {
+		done <- c.handshake(tc)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("fail: silent server handshake succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("fail: connector handshake not timeout")
+	}
+}
+
+type rawCodec struct {
+	transport.DefaultCodec
+}
+
+func TestSecureCodecCfg(t *testing.T) {
+	static, _ := secure.GenerateStaticKey()
+
+	trans, _ := NewTcpTransport(&TcpTransportCfg{
+		TcpListenerCfg: TcpListenerCfg{
+			Compress:  "zstd",
+			Secure:    true,
+			StaticKey: hex.EncodeToString(static.PrivateKey()),
+		},
+	})
+
+	l := trans.GetListener(DefaultListenerName)
+	if err := l.applyCodecCfg(l.getCfg()); err == nil {
+		t.Errorf("fail: compress with secure accepted")
+	}
+
+	// 业务替换了默认codec时不能静默降级为明文
+	old := transport.GetCodec()
+	transport.SetCodec(&rawCodec{})
+	defer transport.SetCodec(old)
+
+	for _, cfg := range []TcpListenerCfg{
+		{Secure: true, StaticKey: hex.EncodeToString(static.PrivateKey())},
+		{Compress: "zstd"},
+	} {
+		cfg := cfg
+		if err := l.applyCodecCfg(&cfg); err == nil {
+			t.Errorf("fail: cfg %+v accepted with custom codec", cfg)
+		}
+	}
+}
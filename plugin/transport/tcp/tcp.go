@@ -3,7 +3,6 @@ package tcp
 import (
 	"bufio"
 	"context"
-	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -19,24 +18,38 @@ type tcpConn struct {
 	ctx           context.Context
 	lastReadTime  time.Time
 	lastWriteTime time.Time
-	conn          *net.TCPConn
+	listener      *TcpListener
+	codec         transport.Codec
+	conn          net.Conn
 	localAddr     net.Addr
 	remoteAddr    net.Addr
 	reader        *bufio.Reader
 	writer        *bufio.Writer
 	cancleCtx     context.Context
 	cancle        context.CancelFunc
+	closeOnce     sync.Once
+	sendMutex     sync.Mutex
+	ctxMutex      sync.Mutex
+	ctxValues     map[interface{}]interface{}
+	outbound      bool
 }
 
 const (
 	_maxBufSize = 512 * 1024
 )
 
-func NewTcpConn(ctx context.Context, conn *net.TCPConn) *tcpConn {
+var (
+	// 握手期间对端未认证, 超时未完成则断开
+	_handshakeTimeout = 5 * time.Second
+)
+
+func NewTcpConn(ctx context.Context, l *TcpListener, conn net.Conn) *tcpConn {
 	cancleCtx, cancle := context.WithCancel(context.Background())
 
 	tcpCtx := &tcpConn{
 		ctx:          ctx,
+		listener:     l,
+		codec:        l.GetCodec(),
 		connID:       uid.GenerateUID(),
 		lastReadTime: time.Now(),
 		conn:         conn,
@@ -64,8 +77,16 @@ func (c *tcpConn) GetRemoteAddr() (addr net.Addr) {
 	return c.remoteAddr
 }
 
+func (c *tcpConn) GetListener() transport.Listener {
+	return c.listener
+}
+
+// Send 编码和写出在同一把锁内完成, 保证有状态的codec(如加密序号)与写出顺序一致.
 func (c *tcpConn) Send(data []byte) error {
-	result, err := transport.GetCodec().Encode(c, data)
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	result, err := c.codec.Encode(c, data)
 	if err != nil {
 		log.Error("codec encode failed for %s", err.Error())
 		return err
@@ -85,22 +106,81 @@ func (c *tcpConn) Send(data []byte) error {
 	return nil
 }
 
+// SendBuffer 发送池化的Buffer, codec支持时原地编码, 发送后归还Buffer.
+func (c *tcpConn) SendBuffer(buf *transport.Buffer) error {
+	codec, ok := c.codec.(transport.FrameCodec)
+	if !ok {
+		defer transport.ReleaseBuffer(buf)
+		return c.Send(buf.B[transport.FrameHeadroom:])
+	}
+
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	frame, err := codec.EncodeFrame(c, buf)
+	if err != nil {
+		log.Error("codec encode frame failed for %s", err.Error())
+		return err
+	}
+	defer transport.ReleaseBuffer(frame)
+
+	c.setWriteTimeout()
+
+	_, err = c.writer.Write(frame.B)
+	if err != nil {
+		log.Error("writer write frame_len %d failed for err %v", len(frame.B), err)
+		return err
+	}
+
+	return c.writer.Flush()
+}
+
+func (c *tcpConn) GetCodec() transport.Codec {
+	return c.codec
+}
+
+// SendFrame 直接写出codec已编码好的帧.
+func (c *tcpConn) SendFrame(frame []byte) error {
+	err := c.writeRaw(frame)
+	if err != nil {
+		log.Error("writer write frame_len %d failed for err %v", len(frame), err)
+	}
+	return err
+}
+
+func (c *tcpConn) GetContext(key interface{}) interface{} {
+	c.ctxMutex.Lock()
+	defer c.ctxMutex.Unlock()
+
+	return c.ctxValues[key]
+}
+
+func (c *tcpConn) SetContext(key interface{}, value interface{}) {
+	c.ctxMutex.Lock()
+	defer c.ctxMutex.Unlock()
+
+	if c.ctxValues == nil {
+		c.ctxValues = make(map[interface{}]interface{})
+	}
+	c.ctxValues[key] = value
+}
+
 func (c *tcpConn) setReadTimeout() {
-	if _transInst.cfg.IdleTimeout > 0 {
+	if idleTimeout := c.listener.getCfg().IdleTimeout; idleTimeout > 0 {
 		now := time.Now()
 		if now.Sub(c.lastReadTime) > 2*time.Second {
 			c.lastReadTime = now
-			c.conn.SetReadDeadline(now.Add(time.Duration(_transInst.cfg.IdleTimeout) * time.Second))
+			c.conn.SetReadDeadline(now.Add(time.Duration(idleTimeout) * time.Second))
 		}
 	}
 }
 
 func (c *tcpConn) setWriteTimeout() {
-	if _transInst.cfg.IdleTimeout > 0 {
+	if idleTimeout := c.listener.getCfg().IdleTimeout; idleTimeout > 0 {
 		now := time.Now()
 		if now.Sub(c.lastWriteTime) > 2*time.Second {
 			c.lastWriteTime = now
-			c.conn.SetWriteDeadline(now.Add(time.Duration(_transInst.cfg.IdleTimeout) * time.Second))
+			c.conn.SetWriteDeadline(now.Add(time.Duration(idleTimeout) * time.Second))
 		}
 	}
 }
@@ -124,10 +204,74 @@ func (c *tcpConn) Read(targetBuff []byte) (int, error) {
 	return index, nil
 }
 
+// writeRaw 不经过codec直接写出, 用于握手和共享帧.
+func (c *tcpConn) writeRaw(data []byte) error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.setWriteTimeout()
+
+	_, err := c.writer.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return c.writer.Flush()
+}
+
+// handshake 只对被动接入的连接执行, 主动连接由TcpConnector在发送前以客户端身份握手.
+func (c *tcpConn) handshake() error {
+	if c.outbound {
+		return nil
+	}
+
+	hs, ok := c.codec.(transport.HandshakeCodec)
+	if !ok {
+		return nil
+	}
+
+	return c.withHandshakeDeadline(func() error {
+		return hs.Handshake(c, c.writeRaw)
+	})
+}
+
+// withHandshakeDeadline 握手使用独立的超时, 空闲超时在连接建立2秒内不生效, 不能用于握手.
+// 握手完成后清除超时, 之后的读写重新按空闲超时设置.
+func (c *tcpConn) withHandshakeDeadline(fn func() error) error {
+	_ = c.conn.SetDeadline(time.Now().Add(_handshakeTimeout))
+
+	err := fn()
+
+	_ = c.conn.SetDeadline(time.Time{})
+	c.lastReadTime = time.Time{}
+	c.lastWriteTime = time.Time{}
+
+	return err
+}
+
 func (c *tcpConn) Recv() {
+	err := c.recoverProxyAddr()
+	if err != nil {
+		log.Error("client %s proxy protocol failed for %s", c.remoteAddr.String(), err.Error())
+		_ = c.conn.Close()
+		return
+	}
+
+	if !c.outbound && c.listener.isProxied(addrIP(c.conn.RemoteAddr())) && !c.listener.checkAccess(addrIP(c.remoteAddr)) {
+		_ = c.conn.Close()
+		return
+	}
+
+	err = c.handshake()
+	if err != nil {
+		log.Error("client %s handshake failed for %s", c.remoteAddr.String(), err.Error())
+		_ = c.conn.Close()
+		return
+	}
+
 	defer c.Close(false)
 
-	_transInst.eventHandler.OnConnOpened(c)
+	c.listener.eventHandler.OnConnOpened(c)
 
 	for {
 		select {
@@ -141,24 +285,34 @@ func (c *tcpConn) Recv() {
 
 		c.setReadTimeout()
 
-		pkg, err := transport.GetCodec().Decode(c)
+		if codec, ok := c.codec.(transport.FrameCodec); ok {
+			buf, err := codec.DecodeFrame(c)
+			if err != nil {
+				log.Info("codec decode frame failed for %s", err.Error())
+				return
+			}
+
+			c.listener.eventHandler.OnData(c, buf.B)
+			transport.ReleaseBuffer(buf)
+			continue
+		}
+
+		pkg, err := c.codec.Decode(c)
 		if err != nil {
 			log.Info("codec decode failed for %s", err.Error())
 			return
 		}
 
-		_transInst.eventHandler.OnData(c, pkg)
+		c.listener.eventHandler.OnData(c, pkg)
 	}
 
 }
 
 func (c *tcpConn) Close(active bool) error {
-	var once sync.Once
-
-	once.Do(func() {
+	c.closeOnce.Do(func() {
 		_ = c.writer.Flush()
 
-		_transInst.eventHandler.OnConnClosed(c, active)
+		c.listener.eventHandler.OnConnClosed(c, active)
 
 		c.cancle()
 
@@ -170,92 +324,156 @@ func (c *tcpConn) Close(active bool) error {
 }
 
 type TcpTransportCfg struct {
-	Addr        string `mapstructure:"addr"`
-	IdleTimeout uint32 `mapstructure:"idletimeout"`
+	// 顶层的监听配置作为名为default的监听器
+	TcpListenerCfg `mapstructure:",squash"`
+
+	Listeners  []TcpListenerCfg  `mapstructure:"listeners"`
+	Connectors []TcpConnectorCfg `mapstructure:"connectors"`
 }
 
 type TcpTransport struct {
-	eventHandler transport.EventHandler
-	cancel       context.CancelFunc
-	cfg          *TcpTransportCfg
+	ctx        context.Context
+	cancel     context.CancelFunc
+	cfg        *TcpTransportCfg
+	connMutex  sync.Mutex
+	connectors map[string]*TcpConnector
+	network    string
+	listeners  map[string]*TcpListener
+	// 顶层配置对应的监听器名字
+	defaultName string
 }
 
-var (
-	_transInst *TcpTransport
-)
-
 func NewTcpTransport(cfg *TcpTransportCfg) (*TcpTransport, error) {
-	_transInst = &TcpTransport{
-		cfg: cfg,
-	}
-	return _transInst, nil
+	return newTransport(cfg, "tcp")
 }
 
-func (t *TcpTransport) SetConfig(cfg *TcpTransportCfg) {
-	t.cfg = cfg
+func newTransport(cfg *TcpTransportCfg, network string) (*TcpTransport, error) {
+	t := &TcpTransport{
+		cfg:        cfg,
+		connectors: make(map[string]*TcpConnector),
+		network:    network,
+		listeners:  make(map[string]*TcpListener),
+	}
+
+	t.defaultName = DefaultListenerName
+	if network == "unix" {
+		t.defaultName = DefaultUnixListenerName
+	}
+
+	for _, lc := range listenerCfgs(cfg, t.defaultName) {
+		if _, ok := t.listeners[lc.Name]; ok {
+			return nil, fmt.Errorf("duplicate listener name %s", lc.Name)
+		}
+
+		t.listeners[lc.Name] = newTcpListener(t, lc)
+	}
+
+	return t, nil
 }
 
-func (t *TcpTransport) Init(o transport.Options) error {
-	t.eventHandler = o.EventHandler
+// listenerCfgs 展开顶层和listeners中的所有监听配置, 顶层配置的名字为defaultName.
+func listenerCfgs(cfg *TcpTransportCfg, defaultName string) []*TcpListenerCfg {
+	result := make([]*TcpListenerCfg, 0, len(cfg.Listeners)+1)
 
-	addr, err := net.ResolveTCPAddr("tcp", t.cfg.Addr)
-	if err != nil {
-		log.Error("resolve err: %s", err.Error())
-		return fmt.Errorf("resolve err:%w", err)
+	def := cfg.TcpListenerCfg
+	def.Name = defaultName
+	result = append(result, &def)
+
+	for i := range cfg.Listeners {
+		lc := cfg.Listeners[i]
+		result = append(result, &lc)
 	}
 
-	listener, err := net.ListenTCP("tcp", addr)
-	if err != nil {
-		log.Error("listen fail for %s", err.Error())
-		return fmt.Errorf("listen fail, err:%w", err)
+	return result
+}
+
+// GetDefaultListenerName 顶层配置对应的监听器名字, tcp为default, unix为unix.
+func (t *TcpTransport) GetDefaultListenerName() string {
+	return t.defaultName
+}
+
+// GetListenerNames 获取所有监听器的名字.
+func (t *TcpTransport) GetListenerNames() []string {
+	names := make([]string, 0, len(t.listeners))
+	for name := range t.listeners {
+		names = append(names, name)
 	}
+	return names
+}
 
-	ctx, cancle := context.WithCancel(context.Background())
+// GetListener 根据名字获取监听器.
+func (t *TcpTransport) GetListener(name string) *TcpListener {
+	return t.listeners[name]
+}
+
+// SetConfig 按名字更新已有监听器的配置, reload中新增的监听器需要重启才能生效.
+func (t *TcpTransport) SetConfig(cfg *TcpTransportCfg) error {
+	for _, lc := range listenerCfgs(cfg, t.defaultName) {
+		l, ok := t.listeners[lc.Name]
+		if !ok {
+			log.Error("listener %s not exist, need restart", lc.Name)
+			continue
+		}
 
-	go func() {
-		t.serve(ctx, listener)
-	}()
+		err := l.setConfig(lc)
+		if err != nil {
+			return fmt.Errorf("listener %s set config failed for %w", lc.Name, err)
+		}
+	}
 
-	t.cancel = cancle
-	log.Info("tcp transport listen on: %s, serving ...", addr.String())
+	t.cfg = cfg
 
 	return nil
 }
 
-func (t *TcpTransport) serve(ctx context.Context, listener *net.TCPListener) {
-	log.Info("tcp tranport begin to serve")
+func (t *TcpTransport) Init(o transport.Options) error {
+	for _, l := range t.listeners {
+		err := l.init(&o)
+		if err != nil {
+			log.Error("init listener %s failed for %s", l.name, err.Error())
+			return err
+		}
+	}
 
-	var once sync.Once
-	defer once.Do(func() {
-		listener.Close()
-	})
+	t.ctx, t.cancel = context.WithCancel(context.Background())
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
+	for _, l := range t.listeners {
+		if l.getCfg().Addr == "" {
+			continue
 		}
 
-		conn, err := listener.AcceptTCP()
+		err := l.listen(t.ctx)
 		if err != nil {
-			var e net.Error
-			if errors.As(err, &e) && e.Temporary() {
-				time.Sleep(2 * time.Millisecond)
-				continue
-			} else {
-				return
-			}
+			return err
 		}
+	}
 
-		conn.SetReadBuffer(int(_maxBufSize))
-		conn.SetWriteBuffer(int(_maxBufSize))
-
-		tcpCtx := NewTcpConn(ctx, conn)
-		go tcpCtx.Recv()
+	for i := range t.cfg.Connectors {
+		_, err := t.Connect(&t.cfg.Connectors[i])
+		if err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
 func (t *TcpTransport) Uninit() {
-	t.cancel()
+	if t.cancel != nil {
+		t.cancel()
+	}
+
+	for _, l := range t.listeners {
+		l.close()
+	}
+}
+
+func setSockBuffer(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	tcpConn.SetReadBuffer(int(_maxBufSize))
+	tcpConn.SetWriteBuffer(int(_maxBufSize))
 }
@@ -0,0 +1,156 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin"
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	_staleDialTimeout = 500 * time.Millisecond
+)
+
+// NewUnixTransport 创建unix socket传输层, 与tcp共用帧格式和连接实现.
+func NewUnixTransport(cfg *TcpTransportCfg) (*TcpTransport, error) {
+	return newTransport(cfg, "unix")
+}
+
+// unixListener 在私有目录中bind后rename到目标路径, 关闭时删除目标路径的socket文件.
+type unixListener struct {
+	*net.UnixListener
+	addr *net.UnixAddr
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	_ = os.Remove(l.addr.Name)
+	return err
+}
+
+// listenUnix 监听前清理残留的socket文件, 文件仍有进程在监听时返回错误.
+// 指定perm时先在仅属主可访问的临时目录中创建socket并chmod, 再rename到目标路径, 避免chmod之前其他用户连入.
+func listenUnix(path string, perm string) (net.Listener, error) {
+	var mode os.FileMode
+	if perm != "" {
+		v, err := strconv.ParseUint(perm, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid perm %s: %w", perm, err)
+		}
+		mode = os.FileMode(v)
+	}
+
+	err := removeStaleSocket(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if perm == "" {
+		listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+		if err != nil {
+			return nil, err
+		}
+
+		listener.SetUnlinkOnClose(true)
+		return listener, nil
+	}
+
+	// TempDir创建的目录权限为0700
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "s")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+
+	// 目标路径的文件由unixListener负责删除
+	listener.SetUnlinkOnClose(false)
+
+	err = os.Chmod(tmpPath, mode)
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return &unixListener{UnixListener: listener, addr: &net.UnixAddr{Name: path, Net: "unix"}}, nil
+}
+
+// removeStaleSocket 只删除没有进程监听的socket文件, 连接被拒绝以外的错误不删除.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, _staleDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("check unix socket %s failed: %w", path, err)
+	}
+
+	log.Info("remove stale unix socket %s", path)
+
+	return os.Remove(path)
+}
+
+//=====================================================
+
+type unixFactory struct {
+	factory
+}
+
+func (f *unixFactory) Name() string {
+	return "unix"
+}
+
+func (f *unixFactory) Setup(v *viper.Viper) (interface{}, error) {
+	var config TcpTransportCfg
+
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, err
+	}
+
+	return NewUnixTransport(&config)
+}
+
+func (f *unixFactory) Destroy(i interface{}) error {
+	i.(*TcpTransport).Uninit()
+	return nil
+}
+
+func init() {
+	plugin.RegisterPluginFactory(&unixFactory{})
+}
@@ -0,0 +1,76 @@
+package tcp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+
+	l, err := listenUnix(path, "0660")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("fail: socket perm %v err %v", info.Mode().Perm(), err)
+	}
+
+	if _, err := listenUnix(path, ""); err == nil {
+		t.Errorf("fail: listen on socket in use")
+	}
+
+	if l.Addr().String() != path {
+		t.Errorf("fail: listener addr %s", l.Addr().String())
+	}
+
+	_ = l.Close()
+
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("fail: socket not removed on close, err %v", err)
+	}
+
+	if _, err := listenUnix(path, "999"); err == nil {
+		t.Errorf("fail: invalid perm accepted")
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.sock")
+
+	// 进程退出后残留的socket文件
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	l, err := listenUnix(path, "0600")
+	if err != nil {
+		t.Fatalf("fail: stale socket not removed, err %v", err)
+	}
+	_ = l.Close()
+
+	file := filepath.Join(t.TempDir(), "file.sock")
+	_ = ioutil.WriteFile(file, nil, 0600)
+	if _, err := listenUnix(file, ""); err == nil {
+		t.Errorf("fail: regular file removed")
+	}
+}
+
+func TestUnixDefaultListenerName(t *testing.T) {
+	trans, err := NewUnixTransport(&TcpTransportCfg{})
+	if err != nil {
+		t.Fatalf("new transport failed: %v", err)
+	}
+
+	if trans.GetListener(DefaultUnixListenerName) == nil || trans.GetListener(DefaultListenerName) != nil {
+		t.Errorf("fail: unix listener names %v", trans.GetListenerNames())
+	}
+}
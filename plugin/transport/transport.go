@@ -11,14 +11,61 @@ type Conn interface {
 	Close(active bool) error
 }
 
+// BufferConn 可选接口, 发送池化Buffer, 调用后Buffer的所有权转移给conn.
+type BufferConn interface {
+	SendBuffer(buf *Buffer) error
+	// GetCodec 连接当前使用的codec.
+	GetCodec() Codec
+	// SendFrame 不经过codec直接写出已编码好的完整帧, 供组播时多个连接共享同一帧, 调用期间frame不能修改.
+	SendFrame(frame []byte) error
+}
+
+// ContextConn 可选接口, 供codec等组件在连接上保存私有状态.
+type ContextConn interface {
+	GetContext(key interface{}) interface{}
+	SetContext(key interface{}, value interface{})
+}
+
+// HandshakeCodec 可选接口, 连接建立后、OnConnOpened之前执行握手, write直接写出不经过codec.
+type HandshakeCodec interface {
+	Handshake(c Conn, write func(data []byte) error) error
+}
+
+// Listener 监听器, 每个监听器有独立的codec和事件处理.
+type Listener interface {
+	GetName() string
+	GetCodec() Codec
+	GetEventHandler() EventHandler
+}
+
+// ListenerConn 可选接口, 获取连接所属的监听器, 主动发起的连接属于其绑定的监听器.
+type ListenerConn interface {
+	GetListener() Listener
+}
+
+// GetConnListenerName 获取连接所属监听器的名字, 不支持时返回空串.
+func GetConnListenerName(c Conn) string {
+	lc, ok := c.(ListenerConn)
+	if !ok || lc.GetListener() == nil {
+		return ""
+	}
+	return lc.GetListener().GetName()
+}
+
 type EventHandler interface {
 	OnConnOpened(conn Conn)
 	OnConnClosed(conn Conn, active bool)
+	// OnData data只在回调期间有效, 需要保留时必须拷贝.
 	OnData(conn Conn, data []byte)
 }
 
 type Options struct {
+	// EventHandler 未在Handlers中指定的监听器使用的事件处理
 	EventHandler EventHandler
+	// Handlers 按监听器名字指定事件处理
+	Handlers map[string]EventHandler
+	// Codecs 按监听器名字指定codec, 指定后忽略该监听器的帧格式配置
+	Codecs map[string]Codec
 }
 
 type Transport interface {
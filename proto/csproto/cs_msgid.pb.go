@@ -25,6 +25,7 @@ type CSMessageID int32
 const (
 	CSMessageID_cs_message_begin CSMessageID = 0
 	CSMessageID_cs_login         CSMessageID = 1
+	CSMessageID_cs_handshake     CSMessageID = 2
 	CSMessageID_cs_message_end   CSMessageID = 4095
 )
 
@@ -33,11 +34,13 @@ var (
 	CSMessageID_name = map[int32]string{
 		0:    "cs_message_begin",
 		1:    "cs_login",
+		2:    "cs_handshake",
 		4095: "cs_message_end",
 	}
 	CSMessageID_value = map[string]int32{
 		"cs_message_begin": 0,
 		"cs_login":         1,
+		"cs_handshake":     2,
 		"cs_message_end":   4095,
 	}
 )
@@ -74,6 +77,7 @@ type SCMessageID int32
 const (
 	SCMessageID_sc_message_begin SCMessageID = 0
 	SCMessageID_sc_login         SCMessageID = 1
+	SCMessageID_sc_handshake     SCMessageID = 2
 	SCMessageID_sc_message_end   SCMessageID = 4095
 )
 
@@ -82,11 +86,13 @@ var (
 	SCMessageID_name = map[int32]string{
 		0:    "sc_message_begin",
 		1:    "sc_login",
+		2:    "sc_handshake",
 		4095: "sc_message_end",
 	}
 	SCMessageID_value = map[string]int32{
 		"sc_message_begin": 0,
 		"sc_login":         1,
+		"sc_handshake":     2,
 		"sc_message_end":   4095,
 	}
 )
@@ -122,17 +128,19 @@ var File_cs_msgid_proto protoreflect.FileDescriptor
 
 var file_cs_msgid_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x63, 0x73, 0x5f, 0x6d, 0x73, 0x67, 0x69, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2a, 0x46, 0x0a, 0x0b, 0x43, 0x53, 0x4d, 0x65, 0x73,
+	0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2a, 0x58, 0x0a, 0x0b, 0x43, 0x53, 0x4d, 0x65, 0x73,
 	0x73, 0x61, 0x67, 0x65, 0x49, 0x44, 0x12, 0x14, 0x0a, 0x10, 0x63, 0x73, 0x5f, 0x6d, 0x65, 0x73,
 	0x73, 0x61, 0x67, 0x65, 0x5f, 0x62, 0x65, 0x67, 0x69, 0x6e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08,
-	0x63, 0x73, 0x5f, 0x6c, 0x6f, 0x67, 0x69, 0x6e, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0e, 0x63, 0x73,
-	0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x65, 0x6e, 0x64, 0x10, 0xff, 0x1f, 0x2a,
-	0x46, 0x0a, 0x0b, 0x53, 0x43, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x44, 0x12, 0x14,
-	0x0a, 0x10, 0x73, 0x63, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x62, 0x65, 0x67,
-	0x69, 0x6e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x73, 0x63, 0x5f, 0x6c, 0x6f, 0x67, 0x69, 0x6e,
-	0x10, 0x01, 0x12, 0x13, 0x0a, 0x0e, 0x73, 0x63, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
-	0x5f, 0x65, 0x6e, 0x64, 0x10, 0xff, 0x1f, 0x42, 0x0a, 0x5a, 0x08, 0x2f, 0x63, 0x73, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x63, 0x73, 0x5f, 0x6c, 0x6f, 0x67, 0x69, 0x6e, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x63, 0x73,
+	0x5f, 0x68, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0e,
+	0x63, 0x73, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x65, 0x6e, 0x64, 0x10, 0xff,
+	0x1f, 0x2a, 0x58, 0x0a, 0x0b, 0x53, 0x43, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x44,
+	0x12, 0x14, 0x0a, 0x10, 0x73, 0x63, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x62,
+	0x65, 0x67, 0x69, 0x6e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x73, 0x63, 0x5f, 0x6c, 0x6f, 0x67,
+	0x69, 0x6e, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x73, 0x63, 0x5f, 0x68, 0x61, 0x6e, 0x64, 0x73,
+	0x68, 0x61, 0x6b, 0x65, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0e, 0x73, 0x63, 0x5f, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x5f, 0x65, 0x6e, 0x64, 0x10, 0xff, 0x1f, 0x42, 0x0a, 0x5a, 0x08, 0x2f,
+	0x63, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
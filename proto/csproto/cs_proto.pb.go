@@ -25,8 +25,9 @@ type CSHead struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Msgid int32 `protobuf:"varint,1,opt,name=msgid,proto3" json:"msgid,omitempty"`
-	Seqid int32 `protobuf:"varint,2,opt,name=seqid,proto3" json:"seqid,omitempty"`
+	Msgid    int32  `protobuf:"varint,1,opt,name=msgid,proto3" json:"msgid,omitempty"`
+	Seqid    int32  `protobuf:"varint,2,opt,name=seqid,proto3" json:"seqid,omitempty"`
+	AckScSeq uint32 `protobuf:"varint,3,opt,name=ack_sc_seq,json=ackScSeq,proto3" json:"ack_sc_seq,omitempty"`
 }
 
 func (x *CSHead) Reset() {
@@ -75,13 +76,23 @@ func (x *CSHead) GetSeqid() int32 {
 	return 0
 }
 
+func (x *CSHead) GetAckScSeq() uint32 {
+	if x != nil {
+		return x.AckScSeq
+	}
+	return 0
+}
+
 type SCHead struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Msgid int32 `protobuf:"varint,1,opt,name=msgid,proto3" json:"msgid,omitempty"`
-	Seqid int32 `protobuf:"varint,2,opt,name=seqid,proto3" json:"seqid,omitempty"`
+	Msgid  int32  `protobuf:"varint,1,opt,name=msgid,proto3" json:"msgid,omitempty"`
+	Seqid  int32  `protobuf:"varint,2,opt,name=seqid,proto3" json:"seqid,omitempty"`
+	Result int32  `protobuf:"varint,3,opt,name=result,proto3" json:"result,omitempty"`
+	Errmsg string `protobuf:"bytes,4,opt,name=errmsg,proto3" json:"errmsg,omitempty"`
+	ScSeq  uint32 `protobuf:"varint,5,opt,name=sc_seq,json=scSeq,proto3" json:"sc_seq,omitempty"`
 }
 
 func (x *SCHead) Reset() {
@@ -130,19 +141,46 @@ func (x *SCHead) GetSeqid() int32 {
 	return 0
 }
 
+func (x *SCHead) GetResult() int32 {
+	if x != nil {
+		return x.Result
+	}
+	return 0
+}
+
+func (x *SCHead) GetErrmsg() string {
+	if x != nil {
+		return x.Errmsg
+	}
+	return ""
+}
+
+func (x *SCHead) GetScSeq() uint32 {
+	if x != nil {
+		return x.ScSeq
+	}
+	return 0
+}
+
 var File_cs_proto_proto protoreflect.FileDescriptor
 
 var file_cs_proto_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x63, 0x73, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x34, 0x0a, 0x06, 0x43, 0x53, 0x48, 0x65, 0x61,
+	0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x52, 0x0a, 0x06, 0x43, 0x53, 0x48, 0x65, 0x61,
 	0x64, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x73, 0x67, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05,
 	0x52, 0x05, 0x6d, 0x73, 0x67, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x65, 0x71, 0x69, 0x64,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x73, 0x65, 0x71, 0x69, 0x64, 0x22, 0x34, 0x0a,
-	0x06, 0x53, 0x43, 0x48, 0x65, 0x61, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x73, 0x67, 0x69, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6d, 0x73, 0x67, 0x69, 0x64, 0x12, 0x14, 0x0a,
-	0x05, 0x73, 0x65, 0x71, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x73, 0x65,
-	0x71, 0x69, 0x64, 0x42, 0x0a, 0x5a, 0x08, 0x2f, 0x63, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x73, 0x65, 0x71, 0x69, 0x64, 0x12, 0x1c, 0x0a,
+	0x0a, 0x61, 0x63, 0x6b, 0x5f, 0x73, 0x63, 0x5f, 0x73, 0x65, 0x71, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x08, 0x61, 0x63, 0x6b, 0x53, 0x63, 0x53, 0x65, 0x71, 0x22, 0x7b, 0x0a, 0x06, 0x53,
+	0x43, 0x48, 0x65, 0x61, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x73, 0x67, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6d, 0x73, 0x67, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x65, 0x71, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x73, 0x65, 0x71, 0x69,
+	0x64, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x72, 0x72,
+	0x6d, 0x73, 0x67, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x72, 0x72, 0x6d, 0x73,
+	0x67, 0x12, 0x15, 0x0a, 0x06, 0x73, 0x63, 0x5f, 0x73, 0x65, 0x71, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x05, 0x73, 0x63, 0x53, 0x65, 0x71, 0x42, 0x0a, 0x5a, 0x08, 0x2f, 0x63, 0x73, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1,18 +1,23 @@
 package app
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/nearmeng/mango-go/common/process"
 	"github.com/nearmeng/mango-go/common/signal"
+	"github.com/nearmeng/mango-go/common/uid"
 	"github.com/nearmeng/mango-go/config"
 	"github.com/nearmeng/mango-go/plugin"
 	"github.com/nearmeng/mango-go/plugin/log"
-	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/server_base/event"
+	"github.com/nearmeng/mango-go/server_base/msg"
 
-	_ "github.com/nearmeng/mango-go/plugin/log/bingologger"
+	"github.com/nearmeng/mango-go/plugin/log/bingologger"
 	_ "github.com/nearmeng/mango-go/plugin/mq/kafka"
 	_ "github.com/nearmeng/mango-go/plugin/mq/pulsar"
 	"github.com/nearmeng/mango-go/plugin/transport/tcp"
@@ -28,14 +33,18 @@ var (
 		moduleCont: make(map[string]ServerModule),
 	}
 
-	_serverFrame   = 4
-	_finishChannel = make(chan struct{})
+	_serverFrame      = 4
+	_packetLogSplitMB = int32(100)
+	_uidInsIDMask     = uint32(0x7FF)
+	_finishChannel    = make(chan struct{})
 )
 
 type serverApp struct {
 	serverName     string
 	serverID       string
 	lastReloadTime int64
+	packetLogPath  string
+	packetLogger   *bingologger.BingoLogger
 }
 
 func NewServerApp(name string) *serverApp {
@@ -76,6 +85,122 @@ func (s *serverApp) initInputParam() error {
 	return nil
 }
 
+// initUID 以服务器id的第一段作为功能id、最后一段作为实例id初始化uid生成器, 连接id由其生成.
+func (s *serverApp) initUID() {
+	segs := strings.Split(s.serverID, ".")
+
+	funcID, _ := strconv.ParseUint(segs[0], 10, 8)
+	insID, _ := strconv.ParseUint(segs[len(segs)-1], 10, 32)
+
+	uid.InitUIDGenerator(uint8(funcID), uint32(insID)&_uidInsIDMask)
+}
+
+func (s *serverApp) initMsgConfig() error {
+	conf := config.GetConfig()
+
+	v := conf.Sub("msg.ratelimit")
+	if v != nil {
+		var cfg msg.RateLimitCfg
+		if err := v.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("unmarshal msg ratelimit failed for %w", err)
+		}
+
+		msg.SetRateLimitCfg(&cfg)
+	}
+
+	v = conf.Sub("msg.resume")
+	if v != nil {
+		var cfg msg.ResumeCfg
+		if err := v.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("unmarshal msg resume failed for %w", err)
+		}
+
+		msg.SetResumeCfg(&cfg)
+	}
+
+	v = conf.Sub("msg.codec")
+	if v != nil {
+		var cfg msg.CSCodecCfg
+		if err := v.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("unmarshal msg codec failed for %w", err)
+		}
+
+		if err := msg.SetCSCodecCfg(&cfg); err != nil {
+			return err
+		}
+	}
+
+	v = conf.Sub("msg.handshake")
+	if v != nil {
+		var cfg msg.HandshakeCfg
+		if err := v.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("unmarshal msg handshake failed for %w", err)
+		}
+
+		msg.SetHandshakeCfg(&cfg)
+	}
+
+	v = conf.Sub("msg.packet_log")
+	if v != nil {
+		var cfg msg.PacketLogCfg
+		if err := v.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("unmarshal msg packet log failed for %w", err)
+		}
+
+		if err := s.initPacketLogger(&cfg); err != nil {
+			return err
+		}
+
+		msg.SetPacketLogCfg(&cfg)
+	}
+
+	return nil
+}
+
+// initPacketLogger 配置了path时包日志写入单独的文件, path变化时才重新创建.
+func (s *serverApp) initPacketLogger(cfg *msg.PacketLogCfg) error {
+	if cfg.Path == s.packetLogPath {
+		return nil
+	}
+
+	if cfg.Path == "" {
+		msg.SetPacketLogger(nil)
+		s.closePacketLogger(nil)
+		s.packetLogPath = ""
+		return nil
+	}
+
+	splitMB := cfg.FileSplitMB
+	if splitMB <= 0 {
+		splitMB = _packetLogSplitMB
+	}
+
+	logger, err := bingologger.NewBingoLogger(bingologger.LogCfg{LogPath: cfg.Path, FileSplitMB: splitMB})
+	if err != nil {
+		return fmt.Errorf("create packet logger failed for %w", err)
+	}
+
+	msg.SetPacketLogger(logger)
+	s.closePacketLogger(logger)
+	s.packetLogPath = cfg.Path
+
+	return nil
+}
+
+// closePacketLogger 新logger替换后关闭旧的包日志文件.
+func (s *serverApp) closePacketLogger(logger *bingologger.BingoLogger) {
+	old := s.packetLogger
+	s.packetLogger = logger
+
+	if old == nil {
+		return
+	}
+
+	if err := old.Close(); err != nil {
+		log.Error("close packet logger failed for %v", err)
+	}
+}
+
 func (s *serverApp) Init() error {
 	//config
 	err := config.Init()
@@ -85,6 +210,7 @@ func (s *serverApp) Init() error {
 
 	conf := config.GetConfig()
 	s.serverID = conf.GetString("svrinfo.serverid")
+	s.initUID()
 
 	//input param process
 	err = s.initInputParam()
@@ -101,9 +227,53 @@ func (s *serverApp) Init() error {
 		return err
 	}
 
+	//server bus
+	busCfg, err := s.initServerBus()
+	if err != nil {
+		return err
+	}
+
 	//plugin manual init
 	tcpIns := plugin.GetPluginInst("transport", "tcp").(*tcp.TcpTransport)
-	tcpIns.Init(transport.Options{EventHandler: &eventTcp{}})
+	err = tcpIns.Init(transportOptions())
+	if err != nil {
+		return err
+	}
+
+	if busCfg != nil {
+		err = s.connectServerPeers(tcpIns, busCfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	unixIns, ok := plugin.GetPluginInst("transport", "unix").(*tcp.TcpTransport)
+	if ok {
+		// handler和codec按监听器名字绑定, tcp和unix的监听器不能重名
+		for _, name := range unixIns.GetListenerNames() {
+			if tcpIns.GetListener(name) != nil {
+				return fmt.Errorf("listener name %s used by both tcp and unix", name)
+			}
+		}
+
+		err = unixIns.Init(transportOptions())
+		if err != nil {
+			return err
+		}
+	}
+
+	//msg
+	err = s.initMsgConfig()
+	if err != nil {
+		return err
+	}
+
+	err = msg.LoadMsgRegistryFromOptions()
+	if err != nil {
+		return err
+	}
+
+	msg.UseMiddleware(msg.RecoveryMiddleware())
 
 	//module
 	for _, module := range _moduleCont.moduleCont {
@@ -124,6 +294,13 @@ func (s *serverApp) Init() error {
 		}
 	}
 
+	//event schedule, 模块订阅完成后再恢复, 按订阅的数据类型解析
+	event.SetSchedulePersistPath(conf.GetString("event.schedule_path"))
+	err = event.LoadSchedule()
+	if err != nil {
+		return err
+	}
+
 	log.Info("server %s %s init success", s.serverName, s.serverID)
 
 	return nil
@@ -156,6 +333,9 @@ func (s *serverApp) Fini() error {
 		log.Info("destroy plugin failed for %v", err)
 	}
 
+	// 插件关闭后剩余的异步回调在逻辑goroutine上执行
+	plugin.Tick()
+
 	log.Info("server %s fini success", s.serverName)
 	return nil
 }
@@ -182,6 +362,10 @@ func (s *serverApp) Mainloop() {
 			finished = true
 		case curr := <-t.C:
 			s.onFrame(curr)
+		case <-event.Notify():
+			event.Dispatch()
+		case <-plugin.TickNotify():
+			plugin.Tick()
 		}
 
 		if finished {
@@ -192,6 +376,9 @@ func (s *serverApp) Mainloop() {
 
 func (s *serverApp) onFrame(t time.Time) {
 
+	event.Tick(t)
+	plugin.Tick()
+
 	for _, module := range _moduleCont.moduleCont {
 		module.Mainloop()
 	}
@@ -214,11 +401,23 @@ func (s *serverApp) Reload() {
 	}
 
 	conf := config.GetConfig()
-	err = plugin.Reload(conf)
+	err = plugin.Reload(conf.Sub("plugin"))
 	if err != nil {
 		log.Error("plugin reload failed for %m", err)
 	}
 
+	err = s.initMsgConfig()
+	if err != nil {
+		log.Error("msg config reload failed for %v", err)
+	}
+
+	busCfg, err := getServerBusCfg()
+	if err != nil {
+		log.Error("msg ss config reload failed for %v", err)
+	} else if busCfg != nil {
+		msg.SetServerBusCfg(busCfg)
+	}
+
 	for _, module := range _moduleCont.moduleCont {
 		module.OnReload()
 	}
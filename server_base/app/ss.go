@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/nearmeng/mango-go/config"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/plugin/transport/tcp"
+	"github.com/nearmeng/mango-go/server_base/msg"
+)
+
+const (
+	_defaultServerListenerName = "internal"
+	_serverConnectorPrefix     = "ss."
+)
+
+type eventServer struct {
+}
+
+func (*eventServer) OnConnOpened(conn transport.Conn) {
+	msg.OnServerConnOpened(conn)
+}
+
+func (*eventServer) OnConnClosed(conn transport.Conn, active bool) {
+	msg.OnServerConnClosed(conn, active)
+}
+
+func (*eventServer) OnData(conn transport.Conn, data []byte) {
+	msg.RecvServerMsg(conn, data)
+}
+
+// getServerBusCfg 读取msg.ss配置, 没有配置时返回nil.
+func getServerBusCfg() (*msg.ServerBusCfg, error) {
+	v := config.GetConfig().Sub("msg.ss")
+	if v == nil {
+		return nil, nil
+	}
+
+	var cfg msg.ServerBusCfg
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal msg ss failed for %w", err)
+	}
+
+	if cfg.Listener == "" {
+		cfg.Listener = _defaultServerListenerName
+	}
+
+	return &cfg, nil
+}
+
+// initServerBus 在transport初始化之前调用, 把服务器间通信的监听器绑定到服务器消息处理.
+func (s *serverApp) initServerBus() (*msg.ServerBusCfg, error) {
+	msg.SetServerID(s.serverID)
+
+	cfg, err := getServerBusCfg()
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+
+	RegisterListenerHandler(cfg.Listener, &eventServer{})
+	msg.SetServerBusCfg(cfg)
+
+	return cfg, nil
+}
+
+// connectServerPeers 为静态路由表中的每个服务器创建连接器, 连接器使用服务器通信的监听器.
+// 路由表的变化需要重启才能生效.
+func (s *serverApp) connectServerPeers(t *tcp.TcpTransport, cfg *msg.ServerBusCfg) error {
+	for _, peer := range cfg.Peers {
+		if peer.ServerID == s.serverID {
+			continue
+		}
+
+		c, err := t.Connect(&tcp.TcpConnectorCfg{
+			Name:     _serverConnectorPrefix + peer.ServerID,
+			Addr:     peer.Addr,
+			Listener: cfg.Listener,
+		})
+		if err != nil {
+			return fmt.Errorf("connect server %s failed for %w", peer.ServerID, err)
+		}
+
+		err = msg.AddServerPeer(peer.ServerID, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -19,3 +19,27 @@ func (*eventTcp) OnConnClosed(conn transport.Conn, active bool) {
 func (*eventTcp) OnData(conn transport.Conn, data []byte) {
 	msg.RecvClientMsg(conn, data)
 }
+
+var (
+	_listenerHandlers = make(map[string]transport.EventHandler)
+	_listenerCodecs   = make(map[string]transport.Codec)
+)
+
+// RegisterListenerHandler 为指定名字的监听器绑定事件处理, 需要在Init之前调用.
+// 未绑定的监听器按客户端连接处理.
+func RegisterListenerHandler(name string, h transport.EventHandler) {
+	_listenerHandlers[name] = h
+}
+
+// RegisterListenerCodec 为指定名字的监听器指定codec, 需要在Init之前调用.
+func RegisterListenerCodec(name string, codec transport.Codec) {
+	_listenerCodecs[name] = codec
+}
+
+func transportOptions() transport.Options {
+	return transport.Options{
+		EventHandler: &eventTcp{},
+		Handlers:     _listenerHandlers,
+		Codecs:       _listenerCodecs,
+	}
+}
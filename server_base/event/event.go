@@ -1,117 +1,296 @@
 package event
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"sync"
-	"time"
 
 	"github.com/nearmeng/mango-go/plugin/log"
+	"go.uber.org/atomic"
 )
 
 const (
 	_maxTopicSubscriberNum = 1024
-	_maxTopicTimeout       = 1 * time.Second
+	_defaultQueueSize      = 65536
 )
 
-type Subscriber struct {
-	name     string
-	callback func(i interface{}, ch chan struct{})
+var (
+	ErrHandlerSignature  = errors.New("event: invalid handler signature")
+	ErrTypeMismatch      = errors.New("event: data type mismatch topic")
+	ErrSubscriberTooMany = errors.New("event: topic subscriber too many")
+	ErrQueueFull         = errors.New("event: async queue full")
+
+	_interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// Subscription 订阅句柄, 用于取消订阅.
+type Subscription struct {
+	bus       *bus
+	topic     string
+	name      string
+	priority  int
+	fn        reflect.Value
+	cancelled atomic.Bool
 }
 
-type Topic struct {
-	timeout     time.Duration
-	subscribers []Subscriber
+func (s *Subscription) GetTopic() string {
+	return s.topic
 }
 
-type Publisher struct {
-	m      sync.RWMutex
-	topics map[string]*Topic
+// GetName 处理函数的函数名, 用于日志.
+func (s *Subscription) GetName() string {
+	return s.name
 }
 
-var (
-	_publisher = &Publisher{
-		topics: make(map[string]*Topic),
+// Unsubscribe 取消订阅, 可以重复调用, 已投递但还没有执行的异步事件也不再执行.
+func (s *Subscription) Unsubscribe() {
+	if s.cancelled.Swap(true) {
+		return
 	}
-)
 
-func NewTopic(name string, timeout time.Duration) error {
-	_publisher.m.Lock()
-	defer _publisher.m.Unlock()
+	s.bus.remove(s)
+}
+
+// topic 订阅者按优先级从高到低排列, 修改时整体替换, 分发时无锁遍历快照.
+type topic struct {
+	name        string
+	dataType    reflect.Type
+	subscribers []*Subscription
+}
+
+type asyncEvent struct {
+	topic string
+	data  interface{}
+}
+
+type bus struct {
+	mutex   sync.RWMutex
+	topics  map[string]*topic
+	queue   []asyncEvent
+	maxSize int
+	notify  chan struct{}
+
+	panicCount atomic.Int64
+}
 
-	_, ok := _publisher.topics[name]
-	if ok {
-		return fmt.Errorf("topic %s has already exist", name)
+var (
+	_bus = newBus(_defaultQueueSize)
+)
+
+func newBus(maxSize int) *bus {
+	return &bus{
+		topics:  make(map[string]*topic),
+		maxSize: maxSize,
+		notify:  make(chan struct{}, 1),
 	}
+}
 
-	if timeout > _maxTopicTimeout {
-		return fmt.Errorf("topic %s time is too max %v", name, timeout)
+// Subscribe 订阅事件, handler为func(data T), 同一事件的所有订阅者的T必须相同, T为interface{}时不检查类型.
+// priority大的先执行, 相同时按订阅顺序执行.
+func Subscribe(name string, priority int, handler interface{}) (*Subscription, error) {
+	return _bus.subscribe(name, priority, handler)
+}
+
+// Publish 同步发布, 在当前goroutine上依次执行所有订阅者, 需要在逻辑goroutine上调用.
+func Publish(name string, data interface{}) error {
+	return _bus.publish(name, data)
+}
+
+// PublishAsync 异步发布, 事件投递到队列, 由逻辑goroutine在Dispatch时执行, 可以在任意goroutine上调用.
+func PublishAsync(name string, data interface{}) error {
+	return _bus.publishAsync(name, data)
+}
+
+// Dispatch 执行队列中的异步事件, 由逻辑goroutine在主循环中调用, 返回执行的事件数.
+// 执行期间新发布的异步事件在下一次Dispatch时执行.
+func Dispatch() int {
+	return _bus.dispatch()
+}
+
+// Notify 有异步事件待执行时可读, 主循环在select中等待后调用Dispatch.
+func Notify() <-chan struct{} {
+	return _bus.notify
+}
+
+// GetPanicCount 获取订阅者panic的次数.
+func GetPanicCount() int64 {
+	return _bus.panicCount.Load()
+}
+
+func parseHandler(handler interface{}) (reflect.Value, reflect.Type, error) {
+	fn := reflect.ValueOf(handler)
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return fn, nil, fmt.Errorf("%w: %T is not func", ErrHandlerSignature, handler)
 	}
 
-	topic := &Topic{
-		timeout:     timeout,
-		subscribers: []Subscriber{},
+	ft := fn.Type()
+	if ft.NumIn() != 1 || ft.NumOut() != 0 {
+		return fn, nil, fmt.Errorf("%w: %s must be func(data T)", ErrHandlerSignature, ft)
 	}
 
-	_publisher.topics[name] = topic
-	return nil
+	return fn, ft.In(0), nil
 }
 
-func RegisterSubscriber(name string, sub Subscriber) error {
-	_publisher.m.Lock()
-	defer _publisher.m.Unlock()
+func (b *bus) subscribe(name string, priority int, handler interface{}) (*Subscription, error) {
+	fn, dataType, err := parseHandler(handler)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 
-	topic, ok := _publisher.topics[name]
+	t, ok := b.topics[name]
 	if !ok {
-		return fmt.Errorf("topic %s not create", name)
+		t = &topic{name: name}
+		b.topics[name] = t
 	}
 
-	if len(topic.subscribers) >= _maxTopicSubscriberNum {
-		return fmt.Errorf("topic %s subscriber too many", name)
+	if dataType != _interfaceType {
+		if t.dataType != nil && t.dataType != dataType {
+			return nil, fmt.Errorf("%w: topic %s type %s, handler %s", ErrTypeMismatch, name, t.dataType, dataType)
+		}
+		t.dataType = dataType
 	}
 
-	for _, s := range topic.subscribers {
-		if s.name == sub.name {
-			return fmt.Errorf("subscriber %s has already subscriber topic %s", sub.name, name)
-		}
+	if len(t.subscribers) >= _maxTopicSubscriberNum {
+		return nil, fmt.Errorf("%w: topic %s", ErrSubscriberTooMany, name)
 	}
 
-	topic.subscribers = append(topic.subscribers, sub)
+	sub := &Subscription{
+		bus:      b,
+		topic:    name,
+		name:     runtime.FuncForPC(fn.Pointer()).Name(),
+		priority: priority,
+		fn:       fn,
+	}
 
-	log.Info("topic %s add subcriber %s, total num %d", name, sub.name, len(topic.subscribers))
-	return nil
+	subscribers := make([]*Subscription, 0, len(t.subscribers)+1)
+	subscribers = append(subscribers, t.subscribers...)
+	subscribers = append(subscribers, sub)
+	sort.SliceStable(subscribers, func(i, j int) bool {
+		return subscribers[i].priority > subscribers[j].priority
+	})
+	t.subscribers = subscribers
+
+	log.Info("topic %s add subscriber %s priority %d, total num %d", name, sub.name, priority, len(subscribers))
 
+	return sub, nil
 }
 
-func SendTopic(sub Subscriber, i interface{}, topic *Topic, wg *sync.WaitGroup) {
-	defer wg.Done()
+func (b *bus) remove(sub *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 
-	ch := make(chan struct{})
-	defer close(ch)
+	t, ok := b.topics[sub.topic]
+	if !ok {
+		return
+	}
 
-	go sub.callback(i, ch)
-	if topic.timeout > 0 {
-		select {
-		case <-time.After(topic.timeout):
-		case <-ch:
+	subscribers := make([]*Subscription, 0, len(t.subscribers))
+	for _, s := range t.subscribers {
+		if s != sub {
+			subscribers = append(subscribers, s)
 		}
 	}
+	t.subscribers = subscribers
+
+	log.Info("topic %s remove subscriber %s, total num %d", sub.topic, sub.name, len(subscribers))
 }
 
-func Publish(name string, i interface{}) error {
-	_publisher.m.Lock()
-	defer _publisher.m.Unlock()
+// getSubscribers 返回订阅者快照, 检查数据类型, 没有订阅者的事件直接丢弃.
+func (b *bus) getSubscribers(name string, data interface{}) ([]*Subscription, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
 
-	topic, ok := _publisher.topics[name]
+	t, ok := b.topics[name]
 	if !ok {
-		return fmt.Errorf("topic %s not create", name)
+		return nil, nil
+	}
+
+	if t.dataType != nil && (data == nil || !reflect.TypeOf(data).AssignableTo(t.dataType)) {
+		return nil, fmt.Errorf("%w: topic %s type %s, data %T", ErrTypeMismatch, name, t.dataType, data)
 	}
 
-	var wg sync.WaitGroup
-	for _, sub := range topic.subscribers {
-		wg.Add(1)
-		go SendTopic(sub, i, topic, &wg)
+	return t.subscribers, nil
+}
+
+func (b *bus) publish(name string, data interface{}) error {
+	subscribers, err := b.getSubscribers(name, data)
+	if err != nil {
+		return err
 	}
 
-	wg.Wait()
+	b.deliver(name, data, subscribers)
 	return nil
 }
+
+func (b *bus) publishAsync(name string, data interface{}) error {
+	_, err := b.getSubscribers(name, data)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	if len(b.queue) >= b.maxSize {
+		b.mutex.Unlock()
+		return fmt.Errorf("%w: topic %s queue size %d", ErrQueueFull, name, b.maxSize)
+	}
+	b.queue = append(b.queue, asyncEvent{topic: name, data: data})
+	b.mutex.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (b *bus) dispatch() int {
+	b.mutex.Lock()
+	queue := b.queue
+	b.queue = nil
+	b.mutex.Unlock()
+
+	for _, e := range queue {
+		subscribers, err := b.getSubscribers(e.topic, e.data)
+		if err != nil {
+			log.Error("dispatch topic %s failed, err %v", e.topic, err)
+			continue
+		}
+		b.deliver(e.topic, e.data, subscribers)
+	}
+
+	return len(queue)
+}
+
+func (b *bus) deliver(name string, data interface{}, subscribers []*Subscription) {
+	for _, sub := range subscribers {
+		if sub.cancelled.Load() {
+			continue
+		}
+		b.call(name, data, sub)
+	}
+}
+
+// call 单个订阅者panic不影响其他订阅者.
+func (b *bus) call(name string, data interface{}, sub *Subscription) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.panicCount.Inc()
+			log.Error("topic %s subscriber %s panic: %v\n%s", name, sub.name, r, debug.Stack())
+		}
+	}()
+
+	arg := reflect.ValueOf(data)
+	if data == nil {
+		arg = reflect.Zero(sub.fn.Type().In(0))
+	}
+
+	sub.fn.Call([]reflect.Value{arg})
+}
@@ -0,0 +1,78 @@
+package event
+
+import (
+	"errors"
+	"testing"
+)
+
+type loginEvent struct {
+	userID uint64
+}
+
+func TestPublishOrder(t *testing.T) {
+	b := newBus(16)
+
+	var order []int
+	_, _ = b.subscribe("login", 0, func(e *loginEvent) { order = append(order, 0) })
+	_, _ = b.subscribe("login", 10, func(e *loginEvent) { order = append(order, 10) })
+	sub, _ := b.subscribe("login", 5, func(e *loginEvent) { panic("bad subscriber") })
+	_, _ = b.subscribe("login", 5, func(e *loginEvent) { order = append(order, 5) })
+
+	if err := b.publish("login", &loginEvent{userID: 1}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	if len(order) != 3 || order[0] != 10 || order[1] != 5 || order[2] != 0 || b.panicCount.Load() != 1 {
+		t.Errorf("fail: order %v panic %d", order, b.panicCount.Load())
+	}
+
+	sub.Unsubscribe()
+	order = nil
+	_ = b.publish("login", &loginEvent{})
+	if len(order) != 3 || b.panicCount.Load() != 1 {
+		t.Errorf("fail: after unsubscribe order %v panic %d", order, b.panicCount.Load())
+	}
+
+	if err := b.publish("login", "wrong type"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("fail: expect type mismatch, err %v", err)
+	}
+
+	if _, err := b.subscribe("login", 0, func(s string) {}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("fail: expect handler type mismatch, err %v", err)
+	}
+}
+
+func TestPublishAsync(t *testing.T) {
+	b := newBus(2)
+
+	var got []uint64
+	sub, _ := b.subscribe("login", 0, func(e *loginEvent) { got = append(got, e.userID) })
+
+	_ = b.publishAsync("login", &loginEvent{userID: 1})
+	_ = b.publishAsync("login", &loginEvent{userID: 2})
+	if err := b.publishAsync("login", &loginEvent{userID: 3}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("fail: expect queue full, err %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Fatalf("fail: async delivered before dispatch")
+	}
+
+	select {
+	case <-b.notify:
+	default:
+		t.Errorf("fail: no notify")
+	}
+
+	if n := b.dispatch(); n != 2 || len(got) != 2 || got[1] != 2 {
+		t.Errorf("fail: dispatch %d got %v", n, got)
+	}
+
+	// 已投递未执行的事件在取消订阅后不再执行
+	_ = b.publishAsync("login", &loginEvent{userID: 4})
+	sub.Unsubscribe()
+	b.dispatch()
+	if len(got) != 2 {
+		t.Errorf("fail: delivered after unsubscribe %v", got)
+	}
+}
@@ -0,0 +1,390 @@
+package event
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+)
+
+const (
+	_secondsPerDay = 24 * 60 * 60
+	_noDaily       = -1
+)
+
+var (
+	ErrTimerInvalid   = errors.New("event: invalid timer param")
+	ErrTimerCancelled = errors.New("event: timer cancelled")
+)
+
+// Timer 延迟或定时发布的事件, 到期后由主循环在逻辑goroutine上同步发布.
+type Timer struct {
+	sched    *scheduler
+	topic    string
+	data     interface{}
+	at       time.Time
+	interval time.Duration
+	daily    int
+	key      string
+	raw      json.RawMessage
+	index    int
+}
+
+// timerRecord 持久化的定时事件, 数据以json保存.
+type timerRecord struct {
+	Key      string          `json:"key"`
+	Topic    string          `json:"topic"`
+	At       int64           `json:"at"`
+	Interval int64           `json:"interval"`
+	Daily    int             `json:"daily"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
+
+type scheduler struct {
+	bus    *bus
+	mutex  sync.Mutex
+	timers timerHeap
+	byKey  map[string]*Timer
+	path   string
+	dirty  bool
+}
+
+var (
+	_scheduler = newScheduler(_bus)
+)
+
+func newScheduler(b *bus) *scheduler {
+	return &scheduler{
+		bus:   b,
+		byKey: make(map[string]*Timer),
+	}
+}
+
+// PublishAfter delay之后发布一次.
+func PublishAfter(name string, data interface{}, delay time.Duration) (*Timer, error) {
+	return _scheduler.add(&Timer{topic: name, data: data, at: time.Now().Add(delay), daily: _noDaily})
+}
+
+// PublishAt 在at时刻发布一次, at已经过去时在下一帧发布.
+func PublishAt(name string, data interface{}, at time.Time) (*Timer, error) {
+	return _scheduler.add(&Timer{topic: name, data: data, at: at, daily: _noDaily})
+}
+
+// PublishEvery 每隔interval发布一次, 第一次在interval之后.
+func PublishEvery(name string, data interface{}, interval time.Duration) (*Timer, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: interval %v", ErrTimerInvalid, interval)
+	}
+
+	return _scheduler.add(&Timer{topic: name, data: data, at: time.Now().Add(interval), interval: interval, daily: _noDaily})
+}
+
+// PublishDaily 每天本地时间hour:minute:second发布, 用于每日重置等.
+func PublishDaily(name string, data interface{}, hour int, minute int, second int) (*Timer, error) {
+	offset := hour*3600 + minute*60 + second
+	if hour < 0 || minute < 0 || minute >= 60 || second < 0 || second >= 60 || offset >= _secondsPerDay {
+		return nil, fmt.Errorf("%w: daily %02d:%02d:%02d", ErrTimerInvalid, hour, minute, second)
+	}
+
+	return _scheduler.add(&Timer{topic: name, data: data, at: nextDaily(time.Now(), offset), daily: offset})
+}
+
+// Tick 发布所有到期的定时事件, 由主循环每帧调用, 返回发布的事件数.
+func Tick(now time.Time) int {
+	return _scheduler.tick(now)
+}
+
+// SetSchedulePersistPath 设置持久化文件, 设置了key的定时事件在变化后于下一帧写入.
+func SetSchedulePersistPath(path string) {
+	_scheduler.mutex.Lock()
+	defer _scheduler.mutex.Unlock()
+
+	_scheduler.path = path
+}
+
+// LoadSchedule 从持久化文件恢复定时事件, 需要在订阅者注册完成后调用, 以便按事件的数据类型解析数据.
+// 重启期间已经到期的事件在下一帧补发一次, key相同的定时事件已存在时不恢复.
+func LoadSchedule() error {
+	return _scheduler.load()
+}
+
+func nextDaily(now time.Time, offset int) time.Time {
+	y, m, d := now.Date()
+	at := time.Date(y, m, d, offset/3600, offset%3600/60, offset%60, 0, now.Location())
+	if !at.After(now) {
+		at = time.Date(y, m, d+1, offset/3600, offset%3600/60, offset%60, 0, now.Location())
+	}
+	return at
+}
+
+func (t *Timer) GetTopic() string {
+	return t.topic
+}
+
+// GetTime 下一次发布的时间.
+func (t *Timer) GetTime() time.Time {
+	t.sched.mutex.Lock()
+	defer t.sched.mutex.Unlock()
+
+	return t.at
+}
+
+// Persist 以key持久化该定时事件, 数据需要可以json序列化, 已有相同key的定时事件会被取消.
+func (t *Timer) Persist(key string) error {
+	raw, err := json.Marshal(t.data)
+	if err != nil {
+		return fmt.Errorf("%w: topic %s data marshal failed, %v", ErrTimerInvalid, t.topic, err)
+	}
+
+	s := t.sched
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if t.index < 0 {
+		return ErrTimerCancelled
+	}
+
+	if old, ok := s.byKey[key]; ok && old != t {
+		s.remove(old)
+	}
+
+	t.key = key
+	t.raw = raw
+	s.byKey[key] = t
+	s.dirty = true
+
+	return nil
+}
+
+// Cancel 取消定时事件, 已取消或已发布的一次性事件忽略.
+func (t *Timer) Cancel() {
+	t.sched.mutex.Lock()
+	defer t.sched.mutex.Unlock()
+
+	t.sched.remove(t)
+}
+
+func (s *scheduler) add(t *Timer) (*Timer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t.sched = s
+	heap.Push(&s.timers, t)
+	return t, nil
+}
+
+// remove 需要持有mutex.
+func (s *scheduler) remove(t *Timer) {
+	if t.index >= 0 {
+		heap.Remove(&s.timers, t.index)
+	}
+
+	if t.key != "" && s.byKey[t.key] == t {
+		delete(s.byKey, t.key)
+		s.dirty = true
+	}
+}
+
+func (s *scheduler) tick(now time.Time) int {
+	var due []*Timer
+
+	s.mutex.Lock()
+	for len(s.timers) > 0 && !s.timers[0].at.After(now) {
+		t := s.timers[0]
+		due = append(due, t)
+
+		switch {
+		case t.daily != _noDaily:
+			t.at = nextDaily(now, t.daily)
+			heap.Fix(&s.timers, 0)
+		case t.interval > 0:
+			t.at = t.at.Add(t.interval)
+			if !t.at.After(now) {
+				t.at = now.Add(t.interval)
+			}
+			heap.Fix(&s.timers, 0)
+		default:
+			heap.Pop(&s.timers)
+			if t.key != "" && s.byKey[t.key] == t {
+				delete(s.byKey, t.key)
+			}
+		}
+
+		if t.key != "" {
+			s.dirty = true
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, t := range due {
+		err := s.bus.publish(t.topic, t.data)
+		if err != nil {
+			log.Error("scheduled topic %s publish failed, err %v", t.topic, err)
+		}
+	}
+
+	s.save()
+
+	return len(due)
+}
+
+func (s *scheduler) save() {
+	s.mutex.Lock()
+	if !s.dirty || s.path == "" {
+		s.mutex.Unlock()
+		return
+	}
+
+	records := make([]*timerRecord, 0, len(s.byKey))
+	for _, t := range s.byKey {
+		records = append(records, &timerRecord{
+			Key:      t.key,
+			Topic:    t.topic,
+			At:       t.at.UnixNano() / int64(time.Millisecond),
+			Interval: int64(t.interval / time.Millisecond),
+			Daily:    t.daily,
+			Data:     t.raw,
+		})
+	}
+	path := s.path
+	s.dirty = false
+	s.mutex.Unlock()
+
+	data, err := json.Marshal(records)
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+
+	if err != nil {
+		log.Error("schedule save to %s failed, err %v", path, err)
+
+		s.mutex.Lock()
+		s.dirty = true
+		s.mutex.Unlock()
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+
+	err := ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (s *scheduler) load() error {
+	s.mutex.Lock()
+	path := s.path
+	s.mutex.Unlock()
+
+	if path == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var records []*timerRecord
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		return fmt.Errorf("schedule file %s unmarshal failed, %w", path, err)
+	}
+
+	count := 0
+	for _, r := range records {
+		value, err := s.bus.decodeData(r.Topic, r.Data)
+		if err != nil {
+			log.Error("schedule key %s topic %s restore failed, err %v", r.Key, r.Topic, err)
+			continue
+		}
+
+		t := &Timer{
+			sched:    s,
+			topic:    r.Topic,
+			data:     value,
+			at:       time.Unix(0, r.At*int64(time.Millisecond)),
+			interval: time.Duration(r.Interval) * time.Millisecond,
+			daily:    r.Daily,
+			key:      r.Key,
+			raw:      r.Data,
+		}
+
+		s.mutex.Lock()
+		if _, ok := s.byKey[r.Key]; !ok {
+			heap.Push(&s.timers, t)
+			s.byKey[r.Key] = t
+			count++
+		}
+		s.mutex.Unlock()
+	}
+
+	log.Info("schedule restore %d of %d timers from %s", count, len(records), path)
+
+	return nil
+}
+
+// decodeData 按事件订阅者的数据类型解析持久化的数据, 没有带类型的订阅者时解析为通用的json值.
+func (b *bus) decodeData(name string, raw json.RawMessage) (interface{}, error) {
+	b.mutex.RLock()
+	var dataType reflect.Type
+	if t, ok := b.topics[name]; ok {
+		dataType = t.dataType
+	}
+	b.mutex.RUnlock()
+
+	if dataType == nil {
+		var v interface{}
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	}
+
+	if dataType.Kind() == reflect.Ptr {
+		v := reflect.New(dataType.Elem())
+		err := json.Unmarshal(raw, v.Interface())
+		return v.Interface(), err
+	}
+
+	v := reflect.New(dataType)
+	err := json.Unmarshal(raw, v.Interface())
+	return v.Elem().Interface(), err
+}
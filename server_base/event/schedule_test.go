@@ -0,0 +1,77 @@
+package event
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type resetEvent struct {
+	Day int `json:"day"`
+}
+
+func TestScheduleTick(t *testing.T) {
+	b := newBus(16)
+	s := newScheduler(b)
+
+	var got []string
+	_, _ = b.subscribe("once", 0, func(e *resetEvent) { got = append(got, "once") })
+	_, _ = b.subscribe("cancel", 0, func(e *resetEvent) { got = append(got, "cancel") })
+	_, _ = b.subscribe("daily", 0, func(e *resetEvent) { got = append(got, "daily") })
+
+	now := time.Date(2021, 6, 1, 3, 0, 0, 0, time.Local)
+	_, _ = s.add(&Timer{topic: "once", data: &resetEvent{}, at: now.Add(30 * time.Second), daily: _noDaily})
+	cancel, _ := s.add(&Timer{topic: "cancel", data: &resetEvent{}, at: now.Add(time.Second), daily: _noDaily})
+	daily, _ := s.add(&Timer{topic: "daily", data: &resetEvent{}, at: nextDaily(now, 4*3600), daily: 4 * 3600})
+
+	cancel.Cancel()
+	cancel.Cancel()
+
+	if n := s.tick(now.Add(10 * time.Second)); n != 0 || len(got) != 0 {
+		t.Errorf("fail: early tick fired %d %v", n, got)
+	}
+
+	if n := s.tick(now.Add(time.Hour)); n != 2 || len(got) != 2 || got[0] != "once" || got[1] != "daily" {
+		t.Errorf("fail: tick fired %d %v", n, got)
+	}
+
+	next := time.Date(2021, 6, 2, 4, 0, 0, 0, time.Local)
+	if !daily.GetTime().Equal(next) || len(s.timers) != 1 {
+		t.Errorf("fail: daily next %v timers %d", daily.GetTime(), len(s.timers))
+	}
+}
+
+func TestSchedulePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	now := time.Now()
+
+	b := newBus(16)
+	s := newScheduler(b)
+	s.path = path
+
+	timer, _ := s.add(&Timer{topic: "close", data: &resetEvent{Day: 3}, at: now.Add(time.Minute), daily: _noDaily})
+	if err := timer.Persist("activity_close"); err != nil {
+		t.Fatalf("persist failed: %v", err)
+	}
+	s.tick(now)
+
+	var got []int
+	b2 := newBus(16)
+	_, _ = b2.subscribe("close", 0, func(e *resetEvent) { got = append(got, e.Day) })
+	s2 := newScheduler(b2)
+	s2.path = path
+
+	if err := s2.load(); err != nil || len(s2.timers) != 1 {
+		t.Fatalf("fail: load err %v timers %d", err, len(s2.timers))
+	}
+
+	if n := s2.tick(now.Add(2 * time.Minute)); n != 1 || len(got) != 1 || got[0] != 3 {
+		t.Errorf("fail: restored fired %d %v", n, got)
+	}
+
+	s3 := newScheduler(newBus(16))
+	s3.path = path
+	if err := s3.load(); err != nil || len(s3.timers) != 0 {
+		t.Errorf("fail: fired timer still persisted, err %v timers %d", err, len(s3.timers))
+	}
+}
@@ -3,27 +3,54 @@ package msg
 import (
 	"encoding/binary"
 	"errors"
-	"strings"
+	"fmt"
+	"sync"
 
-	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
 	"github.com/nearmeng/mango-go/proto/csproto"
 	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/reflect/protoreflect"
-	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 type CSCodec interface {
 	Encode(header *csproto.SCHead, body proto.Message) ([]byte, error)
-	Decode(data []byte) (*csproto.CSHead, proto.Message)
+	// Decode header解析成功而消息体失败时, 同时返回header和错误, 用于错误回包
+	Decode(data []byte) (*csproto.CSHead, proto.Message, error)
 }
 
+// BufferCSCodec 可选接口, 直接编码到池化的transport.Buffer.
+type BufferCSCodec interface {
+	EncodeBuffer(header *csproto.SCHead, body proto.Message) (*transport.Buffer, error)
+}
+
+var (
+	ErrMsgTruncated    = errors.New("msg: data truncated")
+	ErrMsgTooLarge     = errors.New("msg: data too large")
+	ErrMsgUnknownMsgID = errors.New("msg: unknown msgid")
+	ErrMsgMalformed    = errors.New("msg: malformed data")
+	ErrCodecNotExist   = errors.New("msg: codec not exist")
+)
+
 var (
 	CODEC_DEFAULT = "default"
+	CODEC_JSON    = "json"
+	// CODEC_AUTO 根据连接收到的第一个包在default和json之间选择
+	CODEC_AUTO = "auto"
 )
 
+// CSCodecCfg 按监听器名字指定codec, 未指定的监听器使用default.
+type CSCodecCfg struct {
+	Listeners map[string]string `mapstructure:"listeners"`
+}
+
 var (
 	_csCodecFactory = make(map[string]CSCodec)
 	_maxBuffSize    = 500 * 1024
+
+	_listenerCodecMutex sync.RWMutex
+	_listenerCodec      = make(map[string]string)
+
+	// 调用MarshalAppend之前已经通过proto.Size计算过大小
+	_marshalOpt = proto.MarshalOptions{UseCachedSize: true}
 )
 
 func registerCodec(t string, code CSCodec) {
@@ -34,70 +61,215 @@ func getCodec(t string) CSCodec {
 	return _csCodecFactory[t]
 }
 
+// SetCSCodecCfg 设置监听器使用的codec, reload时可重复调用, 已经确定codec的会话不受影响.
+func SetCSCodecCfg(cfg *CSCodecCfg) error {
+	listeners := make(map[string]string, len(cfg.Listeners))
+	for name, codec := range cfg.Listeners {
+		if codec != CODEC_AUTO && getCodec(codec) == nil {
+			return fmt.Errorf("%w: listener %s codec %s", ErrCodecNotExist, name, codec)
+		}
+		listeners[name] = codec
+	}
+
+	_listenerCodecMutex.Lock()
+	defer _listenerCodecMutex.Unlock()
+
+	_listenerCodec = listeners
+
+	return nil
+}
+
+func getListenerCodec(listener string) string {
+	_listenerCodecMutex.RLock()
+	defer _listenerCodecMutex.RUnlock()
+
+	if name, ok := _listenerCodec[listener]; ok {
+		return name
+	}
+	return CODEC_DEFAULT
+}
+
+// SetSessionCodec 为会话指定codec, 用于客户端通过协议协商codec, 之后的收发都使用该codec.
+func SetSessionCodec(s *ClientSession, name string) error {
+	if getCodec(name) == nil {
+		return fmt.Errorf("%w: %s", ErrCodecNotExist, name)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.codec = name
+	return nil
+}
+
+// detectCodec header以'{'开头时为json, protobuf编码的CSHead不会以该字节开头.
+func detectCodec(data []byte) string {
+	if len(data) <= 4 {
+		return CODEC_DEFAULT
+	}
+
+	for _, b := range data[4:] {
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+
+		if b == '{' {
+			return CODEC_JSON
+		}
+		break
+	}
+
+	return CODEC_DEFAULT
+}
+
+// getRecvCodecName 获取连接收包使用的codec, 首次收包时确定并保存在会话上.
+func getRecvCodecName(conn transport.Conn, data []byte) string {
+	sess := GetSession(conn)
+	if sess != nil {
+		if name := sess.GetCodecName(); name != "" {
+			return name
+		}
+	}
+
+	name := getListenerCodec(transport.GetConnListenerName(conn))
+	if name == CODEC_AUTO {
+		name = detectCodec(data)
+	}
+
+	if sess != nil {
+		sess.mutex.Lock()
+		if sess.codec == "" {
+			sess.codec = name
+		}
+		name = sess.codec
+		sess.mutex.Unlock()
+	}
+
+	return name
+}
+
+// getSendCodecName auto的监听器在收到第一个包之前使用default.
+func getSendCodecName(sess *ClientSession, conn transport.Conn) string {
+	if sess != nil {
+		if name := sess.GetCodecName(); name != "" {
+			return name
+		}
+	}
+
+	if conn == nil {
+		return CODEC_DEFAULT
+	}
+
+	name := getListenerCodec(transport.GetConnListenerName(conn))
+	if name == CODEC_AUTO {
+		return CODEC_DEFAULT
+	}
+	return name
+}
+
 type DefaultCSCodec struct {
 }
 
-func (c *DefaultCSCodec) Encode(header *csproto.SCHead, body proto.Message) ([]byte, error) {
-	buff := make([]byte, _maxBuffSize)
+// encodeTo 按应用层格式将header和body直接序列化进buff, 长度需为4+headerSize+bodySize.
+func encodeTo(buff []byte, header *csproto.SCHead, body proto.Message, headerSize int, bodySize int) error {
+	binary.LittleEndian.PutUint32(buff[0:4], uint32(headerSize))
+
+	data, err := _marshalOpt.MarshalAppend(buff[4:4], header)
+	if err != nil {
+		return err
+	}
+
+	if len(data) != headerSize {
+		return fmt.Errorf("%w: header size changed %d -> %d", ErrMsgMalformed, headerSize, len(data))
+	}
+
+	data, err = _marshalOpt.MarshalAppend(buff[4+headerSize:4+headerSize], body)
+	if err != nil {
+		return err
+	}
+
+	if len(data) != bodySize {
+		return fmt.Errorf("%w: body size changed %d -> %d", ErrMsgMalformed, bodySize, len(data))
+	}
+
+	return nil
+}
+
+func (c *DefaultCSCodec) messageSize(header *csproto.SCHead, body proto.Message) (int, int, error) {
+	headerSize := proto.Size(header)
+	bodySize := proto.Size(body)
 
-	data, err := proto.Marshal(header)
+	if 4+headerSize+bodySize > _maxBuffSize {
+		return 0, 0, fmt.Errorf("%w: size %d max buff size %d", ErrMsgTooLarge, 4+headerSize+bodySize, _maxBuffSize)
+	}
+
+	return headerSize, bodySize, nil
+}
+
+func (c *DefaultCSCodec) Encode(header *csproto.SCHead, body proto.Message) ([]byte, error) {
+	headerSize, bodySize, err := c.messageSize(header, body)
 	if err != nil {
 		return nil, err
 	}
 
-	headerSize := len(data)
-	binary.LittleEndian.PutUint32(buff[0:4], uint32(headerSize))
+	buff := make([]byte, 4+headerSize+bodySize)
 
-	n := copy(buff[4:], data)
-	if n != headerSize {
-		return nil, errors.New("buff copy failed")
+	err = encodeTo(buff, header, body, headerSize, bodySize)
+	if err != nil {
+		return nil, err
 	}
 
-	data, err = proto.Marshal(body)
+	return buff, nil
+}
+
+// EncodeBuffer 序列化进池化的Buffer, 并为transport预留帧头空间.
+func (c *DefaultCSCodec) EncodeBuffer(header *csproto.SCHead, body proto.Message) (*transport.Buffer, error) {
+	headerSize, bodySize, err := c.messageSize(header, body)
 	if err != nil {
 		return nil, err
 	}
 
-	n = copy(buff[4+headerSize:], data)
-	if n != len(data) {
-		return nil, errors.New("buff copy failed")
+	buf := transport.AcquireBuffer(transport.FrameHeadroom + 4 + headerSize + bodySize)
+
+	err = encodeTo(buf.B[transport.FrameHeadroom:], header, body, headerSize, bodySize)
+	if err != nil {
+		transport.ReleaseBuffer(buf)
+		return nil, err
 	}
 
-	return buff[0 : 4+int(headerSize)+n], nil
+	return buf, nil
 }
 
-func (c *DefaultCSCodec) Decode(data []byte) (*csproto.CSHead, proto.Message) {
+func (c *DefaultCSCodec) Decode(data []byte) (*csproto.CSHead, proto.Message, error) {
 	var header csproto.CSHead
 
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("%w: data size %d", ErrMsgTruncated, len(data))
+	}
+
 	headerSize := binary.LittleEndian.Uint32(data[0:4])
+	if uint64(headerSize) > uint64(len(data)-4) {
+		return nil, nil, fmt.Errorf("%w: header size %d data size %d", ErrMsgTruncated, headerSize, len(data))
+	}
+
 	err := proto.Unmarshal(data[4:4+headerSize], &header)
 	if err != nil {
-		log.Error("proto unmarshal failed")
-		return nil, nil
+		return nil, nil, fmt.Errorf("%w: header unmarshal failed, %v", ErrMsgMalformed, err)
 	}
 
 	msgid := header.GetMsgid()
-	msgStr, ok := csproto.CSMessageID_name[msgid]
-	if !ok {
-		log.Error("msgid %d is not implement in proto", msgid)
-		return nil, nil
-	}
-
-	msgName := protoreflect.FullName("proto." + strings.ToUpper(msgStr))
-	msgType, err := protoregistry.GlobalTypes.FindMessageByName(msgName)
-	if err != nil {
-		log.Error("find message by name %s failed, err %v", msgName, err)
-		return nil, nil
+	info := GetMsgInfo(MSG_DIRECTION_CS, msgid)
+	if info == nil {
+		return &header, nil, fmt.Errorf("%w: msgid %d is not register", ErrMsgUnknownMsgID, msgid)
 	}
 
-	msg := msgType.New().Interface()
+	msg := info.Type.New().Interface()
 	err = proto.Unmarshal(data[4+headerSize:], msg)
 	if err != nil {
-		log.Error("proto unmarshal failed")
-		return nil, nil
+		return &header, nil, fmt.Errorf("%w: body unmarshal failed, %v", ErrMsgMalformed, err)
 	}
 
-	return &header, msg
+	return &header, msg, nil
 }
 
 func init() {
@@ -0,0 +1,60 @@
+package msg
+
+import (
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+func benchMessage() (*csproto.SCHead, *csproto.CS_LOGIN) {
+	header := &csproto.SCHead{Msgid: int32(csproto.SCMessageID_sc_login), Seqid: 100}
+	body := &csproto.CS_LOGIN{
+		Name:    "benchmark player name",
+		Sex:     "male",
+		Account: &csproto.Account{Id: 10001, Num: 99},
+	}
+
+	return header, body
+}
+
+// BenchmarkEncodeLegacy CSCodec.Encode后再由transport.Codec.Encode拷贝一次.
+func BenchmarkEncodeLegacy(b *testing.B) {
+	codec := &DefaultCSCodec{}
+	frameCodec := &transport.DefaultCodec{}
+	header, body := benchMessage()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := codec.Encode(header, body)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := frameCodec.Encode(nil, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncodeBuffer 直接序列化进池化Buffer并原地写入帧头.
+func BenchmarkEncodeBuffer(b *testing.B) {
+	codec := &DefaultCSCodec{}
+	frameCodec := &transport.DefaultCodec{}
+	header, body := benchMessage()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, err := codec.EncodeBuffer(header, body)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		frame, err := frameCodec.EncodeFrame(nil, buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		transport.ReleaseBuffer(frame)
+	}
+}
@@ -0,0 +1,86 @@
+package msg
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	_jsonMarshalOpt   = protojson.MarshalOptions{}
+	_jsonUnmarshalOpt = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// JSONCSCodec 与DefaultCSCodec的包结构相同, 4字节header长度后接header和body, 但header和body为protojson.
+// 消息体为空对象时可以省略, 用于web工具和调试客户端.
+// 客户端不方便组二进制帧时, tcp监听器可以配置framing: ndjson, 每行收发一个{"head": ..., "body": ...}对象.
+type JSONCSCodec struct {
+}
+
+func (c *JSONCSCodec) Encode(header *csproto.SCHead, body proto.Message) ([]byte, error) {
+	headerData, err := _jsonMarshalOpt.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodyData []byte
+	if body != nil {
+		bodyData, err = _jsonMarshalOpt.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	size := 4 + len(headerData) + len(bodyData)
+	if size > _maxBuffSize {
+		return nil, fmt.Errorf("%w: size %d max buff size %d", ErrMsgTooLarge, size, _maxBuffSize)
+	}
+
+	buff := make([]byte, 4, size)
+	binary.LittleEndian.PutUint32(buff, uint32(len(headerData)))
+	buff = append(buff, headerData...)
+	buff = append(buff, bodyData...)
+
+	return buff, nil
+}
+
+func (c *JSONCSCodec) Decode(data []byte) (*csproto.CSHead, proto.Message, error) {
+	var header csproto.CSHead
+
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("%w: data size %d", ErrMsgTruncated, len(data))
+	}
+
+	headerSize := binary.LittleEndian.Uint32(data[0:4])
+	if uint64(headerSize) > uint64(len(data)-4) {
+		return nil, nil, fmt.Errorf("%w: header size %d data size %d", ErrMsgTruncated, headerSize, len(data))
+	}
+
+	err := _jsonUnmarshalOpt.Unmarshal(data[4:4+headerSize], &header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: header unmarshal failed, %v", ErrMsgMalformed, err)
+	}
+
+	msgid := header.GetMsgid()
+	info := GetMsgInfo(MSG_DIRECTION_CS, msgid)
+	if info == nil {
+		return &header, nil, fmt.Errorf("%w: msgid %d is not register", ErrMsgUnknownMsgID, msgid)
+	}
+
+	msg := info.Type.New().Interface()
+	if bodyData := data[4+headerSize:]; len(bodyData) > 0 {
+		err = _jsonUnmarshalOpt.Unmarshal(bodyData, msg)
+		if err != nil {
+			return &header, nil, fmt.Errorf("%w: body unmarshal failed, %v", ErrMsgMalformed, err)
+		}
+	}
+
+	return &header, msg, nil
+}
+
+func init() {
+	registerCodec(CODEC_JSON, &JSONCSCodec{})
+}
@@ -0,0 +1,82 @@
+package msg
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+func buildJSONData(header string, body string) []byte {
+	data := make([]byte, 4, 4+len(header)+len(body))
+	binary.LittleEndian.PutUint32(data, uint32(len(header)))
+	data = append(data, header...)
+	return append(data, body...)
+}
+
+func TestJSONCSCodec(t *testing.T) {
+	codec := &JSONCSCodec{}
+
+	header, msg, err := codec.Decode(buildJSONData(`{"msgid": 1, "seqid": 3}`, `{"name": "web"}`))
+	if err != nil || header.GetSeqid() != 3 || msg.(*csproto.CS_LOGIN).GetName() != "web" {
+		t.Fatalf("fail: header %v msg %v err %v", header, msg, err)
+	}
+
+	// 空消息体
+	_, msg, err = codec.Decode(buildJSONData(`{"msgid": 1}`, ``))
+	if err != nil || msg.(*csproto.CS_LOGIN).GetName() != "" {
+		t.Errorf("fail: empty body msg %v err %v", msg, err)
+	}
+
+	if detectCodec(buildJSONData(` {"msgid": 1}`, ``)) != CODEC_JSON {
+		t.Errorf("fail: detect json")
+	}
+
+	data := buildCSData(t, &csproto.CSHead{Msgid: 1}, &csproto.CS_LOGIN{Name: "pb"})
+	if detectCodec(data) != CODEC_DEFAULT {
+		t.Errorf("fail: detect default")
+	}
+}
+
+func TestAutoCodecSession(t *testing.T) {
+	msgid := int32(csproto.CSMessageID_cs_login)
+
+	err := RegisterTypedHandler(func(conn transport.Conn, req *csproto.CS_LOGIN) *csproto.SC_LOGIN {
+		return &csproto.SC_LOGIN{Success: 2}
+	})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	defer func() {
+		delete(msgHandlerMgr.clientMsgHandler, msgid)
+		_middlewareMgr.reset(msgid)
+	}()
+
+	// fakeConn不属于任何监听器, 对应名字为空的监听器
+	err = SetCSCodecCfg(&CSCodecCfg{Listeners: map[string]string{"": CODEC_AUTO}})
+	if err != nil {
+		t.Fatalf("set codec cfg failed: %v", err)
+	}
+	defer SetCSCodecCfg(&CSCodecCfg{})
+
+	conn := &recordConn{fakeConn: fakeConn{id: 9001}}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	RecvClientMsg(conn, buildJSONData(`{"msgid": 1, "seqid": 4}`, `{"name": "a"}`))
+
+	if GetSession(conn).GetCodecName() != CODEC_JSON || len(conn.sent) != 1 {
+		t.Fatalf("fail: codec %s sent %d", GetSession(conn).GetCodecName(), len(conn.sent))
+	}
+
+	rsp := conn.sent[0]
+	headerSize := binary.LittleEndian.Uint32(rsp)
+	var header csproto.SCHead
+	var body csproto.SC_LOGIN
+	if _jsonUnmarshalOpt.Unmarshal(rsp[4:4+headerSize], &header) != nil ||
+		_jsonUnmarshalOpt.Unmarshal(rsp[4+headerSize:], &body) != nil ||
+		header.GetSeqid() != 4 || body.GetSuccess() != 2 {
+		t.Errorf("fail: json rsp %s", rsp[4:])
+	}
+}
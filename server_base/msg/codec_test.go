@@ -0,0 +1,145 @@
+package msg
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+)
+
+type quietLogger struct {
+	log.MockLogger
+}
+
+func (*quietLogger) GetLevel() int {
+	return log.LogLevelFatal + 1
+}
+
+func TestMain(m *testing.M) {
+	log.SetLogger(&quietLogger{})
+
+	err := LoadMsgRegistryFromOptions()
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// decodeSC 解析服务器下发的包, 用于校验回包.
+func decodeSC(data []byte) (*csproto.SCHead, proto.Message, error) {
+	var header csproto.SCHead
+
+	headerSize := binary.LittleEndian.Uint32(data[0:4])
+	err := proto.Unmarshal(data[4:4+headerSize], &header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 错误回包没有消息体
+	if header.GetResult() != RESULT_OK {
+		return &header, nil, nil
+	}
+
+	info := GetMsgInfo(MSG_DIRECTION_SC, header.GetMsgid())
+	if info == nil {
+		return nil, nil, ErrMsgUnknownMsgID
+	}
+
+	body := info.Type.New().Interface()
+	err = proto.Unmarshal(data[4+headerSize:], body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &header, body, nil
+}
+
+func buildCSData(t testing.TB, header *csproto.CSHead, body proto.Message) []byte {
+	headerData, err := proto.Marshal(header)
+	if err != nil {
+		t.Fatalf("marshal header failed: %v", err)
+	}
+
+	bodyData, err := proto.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal body failed: %v", err)
+	}
+
+	data := make([]byte, 4+len(headerData)+len(bodyData))
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(headerData)))
+	copy(data[4:], headerData)
+	copy(data[4+len(headerData):], bodyData)
+
+	return data
+}
+
+func TestDefaultCSCodecDecode(t *testing.T) {
+	codec := &DefaultCSCodec{}
+
+	data := buildCSData(t, &csproto.CSHead{Msgid: int32(csproto.CSMessageID_cs_login), Seqid: 7},
+		&csproto.CS_LOGIN{Name: "test"})
+
+	header, msg, err := codec.Decode(data)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	if header.GetSeqid() != 7 || msg.(*csproto.CS_LOGIN).GetName() != "test" {
+		t.Errorf("fail: decoded content mismatch")
+	}
+}
+
+func TestDefaultCSCodecDecodeErrors(t *testing.T) {
+	codec := &DefaultCSCodec{}
+
+	_, _, err := codec.Decode([]byte{1, 0})
+	if !errors.Is(err, ErrMsgTruncated) {
+		t.Errorf("fail: expect truncated, got %v", err)
+	}
+
+	_, _, err = codec.Decode([]byte{0xFF, 0xFF, 0xFF, 0xFF, 1})
+	if !errors.Is(err, ErrMsgTruncated) {
+		t.Errorf("fail: expect truncated on header size, got %v", err)
+	}
+
+	data := buildCSData(t, &csproto.CSHead{Msgid: 1000}, &csproto.CS_LOGIN{})
+	_, _, err = codec.Decode(data)
+	if !errors.Is(err, ErrMsgUnknownMsgID) {
+		t.Errorf("fail: expect unknown msgid, got %v", err)
+	}
+
+	_, _, err = codec.Decode([]byte{2, 0, 0, 0, 0xFF, 0xFF})
+	if !errors.Is(err, ErrMsgMalformed) {
+		t.Errorf("fail: expect malformed, got %v", err)
+	}
+}
+
+func FuzzDefaultCSCodecDecode(f *testing.F) {
+	f.Add(buildCSData(f, &csproto.CSHead{Msgid: int32(csproto.CSMessageID_cs_login), Seqid: 1},
+		&csproto.CS_LOGIN{Name: "fuzz", Account: &csproto.Account{Id: 1}}))
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0x7F})
+	f.Add([]byte{})
+
+	codec := &DefaultCSCodec{}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, msg, err := codec.Decode(data)
+		if err != nil {
+			return
+		}
+
+		if header == nil || msg == nil {
+			t.Fatalf("decode returned nil without error")
+		}
+
+		scHeader := &csproto.SCHead{Msgid: header.GetMsgid(), Seqid: header.GetSeqid()}
+		if _, err := codec.Encode(scHeader, msg); err != nil {
+			t.Fatalf("re-encode failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package msg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+// result code, 1000以下为框架保留.
+const (
+	RESULT_OK                = 0
+	RESULT_ERR_DECODE        = 1
+	RESULT_ERR_UNKNOWN_MSGID = 2
+	RESULT_ERR_NO_HANDLER    = 3
+	RESULT_ERR_RATE_LIMIT    = 4
+	RESULT_ERR_NOT_AUTH      = 5
+	RESULT_ERR_INTERNAL      = 6
+	RESULT_ERR_NO_HANDSHAKE  = 7
+
+	RESULT_USER_BEGIN = 1000
+)
+
+var (
+	ErrErrCodeDuplicate = errors.New("msg: duplicate error code registration")
+	ErrErrCodeReserved  = errors.New("msg: error code is reserved by framework")
+)
+
+var (
+	_errCodeMutex sync.RWMutex
+	_errCodeDesc  = map[int32]string{
+		RESULT_OK:                "ok",
+		RESULT_ERR_DECODE:        "decode failed",
+		RESULT_ERR_UNKNOWN_MSGID: "unknown msgid",
+		RESULT_ERR_NO_HANDLER:    "no handler",
+		RESULT_ERR_RATE_LIMIT:    "rate limit exceed",
+		RESULT_ERR_NOT_AUTH:      "not auth",
+		RESULT_ERR_INTERNAL:      "internal error",
+		RESULT_ERR_NO_HANDSHAKE:  "not handshake",
+	}
+)
+
+// RegisterErrCode 注册业务错误码及描述, 错误码需不小于RESULT_USER_BEGIN.
+func RegisterErrCode(code int32, desc string) error {
+	if code < RESULT_USER_BEGIN {
+		return fmt.Errorf("%w: code %d", ErrErrCodeReserved, code)
+	}
+
+	_errCodeMutex.Lock()
+	defer _errCodeMutex.Unlock()
+
+	if old, ok := _errCodeDesc[code]; ok {
+		return fmt.Errorf("%w: code %d already register by %s", ErrErrCodeDuplicate, code, old)
+	}
+
+	_errCodeDesc[code] = desc
+	return nil
+}
+
+// GetErrCodeDesc 获取错误码描述, 未注册时返回空串.
+func GetErrCodeDesc(code int32) string {
+	_errCodeMutex.RLock()
+	defer _errCodeMutex.RUnlock()
+
+	return _errCodeDesc[code]
+}
+
+// ResultError 携带错误码的错误, 处理函数返回时框架以其错误码回包.
+type ResultError struct {
+	Code int32
+	Msg  string
+}
+
+func NewResultError(code int32, msg string) *ResultError {
+	return &ResultError{Code: code, Msg: msg}
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("result %d: %s", e.Code, e.Msg)
+}
+
+// GetResultCode 获取err对应的错误码, 非ResultError时为RESULT_ERR_INTERNAL.
+func GetResultCode(err error) int32 {
+	if err == nil {
+		return RESULT_OK
+	}
+
+	var re *ResultError
+	if errors.As(err, &re) {
+		return re.Code
+	}
+
+	return RESULT_ERR_INTERNAL
+}
+
+// SendErrorToClient 发送只有SCHead没有消息体的错误回包, errmsg为空时填充错误码描述.
+func SendErrorToClient(conn transport.Conn, msgid int32, seqid int32, code int32, errmsg string) error {
+	if errmsg == "" {
+		errmsg = GetErrCodeDesc(code)
+	}
+
+	header := &csproto.SCHead{
+		Msgid:  msgid,
+		Seqid:  seqid,
+		Result: code,
+		Errmsg: errmsg,
+	}
+
+	return SendToClient(conn, header, nil)
+}
+
+// replyError 框架产生的错误回包, 消息号与请求相同, 客户端通过seqid匹配请求.
+func replyError(conn transport.Conn, header *csproto.CSHead, code int32) {
+	err := SendErrorToClient(conn, header.GetMsgid(), header.GetSeqid(), code, "")
+	if err != nil {
+		log.Error("conn %v msgid %d reply error %d failed, err %v", conn.GetConnID(), header.GetMsgid(), code, err)
+	}
+}
@@ -0,0 +1,85 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+func TestRegisterErrCode(t *testing.T) {
+	err := RegisterErrCode(RESULT_ERR_INTERNAL, "x")
+	if !errors.Is(err, ErrErrCodeReserved) {
+		t.Errorf("fail: expect reserved, err %v", err)
+	}
+
+	err = RegisterErrCode(RESULT_USER_BEGIN+1, "item not enough")
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	err = RegisterErrCode(RESULT_USER_BEGIN+1, "dup")
+	if !errors.Is(err, ErrErrCodeDuplicate) {
+		t.Errorf("fail: expect duplicate, err %v", err)
+	}
+
+	if GetErrCodeDesc(RESULT_USER_BEGIN+1) != "item not enough" {
+		t.Errorf("fail: desc %s", GetErrCodeDesc(RESULT_USER_BEGIN+1))
+	}
+}
+
+func TestAutoErrorResponse(t *testing.T) {
+	conn := &recordConn{fakeConn: fakeConn{id: 6001}}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	msgid := int32(csproto.CSMessageID_cs_login)
+
+	// 未注册处理函数
+	RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: msgid, Seqid: 1}, &csproto.CS_LOGIN{}))
+
+	// 未注册的消息号
+	RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: 1000, Seqid: 2}, &csproto.CS_LOGIN{}))
+
+	err := RegisterTypedHandler(func(conn transport.Conn, req *csproto.CS_LOGIN) (*csproto.SC_LOGIN, error) {
+		if req.GetName() == "" {
+			panic("empty name")
+		}
+		return nil, NewResultError(RESULT_USER_BEGIN+2, "bad name")
+	})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	UseMsgMiddleware(msgid, RecoveryMiddleware())
+	defer func() {
+		delete(msgHandlerMgr.clientMsgHandler, msgid)
+		delete(_middlewareMgr.msg, msgid)
+		_middlewareMgr.reset(msgid)
+	}()
+
+	RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: msgid, Seqid: 3}, &csproto.CS_LOGIN{Name: "a"}))
+	RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: msgid, Seqid: 4}, &csproto.CS_LOGIN{}))
+
+	expect := []struct {
+		seqid  int32
+		result int32
+		errmsg string
+	}{
+		{1, RESULT_ERR_NO_HANDLER, "no handler"},
+		{2, RESULT_ERR_UNKNOWN_MSGID, "unknown msgid"},
+		{3, RESULT_USER_BEGIN + 2, "bad name"},
+		{4, RESULT_ERR_INTERNAL, "internal error"},
+	}
+
+	if len(conn.sent) != len(expect) {
+		t.Fatalf("fail: sent %d", len(conn.sent))
+	}
+
+	for i, e := range expect {
+		header, _, _ := decodeSC(conn.sent[i])
+		if header == nil || header.GetSeqid() != e.seqid || header.GetResult() != e.result || header.GetErrmsg() != e.errmsg {
+			t.Errorf("fail: index %d header %v", i, header)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package msg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrGroupExist     = errors.New("msg: group already exist")
+	ErrGroupDestroyed = errors.New("msg: group destroyed")
+	ErrSessionClosed  = errors.New("msg: session closed")
+)
+
+// Group 房间/频道, 成员为会话, 会话销毁时自动退出所有分组.
+// 可恢复的会话断线期间仍保留在分组中, 组播的包被缓存用于重连后重发.
+type Group struct {
+	id        string
+	members   map[*ClientSession]struct{}
+	destroyed bool
+}
+
+// groupMgr 所有分组及会话所在分组的关系由同一把锁保护.
+type groupMgr struct {
+	mutex  sync.RWMutex
+	groups map[string]*Group
+}
+
+var (
+	_groupMgr = &groupMgr{
+		groups: make(map[string]*Group),
+	}
+)
+
+// CreateGroup 创建分组, id已存在时返回ErrGroupExist.
+func CreateGroup(id string) (*Group, error) {
+	_groupMgr.mutex.Lock()
+	defer _groupMgr.mutex.Unlock()
+
+	if _, ok := _groupMgr.groups[id]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupExist, id)
+	}
+
+	g := &Group{
+		id:      id,
+		members: make(map[*ClientSession]struct{}),
+	}
+	_groupMgr.groups[id] = g
+
+	return g, nil
+}
+
+// DestroyGroup 销毁分组并移除所有成员, 分组不存在时忽略.
+func DestroyGroup(id string) {
+	_groupMgr.mutex.Lock()
+	defer _groupMgr.mutex.Unlock()
+
+	g, ok := _groupMgr.groups[id]
+	if !ok {
+		return
+	}
+
+	for s := range g.members {
+		delete(s.groups, g)
+	}
+
+	g.members = nil
+	g.destroyed = true
+	delete(_groupMgr.groups, id)
+}
+
+func GetGroup(id string) *Group {
+	_groupMgr.mutex.RLock()
+	defer _groupMgr.mutex.RUnlock()
+
+	return _groupMgr.groups[id]
+}
+
+func GetGroupCount() int {
+	_groupMgr.mutex.RLock()
+	defer _groupMgr.mutex.RUnlock()
+
+	return len(_groupMgr.groups)
+}
+
+func (g *Group) GetID() string {
+	return g.id
+}
+
+// Join 会话加入分组, 重复加入不报错.
+func (g *Group) Join(s *ClientSession) error {
+	_groupMgr.mutex.Lock()
+	defer _groupMgr.mutex.Unlock()
+
+	if g.destroyed {
+		return fmt.Errorf("%w: %s", ErrGroupDestroyed, g.id)
+	}
+
+	if s.groupsClosed {
+		return ErrSessionClosed
+	}
+
+	if s.groups == nil {
+		s.groups = make(map[*Group]struct{})
+	}
+
+	g.members[s] = struct{}{}
+	s.groups[g] = struct{}{}
+
+	return nil
+}
+
+// Leave 会话退出分组, 不在分组中时忽略.
+func (g *Group) Leave(s *ClientSession) {
+	_groupMgr.mutex.Lock()
+	defer _groupMgr.mutex.Unlock()
+
+	delete(g.members, s)
+	delete(s.groups, g)
+}
+
+func (g *Group) HasMember(s *ClientSession) bool {
+	_groupMgr.mutex.RLock()
+	defer _groupMgr.mutex.RUnlock()
+
+	_, ok := g.members[s]
+	return ok
+}
+
+func (g *Group) GetMemberCount() int {
+	_groupMgr.mutex.RLock()
+	defer _groupMgr.mutex.RUnlock()
+
+	return len(g.members)
+}
+
+// GetMembers 获取成员的快照.
+func (g *Group) GetMembers() []*ClientSession {
+	_groupMgr.mutex.RLock()
+	defer _groupMgr.mutex.RUnlock()
+
+	result := make([]*ClientSession, 0, len(g.members))
+	for s := range g.members {
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// Multicast 向分组的所有成员发送消息, exclude中的会话不发送, 每种codec只编码一次.
+func (g *Group) Multicast(header *csproto.SCHead, msg proto.Message, exclude ...*ClientSession) error {
+	members := g.GetMembers()
+
+	if len(exclude) > 0 {
+		n := 0
+		for _, s := range members {
+			if !containsSession(exclude, s) {
+				members[n] = s
+				n++
+			}
+		}
+		members = members[:n]
+	}
+
+	return SendToSessions(members, header, msg)
+}
+
+// GetSessionGroups 获取会话所在的分组.
+func GetSessionGroups(s *ClientSession) []*Group {
+	_groupMgr.mutex.RLock()
+	defer _groupMgr.mutex.RUnlock()
+
+	result := make([]*Group, 0, len(s.groups))
+	for g := range s.groups {
+		result = append(result, g)
+	}
+
+	return result
+}
+
+// leaveAllGroups 会话销毁时退出所有分组, 之后不能再加入分组.
+func (s *ClientSession) leaveAllGroups() {
+	_groupMgr.mutex.Lock()
+	defer _groupMgr.mutex.Unlock()
+
+	for g := range s.groups {
+		delete(g.members, s)
+	}
+
+	s.groups = nil
+	s.groupsClosed = true
+}
+
+func containsSession(list []*ClientSession, s *ClientSession) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SendToSessions 向多个会话发送同一条消息, 每种codec只编码一次, 使用相同codec的连接共享编码后的数据.
+// 连接的transport codec支持时每种帧格式也只组帧一次, 压缩等处理不会逐个连接重复执行.
+// 单个会话发送失败只记录日志, 不影响其他会话.
+func SendToSessions(sessions []*ClientSession, header *csproto.SCHead, msg proto.Message) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	err := checkSCMsg(header.GetMsgid(), msg)
+	if err != nil {
+		log.Error("multicast msgid %d failed, err %v", header.GetMsgid(), err)
+		return err
+	}
+
+	encoded := make(map[string][]byte, 1)
+	frames := make(map[sharedFrameKey]*transport.Buffer, 1)
+	defer func() {
+		for _, frame := range frames {
+			transport.ReleaseBuffer(frame)
+		}
+	}()
+
+	for _, s := range sessions {
+		// 可恢复会话的每个包有自己的sc_seq, 不能共享编码结果
+		if s.isResumable() {
+			_ = SendToSession(s, header, msg)
+			continue
+		}
+
+		conn := s.GetConn()
+		codec := getSendCodecName(s, conn)
+
+		data, ok := encoded[codec]
+		if !ok {
+			data, err = getCodec(codec).Encode(header, msg)
+			if err != nil {
+				log.Error("multicast msgid %d codec %s encode failed, err %v", header.GetMsgid(), codec, err)
+				return err
+			}
+			encoded[codec] = data
+		}
+
+		if conn == nil {
+			continue
+		}
+
+		err = sendSharedFrame(conn, codec, data, frames)
+		if err != nil {
+			log.Error("conn %v multicast msgid %d send failed, err %v", conn.GetConnID(), header.GetMsgid(), err)
+			continue
+		}
+
+		logSCPacket(conn, s, header, msg)
+	}
+
+	return nil
+}
+
+type sharedFrameKey struct {
+	csCodec  string
+	codec    transport.Codec
+	frameKey uint32
+}
+
+// sendSharedFrame 连接的codec支持共享帧时, 相同codec和帧格式的连接只组帧一次, 否则退回conn.Send.
+func sendSharedFrame(conn transport.Conn, csCodec string, data []byte, frames map[sharedFrameKey]*transport.Buffer) error {
+	bufConn, ok := conn.(transport.BufferConn)
+	if !ok {
+		return conn.Send(data)
+	}
+
+	codec := bufConn.GetCodec()
+	frameCodec, ok1 := codec.(transport.FrameCodec)
+	keyCodec, ok2 := codec.(transport.FrameKeyCodec)
+	if !ok1 || !ok2 {
+		return conn.Send(data)
+	}
+
+	key := sharedFrameKey{csCodec: csCodec, codec: codec, frameKey: keyCodec.FrameKey(conn)}
+
+	frame, ok := frames[key]
+	if !ok {
+		buf := transport.AcquireBuffer(transport.FrameHeadroom + len(data))
+		copy(buf.B[transport.FrameHeadroom:], data)
+
+		var err error
+		frame, err = frameCodec.EncodeFrame(conn, buf)
+		if err != nil {
+			return err
+		}
+		frames[key] = frame
+	}
+
+	return bufConn.SendFrame(frame.B)
+}
@@ -0,0 +1,123 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+func TestGroupMulticast(t *testing.T) {
+	g, err := CreateGroup("room.1")
+	if err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	defer DestroyGroup("room.1")
+
+	_, err = CreateGroup("room.1")
+	if !errors.Is(err, ErrGroupExist) {
+		t.Errorf("fail: expect exist, err %v", err)
+	}
+
+	c1 := &recordConn{fakeConn: fakeConn{id: 7001}}
+	c2 := &recordConn{fakeConn: fakeConn{id: 7002}}
+	c3 := &recordConn{fakeConn: fakeConn{id: 7003}}
+	for _, c := range []*recordConn{c1, c2, c3} {
+		OnClientConnOpened(c)
+		_ = g.Join(GetSession(c))
+	}
+	defer OnClientConnClosed(c1, false)
+	defer OnClientConnClosed(c2, false)
+
+	err = g.Multicast(&csproto.SCHead{Msgid: int32(csproto.SCMessageID_sc_login)}, &csproto.SC_LOGIN{Success: 1},
+		GetSession(c3))
+	if err != nil {
+		t.Fatalf("multicast failed: %v", err)
+	}
+
+	if len(c1.sent) != 1 || len(c2.sent) != 1 || len(c3.sent) != 0 {
+		t.Fatalf("fail: sent %d %d %d", len(c1.sent), len(c2.sent), len(c3.sent))
+	}
+
+	// 编码结果在成员间共享
+	if &c1.sent[0][0] != &c2.sent[0][0] {
+		t.Errorf("fail: data not shared")
+	}
+
+	s3 := GetSession(c3)
+	OnClientConnClosed(c3, false)
+	if g.GetMemberCount() != 2 || g.HasMember(s3) {
+		t.Errorf("fail: member count %d after close", g.GetMemberCount())
+	}
+
+	if !errors.Is(g.Join(s3), ErrSessionClosed) {
+		t.Errorf("fail: closed session join")
+	}
+
+	DestroyGroup("room.1")
+	if len(GetSessionGroups(GetSession(c1))) != 0 || !errors.Is(g.Join(GetSession(c1)), ErrGroupDestroyed) {
+		t.Errorf("fail: group not destroyed")
+	}
+}
+
+type countFrameCodec struct {
+	transport.DefaultCodec
+	encodes int
+}
+
+func (c *countFrameCodec) Encode(conn transport.Conn, buff []byte) ([]byte, error) {
+	c.encodes++
+	return c.DefaultCodec.Encode(conn, buff)
+}
+
+func (c *countFrameCodec) EncodeFrame(conn transport.Conn, buf *transport.Buffer) (*transport.Buffer, error) {
+	c.encodes++
+	return c.DefaultCodec.EncodeFrame(conn, buf)
+}
+
+type frameConn struct {
+	recordConn
+	codec  transport.Codec
+	frames [][]byte
+}
+
+func (c *frameConn) GetCodec() transport.Codec { return c.codec }
+
+func (c *frameConn) SendBuffer(buf *transport.Buffer) error {
+	defer transport.ReleaseBuffer(buf)
+	return c.Send(buf.B[transport.FrameHeadroom:])
+}
+
+func (c *frameConn) SendFrame(frame []byte) error {
+	c.frames = append(c.frames, append([]byte(nil), frame...))
+	return nil
+}
+
+func TestSendToSessionsSharedFrame(t *testing.T) {
+	codec := &countFrameCodec{}
+
+	var sessions []*ClientSession
+	for i := 0; i < 3; i++ {
+		c := &frameConn{recordConn: recordConn{fakeConn: fakeConn{id: uint64(7101 + i)}}, codec: codec}
+		OnClientConnOpened(c)
+		defer OnClientConnClosed(c, false)
+		sessions = append(sessions, GetSession(c))
+	}
+
+	err := SendToSessions(sessions, &csproto.SCHead{Msgid: int32(csproto.SCMessageID_sc_login)}, &csproto.SC_LOGIN{Success: 1})
+	if err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	if codec.encodes != 1 {
+		t.Errorf("fail: frame encoded %d times", codec.encodes)
+	}
+
+	for _, s := range sessions {
+		c := s.GetConn().(*frameConn)
+		if len(c.sent) != 0 || len(c.frames) != 1 || string(c.frames[0]) != string(sessions[0].GetConn().(*frameConn).frames[0]) {
+			t.Errorf("fail: conn %d sent %d frames %d", c.id, len(c.sent), len(c.frames))
+		}
+	}
+}
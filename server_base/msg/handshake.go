@@ -0,0 +1,230 @@
+package msg
+
+import (
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+)
+
+// handshake status.
+const (
+	HANDSHAKE_STATUS_ACCEPT = 0
+	HANDSHAKE_STATUS_WARN   = 1
+	HANDSHAKE_STATUS_REJECT = 2
+)
+
+// ClientVersion 客户端握手时上报的版本信息.
+type ClientVersion struct {
+	ProtoVersion uint32
+	Build        uint32
+	Platform     string
+}
+
+// HandshakeCfg 版本协商配置, 版本号为0的限制不生效, 平台名不区分大小写.
+type HandshakeCfg struct {
+	// 未握手的连接发送其他消息时回复RESULT_ERR_NO_HANDSHAKE
+	Required bool `mapstructure:"required"`
+	// 连接建立后超时未握手则断开, 0不限制
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+	ServerVersion  uint32 `mapstructure:"server_version"`
+	// 协议版本低于min_version拒绝, 低于warn_version接受并提示升级
+	MinVersion  uint32            `mapstructure:"min_version"`
+	WarnVersion uint32            `mapstructure:"warn_version"`
+	MinBuilds   map[string]uint32 `mapstructure:"min_builds"`
+	UpgradeHint string            `mapstructure:"upgrade_hint"`
+	UpgradeURLs map[string]string `mapstructure:"upgrade_urls"`
+}
+
+// HandshakePolicy 根据客户端版本决定握手结果, 返回握手状态和给客户端的提示.
+type HandshakePolicy func(s *ClientSession, v *ClientVersion) (int32, string)
+
+type handshakeMgr struct {
+	mutex  sync.RWMutex
+	cfg    HandshakeCfg
+	policy HandshakePolicy
+}
+
+var (
+	_handshakeMgr = &handshakeMgr{}
+)
+
+func SetHandshakeCfg(cfg *HandshakeCfg) {
+	c := *cfg
+	c.MinBuilds = make(map[string]uint32, len(cfg.MinBuilds))
+	for platform, build := range cfg.MinBuilds {
+		c.MinBuilds[strings.ToLower(platform)] = build
+	}
+	c.UpgradeURLs = make(map[string]string, len(cfg.UpgradeURLs))
+	for platform, url := range cfg.UpgradeURLs {
+		c.UpgradeURLs[strings.ToLower(platform)] = url
+	}
+
+	_handshakeMgr.mutex.Lock()
+	defer _handshakeMgr.mutex.Unlock()
+
+	_handshakeMgr.cfg = c
+
+	log.Info("handshake cfg set, required %v timeout %ds server version %d min version %d warn version %d",
+		c.Required, c.TimeoutSeconds, c.ServerVersion, c.MinVersion, c.WarnVersion)
+}
+
+func getHandshakeCfg() HandshakeCfg {
+	_handshakeMgr.mutex.RLock()
+	defer _handshakeMgr.mutex.RUnlock()
+
+	return _handshakeMgr.cfg
+}
+
+// SetHandshakePolicy 替换按配置判断的默认策略, 为nil时恢复默认策略.
+func SetHandshakePolicy(policy HandshakePolicy) {
+	_handshakeMgr.mutex.Lock()
+	defer _handshakeMgr.mutex.Unlock()
+
+	_handshakeMgr.policy = policy
+}
+
+func getHandshakePolicy() HandshakePolicy {
+	_handshakeMgr.mutex.RLock()
+	defer _handshakeMgr.mutex.RUnlock()
+
+	if _handshakeMgr.policy != nil {
+		return _handshakeMgr.policy
+	}
+
+	cfg := _handshakeMgr.cfg
+	return func(s *ClientSession, v *ClientVersion) (int32, string) {
+		return defaultHandshakePolicy(&cfg, v)
+	}
+}
+
+func defaultHandshakePolicy(cfg *HandshakeCfg, v *ClientVersion) (int32, string) {
+	if v.ProtoVersion < cfg.MinVersion {
+		return HANDSHAKE_STATUS_REJECT, cfg.UpgradeHint
+	}
+
+	if v.Build < cfg.MinBuilds[strings.ToLower(v.Platform)] {
+		return HANDSHAKE_STATUS_REJECT, cfg.UpgradeHint
+	}
+
+	if v.ProtoVersion < cfg.WarnVersion {
+		return HANDSHAKE_STATUS_WARN, cfg.UpgradeHint
+	}
+
+	return HANDSHAKE_STATUS_ACCEPT, ""
+}
+
+// GetClientVersion 握手时上报的客户端版本, 未握手时为零值.
+func (s *ClientSession) GetClientVersion() ClientVersion {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.version
+}
+
+func (s *ClientSession) IsHandshaked() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.handshaked
+}
+
+func (s *ClientSession) setClientVersion(v ClientVersion) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.version = v
+	s.handshaked = true
+}
+
+// startHandshakeTimer 连接建立时调用, 超时仍未握手的连接被关闭.
+func startHandshakeTimer(conn transport.Conn, s *ClientSession) {
+	timeout := getHandshakeCfg().TimeoutSeconds
+	if timeout <= 0 {
+		return
+	}
+
+	time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+		if GetSession(conn) != s || s.IsHandshaked() {
+			return
+		}
+
+		log.Info("conn %v handshake timeout, close it", conn.GetConnID())
+		_ = conn.Close(true)
+	})
+}
+
+// checkHandshake 配置了required时未握手的连接不能发送其他消息.
+func checkHandshake(s *ClientSession) bool {
+	return s == nil || !getHandshakeCfg().Required || s.IsHandshaked()
+}
+
+func isHandshakeMsg(header *csproto.CSHead) bool {
+	return header.GetMsgid() == int32(csproto.CSMessageID_cs_handshake)
+}
+
+// callHandshakePolicy 握手不经过中间件, 策略panic时在这里恢复并拒绝握手.
+func callHandshakePolicy(policy HandshakePolicy, s *ClientSession, v *ClientVersion) (status int32, hint string) {
+	defer func() {
+		if r := recover(); r != nil {
+			_panicCount.Inc()
+			log.Error("handshake policy panic: %v\n%s", r, debug.Stack())
+			status, hint = HANDSHAKE_STATUS_REJECT, ""
+		}
+	}()
+
+	return policy(s, v)
+}
+
+// handleHandshake 由框架处理握手消息, 不经过中间件, 拒绝时回包后断开连接.
+func handleHandshake(conn transport.Conn, s *ClientSession, header *csproto.CSHead, msg proto.Message) {
+	req, ok := msg.(*csproto.CS_HANDSHAKE)
+	if !ok {
+		replyError(conn, header, RESULT_ERR_DECODE)
+		return
+	}
+
+	v := ClientVersion{
+		ProtoVersion: req.GetProtoVersion(),
+		Build:        req.GetBuild(),
+		Platform:     req.GetPlatform(),
+	}
+
+	cfg := getHandshakeCfg()
+	status, hint := callHandshakePolicy(getHandshakePolicy(), s, &v)
+
+	rsp := &csproto.SC_HANDSHAKE{
+		Status:        status,
+		ServerVersion: cfg.ServerVersion,
+		UpgradeHint:   hint,
+	}
+	if status != HANDSHAKE_STATUS_ACCEPT {
+		rsp.UpgradeUrl = cfg.UpgradeURLs[strings.ToLower(v.Platform)]
+	}
+
+	if s != nil && status != HANDSHAKE_STATUS_REJECT {
+		s.setClientVersion(v)
+	}
+
+	log.Info("conn %v handshake version %d build %d platform %s status %d",
+		conn.GetConnID(), v.ProtoVersion, v.Build, v.Platform, status)
+
+	rspHeader := &csproto.SCHead{
+		Msgid: int32(csproto.SCMessageID_sc_handshake),
+		Seqid: header.GetSeqid(),
+	}
+
+	err := SendToClient(conn, rspHeader, rsp)
+	if err != nil {
+		log.Error("conn %v handshake reply failed, err %v", conn.GetConnID(), err)
+	}
+
+	if status == HANDSHAKE_STATUS_REJECT {
+		_ = conn.Close(true)
+	}
+}
@@ -0,0 +1,74 @@
+package msg
+
+import (
+	"testing"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+func TestHandshake(t *testing.T) {
+	SetHandshakeCfg(&HandshakeCfg{
+		Required:    true,
+		MinVersion:  2,
+		WarnVersion: 3,
+		MinBuilds:   map[string]uint32{"ios": 100},
+		UpgradeURLs: map[string]string{"ios": "https://upgrade"},
+	})
+	defer SetHandshakeCfg(&HandshakeCfg{})
+
+	handshake := func(conn *recordConn, version uint32, build uint32) *csproto.SC_HANDSHAKE {
+		conn.sent = nil
+		RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: int32(csproto.CSMessageID_cs_handshake)},
+			&csproto.CS_HANDSHAKE{ProtoVersion: version, Build: build, Platform: "IOS"}))
+		if len(conn.sent) != 1 {
+			t.Fatalf("fail: handshake sent %d", len(conn.sent))
+		}
+		_, body, _ := decodeSC(conn.sent[0])
+		return body.(*csproto.SC_HANDSHAKE)
+	}
+
+	conn := &recordConn{fakeConn: fakeConn{id: 9201}}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	// 握手之前的其他消息被拒绝
+	RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: 1}, &csproto.CS_LOGIN{}))
+	if header, _, _ := decodeSC(conn.sent[0]); header.GetResult() != RESULT_ERR_NO_HANDSHAKE {
+		t.Errorf("fail: expect no handshake, header %v", header)
+	}
+
+	if rsp := handshake(conn, 2, 100); rsp.GetStatus() != HANDSHAKE_STATUS_WARN || rsp.GetUpgradeUrl() != "https://upgrade" {
+		t.Errorf("fail: warn rsp %v", rsp)
+	}
+
+	if v := GetSession(conn).GetClientVersion(); v.ProtoVersion != 2 || v.Build != 100 || conn.closed {
+		t.Errorf("fail: session version %v closed %v", v, conn.closed)
+	}
+
+	if rsp := handshake(conn, 3, 99); rsp.GetStatus() != HANDSHAKE_STATUS_REJECT || !conn.closed {
+		t.Errorf("fail: reject rsp %v closed %v", rsp, conn.closed)
+	}
+}
+
+func TestHandshakePolicyPanic(t *testing.T) {
+	SetHandshakePolicy(func(s *ClientSession, v *ClientVersion) (int32, string) {
+		panic("bad policy")
+	})
+	defer SetHandshakePolicy(nil)
+
+	conn := &recordConn{fakeConn: fakeConn{id: 9202}}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	count := GetPanicCount()
+	RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: int32(csproto.CSMessageID_cs_handshake)}, &csproto.CS_HANDSHAKE{ProtoVersion: 1}))
+
+	if len(conn.sent) != 1 {
+		t.Fatalf("fail: handshake sent %d", len(conn.sent))
+	}
+
+	_, body, _ := decodeSC(conn.sent[0])
+	if body.(*csproto.SC_HANDSHAKE).GetStatus() != HANDSHAKE_STATUS_REJECT || !conn.closed || GetPanicCount() != count+1 {
+		t.Errorf("fail: panic policy rsp %v closed %v panic count %d", body, conn.closed, GetPanicCount()-count)
+	}
+}
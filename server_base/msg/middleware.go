@@ -0,0 +1,283 @@
+package msg
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/common/ratelimit"
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+)
+
+// ClientMsgMiddleware 包装客户端消息处理, 不调用next即中断处理链.
+type ClientMsgMiddleware func(next ClientMsgHandler) ClientMsgHandler
+
+type middlewareMgr struct {
+	mutex  sync.RWMutex
+	global []ClientMsgMiddleware
+	msg    map[int32][]ClientMsgMiddleware
+	chains map[int32]ClientMsgHandler
+}
+
+var (
+	_middlewareMgr = &middlewareMgr{
+		msg:    make(map[int32][]ClientMsgMiddleware),
+		chains: make(map[int32]ClientMsgHandler),
+	}
+)
+
+// UseMiddleware 注册作用于所有消息的中间件, 按注册顺序由外到内执行, 全局中间件在消息中间件之外.
+func UseMiddleware(mws ...ClientMsgMiddleware) {
+	_middlewareMgr.mutex.Lock()
+	defer _middlewareMgr.mutex.Unlock()
+
+	_middlewareMgr.global = append(_middlewareMgr.global, mws...)
+	_middlewareMgr.chains = make(map[int32]ClientMsgHandler)
+}
+
+// UseMsgMiddleware 注册只作用于msgid的中间件.
+func UseMsgMiddleware(msgid int32, mws ...ClientMsgMiddleware) {
+	_middlewareMgr.mutex.Lock()
+	defer _middlewareMgr.mutex.Unlock()
+
+	_middlewareMgr.msg[msgid] = append(_middlewareMgr.msg[msgid], mws...)
+	delete(_middlewareMgr.chains, msgid)
+}
+
+// getChain 获取msgid对应的完整处理链, 构建后缓存直到中间件或处理函数变化.
+func (m *middlewareMgr) getChain(msgid int32, h ClientMsgHandler) ClientMsgHandler {
+	m.mutex.RLock()
+	chain, ok := m.chains[msgid]
+	m.mutex.RUnlock()
+
+	if ok {
+		return chain
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	chain = h
+	mws := m.msg[msgid]
+	for i := len(mws) - 1; i >= 0; i-- {
+		chain = mws[i](chain)
+	}
+
+	for i := len(m.global) - 1; i >= 0; i-- {
+		chain = m.global[i](chain)
+	}
+
+	m.chains[msgid] = chain
+
+	return chain
+}
+
+func (m *middlewareMgr) reset(msgid int32) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.chains, msgid)
+}
+
+//=====================================================
+
+var (
+	_panicCount atomic.Int64
+)
+
+// RecoveryMiddleware 捕获处理函数的panic并打印堆栈, 回复RESULT_ERR_INTERNAL, 连接保持不断开.
+func RecoveryMiddleware() ClientMsgMiddleware {
+	return func(next ClientMsgHandler) ClientMsgHandler {
+		return func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+			defer func() {
+				if r := recover(); r != nil {
+					_panicCount.Inc()
+					log.Error("conn %v msgid %d handler panic: %v\n%s", conn.GetConnID(), header.GetMsgid(), r, debug.Stack())
+					replyError(conn, header, RESULT_ERR_INTERNAL)
+				}
+			}()
+
+			next(conn, header, msg)
+		}
+	}
+}
+
+// GetPanicCount 获取处理函数panic的次数.
+func GetPanicCount() int64 {
+	return _panicCount.Load()
+}
+
+// AuthMiddleware 要求会话已认证, 未认证时丢弃消息并回复RESULT_ERR_NOT_AUTH, exempt中的消息号(如登录)不做检查.
+func AuthMiddleware(exempt ...int32) ClientMsgMiddleware {
+	exemptSet := make(map[int32]bool, len(exempt))
+	for _, msgid := range exempt {
+		exemptSet[msgid] = true
+	}
+
+	return func(next ClientMsgHandler) ClientMsgHandler {
+		return func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+			if !exemptSet[header.GetMsgid()] {
+				sess := GetSession(conn)
+				if sess == nil || !sess.IsAuthed() {
+					log.Error("conn %v msgid %d need auth, drop", conn.GetConnID(), header.GetMsgid())
+					replyError(conn, header, RESULT_ERR_NOT_AUTH)
+					return
+				}
+			}
+
+			next(conn, header, msg)
+		}
+	}
+}
+
+var _rateLimitMiddlewareID atomic.Uint64
+
+// RateLimitMiddleware 按会话限制消息频率, 超出时丢弃并回复RESULT_ERR_RATE_LIMIT. 令牌桶保存在会话上, 随会话销毁.
+func RateLimitMiddleware(perSec float64, burst int) ClientMsgMiddleware {
+	attrKey := fmt.Sprintf("msg.ratelimit.%d", _rateLimitMiddlewareID.Inc())
+
+	return func(next ClientMsgHandler) ClientMsgHandler {
+		return func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+			sess := GetSession(conn)
+			if sess != nil {
+				bucket := sess.getOrSetAttr(attrKey, func() interface{} {
+					return ratelimit.NewTokenBucket(perSec, burst)
+				}).(*ratelimit.TokenBucket)
+
+				if !bucket.Allow(1) {
+					_rateLimitMgr.dropped.Inc()
+					log.Error("conn %v msgid %d middleware rate limit exceed, drop", conn.GetConnID(), header.GetMsgid())
+					_rateLimitMgr.onExceed(conn, header.GetMsgid(), RATE_LIMIT_REASON_MSGID)
+					replyError(conn, header, RESULT_ERR_RATE_LIMIT)
+					return
+				}
+			}
+
+			next(conn, header, msg)
+		}
+	}
+}
+
+// MsgLatencyStats 单个消息号的处理耗时统计.
+type MsgLatencyStats struct {
+	Msgid int32
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Avg 平均处理耗时.
+func (s MsgLatencyStats) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+var (
+	_latencyMutex sync.Mutex
+	_latencyStats = make(map[int32]*MsgLatencyStats)
+)
+
+// LatencyMiddleware 统计每个消息号的处理次数和耗时, 通过GetMsgLatencyStats获取.
+func LatencyMiddleware() ClientMsgMiddleware {
+	return func(next ClientMsgHandler) ClientMsgHandler {
+		return func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+			begin := time.Now()
+			defer func() {
+				addLatency(header.GetMsgid(), time.Since(begin))
+			}()
+
+			next(conn, header, msg)
+		}
+	}
+}
+
+func addLatency(msgid int32, d time.Duration) {
+	_latencyMutex.Lock()
+	defer _latencyMutex.Unlock()
+
+	s, ok := _latencyStats[msgid]
+	if !ok {
+		s = &MsgLatencyStats{Msgid: msgid}
+		_latencyStats[msgid] = s
+	}
+
+	s.Count++
+	s.Total += d
+	if d > s.Max {
+		s.Max = d
+	}
+}
+
+// GetMsgLatencyStats 按消息号排序返回耗时统计.
+func GetMsgLatencyStats() []MsgLatencyStats {
+	_latencyMutex.Lock()
+	result := make([]MsgLatencyStats, 0, len(_latencyStats))
+	for _, s := range _latencyStats {
+		result = append(result, *s)
+	}
+	_latencyMutex.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Msgid < result[j].Msgid
+	})
+
+	return result
+}
+
+// LogMiddleware 每sample条消息打印一条消息内容, sample为0时不打印.
+func LogMiddleware(sample uint64) ClientMsgMiddleware {
+	var count atomic.Uint64
+
+	return func(next ClientMsgHandler) ClientMsgHandler {
+		return func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+			if sample > 0 && count.Inc()%sample == 1%sample {
+				printCSMsg(header, msg)
+			}
+
+			next(conn, header, msg)
+		}
+	}
+}
+
+// TraceMiddleware 为每条消息创建一个span, 未配置TracerProvider时为空操作.
+func TraceMiddleware() ClientMsgMiddleware {
+	tracer := otel.Tracer("github.com/nearmeng/mango-go/server_base/msg")
+
+	return func(next ClientMsgHandler) ClientMsgHandler {
+		return func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+			_, span := tracer.Start(context.Background(), string(msg.ProtoReflect().Descriptor().Name()))
+			span.SetAttributes(
+				attribute.Int64("msg.msgid", int64(header.GetMsgid())),
+				attribute.Int64("msg.seqid", int64(header.GetSeqid())),
+				attribute.Int64("msg.connid", int64(conn.GetConnID())),
+			)
+
+			if sess := GetSession(conn); sess != nil && sess.GetUserID() != 0 {
+				span.SetAttributes(attribute.Int64("msg.userid", int64(sess.GetUserID())))
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					span.SetStatus(codes.Error, "handler panic")
+					span.End()
+					panic(r)
+				}
+				span.End()
+			}()
+
+			next(conn, header, msg)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package msg
+
+import (
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	msgid := int32(csproto.CSMessageID_cs_login)
+	var trace []string
+
+	tag := func(name string) ClientMsgMiddleware {
+		return func(next ClientMsgHandler) ClientMsgHandler {
+			return func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+				trace = append(trace, name)
+				next(conn, header, msg)
+			}
+		}
+	}
+
+	_ = RegisterClientMsgHandler(msgid, func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+		trace = append(trace, "handler")
+		panic("boom")
+	})
+
+	oldGlobal := _middlewareMgr.global
+	UseMiddleware(RecoveryMiddleware(), tag("global"))
+	UseMsgMiddleware(msgid, tag("msg"), RateLimitMiddleware(0, 1))
+	defer func() {
+		delete(msgHandlerMgr.clientMsgHandler, msgid)
+		delete(_middlewareMgr.msg, msgid)
+		_middlewareMgr.global = oldGlobal
+		_middlewareMgr.reset(msgid)
+	}()
+
+	conn := &fakeConn{id: 4001}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	data := buildCSData(t, &csproto.CSHead{Msgid: msgid, Seqid: 1}, &csproto.CS_LOGIN{Name: "a"})
+
+	panics := GetPanicCount()
+	RecvClientMsg(conn, data)
+
+	if len(trace) != 3 || trace[0] != "global" || trace[1] != "msg" || trace[2] != "handler" {
+		t.Errorf("fail: trace %v", trace)
+	}
+
+	if GetPanicCount() != panics+1 {
+		t.Errorf("fail: panic not recovered")
+	}
+
+	// 令牌桶只有1个令牌且不补充
+	trace = trace[:0]
+	RecvClientMsg(conn, data)
+	if len(trace) != 2 {
+		t.Errorf("fail: rate limited trace %v", trace)
+	}
+}
@@ -2,25 +2,31 @@ package msg
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/nearmeng/mango-go/plugin/log"
 	"github.com/nearmeng/mango-go/plugin/transport"
 	"github.com/nearmeng/mango-go/proto/csproto"
+	"github.com/nearmeng/mango-go/proto/ssproto"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
 )
 
 type ConnEventHandler func(conn transport.Conn)
 type ClientMsgHandler func(conn transport.Conn, header *csproto.CSHead, msg proto.Message)
-type ServerMsgHandler func()
+type ServerMsgHandler func(header *ssproto.SSHead, msg proto.Message)
+
+// ServerRequestHandler 处理其他服务器的请求, 返回的回包由框架发回请求方, 返回error时回包只带错误码.
+type ServerRequestHandler func(header *ssproto.SSHead, req proto.Message) (proto.Message, error)
 
 type MsgHandlerMgr struct {
 	mutex            sync.RWMutex
 	connEventHandler map[int32]ConnEventHandler
 	clientMsgHandler map[int32]ClientMsgHandler
 	serverMsgHandler map[int32]ServerMsgHandler
+	serverReqHandler map[int32]ServerRequestHandler
 }
 
 const (
@@ -33,6 +39,7 @@ var (
 		connEventHandler: map[int32]ConnEventHandler{},
 		clientMsgHandler: map[int32]ClientMsgHandler{},
 		serverMsgHandler: map[int32]ServerMsgHandler{},
+		serverReqHandler: map[int32]ServerRequestHandler{},
 	}
 )
 
@@ -59,9 +66,22 @@ func RegisterClientMsgHandler(msgid int32, handler ClientMsgHandler) error {
 	}
 
 	msgHandlerMgr.clientMsgHandler[msgid] = handler
+	_middlewareMgr.reset(msgid)
+	return nil
+}
+
+// RegisterAuthClientMsgHandler 注册只允许已认证会话调用的消息处理, 未认证时消息被丢弃.
+func RegisterAuthClientMsgHandler(msgid int32, handler ClientMsgHandler) error {
+	err := RegisterClientMsgHandler(msgid, handler)
+	if err != nil {
+		return err
+	}
+
+	UseMsgMiddleware(msgid, AuthMiddleware())
 	return nil
 }
 
+// RegisterServerMsgHandler 注册其他服务器发来的通知的处理.
 func RegisterServerMsgHandler(msgid int32, handler ServerMsgHandler) error {
 	msgHandlerMgr.mutex.Lock()
 	defer msgHandlerMgr.mutex.Unlock()
@@ -75,16 +95,44 @@ func RegisterServerMsgHandler(msgid int32, handler ServerMsgHandler) error {
 	return nil
 }
 
+// RegisterServerRequestHandler 注册其他服务器通过CallServer发来的请求的处理.
+func RegisterServerRequestHandler(msgid int32, handler ServerRequestHandler) error {
+	msgHandlerMgr.mutex.Lock()
+	defer msgHandlerMgr.mutex.Unlock()
+
+	_, ok := msgHandlerMgr.serverReqHandler[msgid]
+	if ok {
+		return errors.New("already find msgid")
+	}
+
+	msgHandlerMgr.serverReqHandler[msgid] = handler
+	return nil
+}
+
 func OnClientConnOpened(conn transport.Conn) {
 	log.Info("client connect by connid %v", conn.GetConnID())
 
-	msgHandlerMgr.connEventHandler[CONN_EVENT_START](conn)
+	_rateLimitMgr.addLimiter(conn)
+
+	startHandshakeTimer(conn, _sessionMgr.add(conn))
+
+	h, ok := msgHandlerMgr.connEventHandler[CONN_EVENT_START]
+	if ok {
+		h(conn)
+	}
 }
 
 func OnClientConnClosed(conn transport.Conn, active bool) {
 	log.Info("client disconnnect of connid %v active %d", conn.GetConnID(), active)
 
-	msgHandlerMgr.connEventHandler[CONN_EVENT_STOP](conn)
+	_rateLimitMgr.removeLimiter(conn)
+
+	h, ok := msgHandlerMgr.connEventHandler[CONN_EVENT_STOP]
+	if ok {
+		h(conn)
+	}
+
+	_sessionMgr.remove(conn)
 }
 
 func PrintReadableStr(msg proto.Message) string {
@@ -100,52 +148,146 @@ func printCSMsg(header *csproto.CSHead, msg proto.Message) {
 		msg.ProtoReflect().Descriptor().Name(), PrintReadableStr(msg))
 }
 
-func printSCMsg(header *csproto.SCHead, msg proto.Message) {
-	log.Info("send sc msg:\nSC_HEAD {\n%s}\n%s {\n%s}", PrintReadableStr(header),
-		msg.ProtoReflect().Descriptor().Name(), PrintReadableStr(msg))
-}
-
 func RecvClientMsg(conn transport.Conn, data []byte) {
-	header, msg := getCodec(CODEC_DEFAULT).Decode(data)
-	if header == nil || msg == nil {
-		log.Error("conn %v client msg decode failed", conn.GetConnID())
+	if !checkConnLimit(conn, len(data)) {
 		return
 	}
 
-	printCSMsg(header, msg)
+	header, msg, err := getCodec(getRecvCodecName(conn, data)).Decode(data)
+	if err != nil {
+		log.Error("conn %v client msg decode failed, err %v", conn.GetConnID(), err)
+		if header != nil {
+			code := int32(RESULT_ERR_DECODE)
+			if errors.Is(err, ErrMsgUnknownMsgID) {
+				code = RESULT_ERR_UNKNOWN_MSGID
+			}
+			replyError(conn, header, code)
+		}
+		return
+	}
+
+	ok, closed := checkMsgLimit(conn, header.GetMsgid())
+	if !ok {
+		if !closed {
+			replyError(conn, header, RESULT_ERR_RATE_LIMIT)
+		}
+		return
+	}
+
+	sess := GetSession(conn)
+	if sess != nil {
+		sess.setLastSeqid(header.GetSeqid())
+		if header.GetAckScSeq() > 0 {
+			AckSCSeq(sess, header.GetAckScSeq())
+		}
+	}
+
+	logCSPacket(conn, sess, header, msg)
 
+	if isHandshakeMsg(header) {
+		handleHandshake(conn, sess, header, msg)
+		return
+	}
+
+	if !checkHandshake(sess) {
+		replyError(conn, header, RESULT_ERR_NO_HANDSHAKE)
+		return
+	}
+
+	msgHandlerMgr.mutex.RLock()
 	h, ok := msgHandlerMgr.clientMsgHandler[header.GetMsgid()]
+	msgHandlerMgr.mutex.RUnlock()
+
 	if !ok {
 		log.Error("msgid %d is not register", header.GetMsgid())
+		replyError(conn, header, RESULT_ERR_NO_HANDLER)
 		return
 	}
 
-	h(conn, header, msg)
+	_middlewareMgr.getChain(header.GetMsgid(), h)(conn, header, msg)
 }
 
 func SendToClient(conn transport.Conn, header *csproto.SCHead, msg proto.Message) error {
-	data, err := getCodec(CODEC_DEFAULT).Encode(header, msg)
+	sess := GetSession(conn)
+	if sess != nil && sess.isResumable() {
+		return SendToSession(sess, header, msg)
+	}
+
+	return sendToConn(conn, header, msg)
+}
+
+// SendToSession 发送给会话, 可恢复的会话会缓存该包, 断线期间只缓存不发送.
+func SendToSession(sess *ClientSession, header *csproto.SCHead, msg proto.Message) error {
+	err := checkSCMsg(header.GetMsgid(), msg)
 	if err != nil {
-		log.Error("conn %v client msg encode failed", conn.GetConnID())
+		log.Error("session of user %d send failed, err %v", sess.GetUserID(), err)
 		return err
 	}
 
-	err = conn.Send(data)
+	if !sess.isResumable() {
+		conn := sess.GetConn()
+		if conn == nil {
+			return fmt.Errorf("session of user %d has no conn", sess.GetUserID())
+		}
+		return sendToConn(conn, header, msg)
+	}
+
+	conn, h, err := sess.sendResumable(header, msg)
 	if err != nil {
-		log.Error("conn %v client msg send failed", conn.GetConnID())
+		log.Error("session of user %d client msg send failed, err %v", sess.GetUserID(), err)
 		return err
 	}
 
-	printSCMsg(header, msg)
+	if conn != nil {
+		logSCPacket(conn, sess, h, msg)
+	}
 
 	return nil
 }
 
-// by mosn
-func RecvServerMsg(conn transport.Conn, data []byte) {
+func sendToConn(conn transport.Conn, header *csproto.SCHead, msg proto.Message) error {
+	err := checkSCMsg(header.GetMsgid(), msg)
+	if err != nil {
+		log.Error("conn %v send failed, err %v", conn.GetConnID(), err)
+		return err
+	}
+
+	sess := GetSession(conn)
+	codec := getCodec(getSendCodecName(sess, conn))
 
-}
+	bufCodec, ok1 := codec.(BufferCSCodec)
+	bufConn, ok2 := conn.(transport.BufferConn)
+	if ok1 && ok2 {
+		buf, err := bufCodec.EncodeBuffer(header, msg)
+		if err != nil {
+			log.Error("conn %v client msg encode failed, err %v", conn.GetConnID(), err)
+			return err
+		}
+
+		err = bufConn.SendBuffer(buf)
+		if err != nil {
+			log.Error("conn %v client msg send failed", conn.GetConnID())
+			return err
+		}
+
+		logSCPacket(conn, sess, header, msg)
 
-func SendToServer(data []byte) {
+		return nil
+	}
 
+	data, err := codec.Encode(header, msg)
+	if err != nil {
+		log.Error("conn %v client msg encode failed", conn.GetConnID())
+		return err
+	}
+
+	err = conn.Send(data)
+	if err != nil {
+		log.Error("conn %v client msg send failed", conn.GetConnID())
+		return err
+	}
+
+	logSCPacket(conn, sess, header, msg)
+
+	return nil
 }
@@ -0,0 +1,259 @@
+package msg
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	_packetLogTag   = "PKT"
+	_packetLogDepth = 3
+)
+
+// PacketLogCfg 消息包日志配置, 被跟踪的用户和连接不受方向开关、过滤和采样的限制.
+type PacketLogCfg struct {
+	CS bool `mapstructure:"cs"`
+	SC bool `mapstructure:"sc"`
+	// 不为空时只记录其中的消息号
+	IncludeMsgids []int32 `mapstructure:"include_msgids"`
+	ExcludeMsgids []int32 `mapstructure:"exclude_msgids"`
+	// 每个方向每sample个包记录一个, 0和1都记录
+	Sample uint64 `mapstructure:"sample"`
+	// 消息体文本的最大长度, 0不截断
+	MaxPayload int      `mapstructure:"max_payload"`
+	TraceUsers []uint64 `mapstructure:"trace_users"`
+	// 单独的包日志文件, 为空时写入全局日志
+	Path        string `mapstructure:"path"`
+	FileSplitMB int32  `mapstructure:"filesplitmb"`
+}
+
+// packetLogState 每次修改时整体替换, 收发包时无锁读取.
+type packetLogState struct {
+	cfg     PacketLogCfg
+	include map[int32]bool
+	exclude map[int32]bool
+	users   map[uint64]bool
+	conns   map[uint64]bool
+	logger  log.Logger
+}
+
+type packetLogMgr struct {
+	mutex        sync.Mutex
+	cfg          PacketLogCfg
+	logger       log.Logger
+	runtimeUsers map[uint64]bool
+	runtimeConns map[uint64]bool
+	state        atomic.Value
+	counter      [2]atomic.Uint64
+}
+
+var (
+	_packetLogMgr = newPacketLogMgr()
+)
+
+func newPacketLogMgr() *packetLogMgr {
+	m := &packetLogMgr{
+		runtimeUsers: make(map[uint64]bool),
+		runtimeConns: make(map[uint64]bool),
+	}
+	m.rebuild()
+
+	return m
+}
+
+// rebuild 需要持有mutex或在初始化时调用.
+func (m *packetLogMgr) rebuild() {
+	st := &packetLogState{
+		cfg:     m.cfg,
+		include: make(map[int32]bool, len(m.cfg.IncludeMsgids)),
+		exclude: make(map[int32]bool, len(m.cfg.ExcludeMsgids)),
+		users:   make(map[uint64]bool, len(m.cfg.TraceUsers)+len(m.runtimeUsers)),
+		conns:   make(map[uint64]bool, len(m.runtimeConns)),
+		logger:  m.logger,
+	}
+
+	for _, msgid := range m.cfg.IncludeMsgids {
+		st.include[msgid] = true
+	}
+
+	for _, msgid := range m.cfg.ExcludeMsgids {
+		st.exclude[msgid] = true
+	}
+
+	for _, userID := range m.cfg.TraceUsers {
+		st.users[userID] = true
+	}
+
+	for userID := range m.runtimeUsers {
+		st.users[userID] = true
+	}
+
+	for connID := range m.runtimeConns {
+		st.conns[connID] = true
+	}
+
+	m.state.Store(st)
+}
+
+func (m *packetLogMgr) load() *packetLogState {
+	return m.state.Load().(*packetLogState)
+}
+
+// SetPacketLogCfg 设置包日志配置, reload时可重复调用, 运行时跟踪的用户和连接保留.
+func SetPacketLogCfg(cfg *PacketLogCfg) {
+	_packetLogMgr.mutex.Lock()
+	defer _packetLogMgr.mutex.Unlock()
+
+	_packetLogMgr.cfg = *cfg
+	_packetLogMgr.rebuild()
+
+	log.Info("packet log cfg set, cs %v sc %v include %d exclude %d sample %d max payload %d trace users %d",
+		cfg.CS, cfg.SC, len(cfg.IncludeMsgids), len(cfg.ExcludeMsgids), cfg.Sample, cfg.MaxPayload, len(cfg.TraceUsers))
+}
+
+// SetPacketLogger 包日志写入单独的logger, 为nil时写入全局日志.
+func SetPacketLogger(logger log.Logger) {
+	_packetLogMgr.mutex.Lock()
+	defer _packetLogMgr.mutex.Unlock()
+
+	_packetLogMgr.logger = logger
+	_packetLogMgr.rebuild()
+}
+
+// TraceUser 运行时开关对某个用户的跟踪, 跟踪期间该用户收发的所有包都会记录.
+func TraceUser(userID uint64, enable bool) {
+	_packetLogMgr.mutex.Lock()
+	defer _packetLogMgr.mutex.Unlock()
+
+	if enable {
+		_packetLogMgr.runtimeUsers[userID] = true
+	} else {
+		delete(_packetLogMgr.runtimeUsers, userID)
+	}
+	_packetLogMgr.rebuild()
+}
+
+// TraceConn 运行时开关对某个连接的跟踪, 用于登录之前的会话.
+func TraceConn(connID uint64, enable bool) {
+	_packetLogMgr.mutex.Lock()
+	defer _packetLogMgr.mutex.Unlock()
+
+	if enable {
+		_packetLogMgr.runtimeConns[connID] = true
+	} else {
+		delete(_packetLogMgr.runtimeConns, connID)
+	}
+	_packetLogMgr.rebuild()
+}
+
+// isTraced 没有跟踪对象时不查找会话.
+func (st *packetLogState) isTraced(conn transport.Conn, sess *ClientSession) bool {
+	if len(st.users) == 0 && len(st.conns) == 0 {
+		return false
+	}
+
+	if conn != nil && st.conns[conn.GetConnID()] {
+		return true
+	}
+
+	if sess == nil && conn != nil {
+		sess = GetSession(conn)
+	}
+
+	return sess != nil && st.users[sess.GetUserID()]
+}
+
+func (m *packetLogMgr) shouldLog(st *packetLogState, dir int32, conn transport.Conn, sess *ClientSession, msgid int32) bool {
+	if st.isTraced(conn, sess) {
+		return true
+	}
+
+	enabled := st.cfg.CS
+	if dir == MSG_DIRECTION_SC {
+		enabled = st.cfg.SC
+	}
+
+	if !enabled {
+		return false
+	}
+
+	if len(st.include) > 0 && !st.include[msgid] {
+		return false
+	}
+
+	if st.exclude[msgid] {
+		return false
+	}
+
+	sample := st.cfg.Sample
+	return sample <= 1 || m.counter[dir].Inc()%sample == 1
+}
+
+func (m *packetLogMgr) output(st *packetLogState, format string, v ...interface{}) {
+	if st.logger != nil {
+		st.logger.Output(_packetLogDepth, _packetLogTag, nil, format, v...)
+		return
+	}
+
+	log.Info(format, v...)
+}
+
+// formatPayload 消息体的单行文本, 超过max时在字符边界截断.
+func formatPayload(msg proto.Message, max int) string {
+	if msg == nil {
+		return "nil"
+	}
+
+	s := string(msg.ProtoReflect().Descriptor().Name()) + " " + log.FormatPB(msg)
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+
+	n := max
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return fmt.Sprintf("%s...(%d bytes truncated)", s[:n], len(s)-n)
+}
+
+func logCSPacket(conn transport.Conn, sess *ClientSession, header *csproto.CSHead, msg proto.Message) {
+	st := _packetLogMgr.load()
+	if !_packetLogMgr.shouldLog(st, MSG_DIRECTION_CS, conn, sess, header.GetMsgid()) {
+		return
+	}
+
+	var userID uint64
+	if sess != nil {
+		userID = sess.GetUserID()
+	}
+
+	_packetLogMgr.output(st, "recv cs conn %v user %d msgid %d seqid %d %s", conn.GetConnID(), userID,
+		header.GetMsgid(), header.GetSeqid(), formatPayload(msg, st.cfg.MaxPayload))
+}
+
+// logSCPacket sess为nil时按conn查找会话, 只缓存未发送的包不记录.
+func logSCPacket(conn transport.Conn, sess *ClientSession, header *csproto.SCHead, msg proto.Message) {
+	st := _packetLogMgr.load()
+	if !_packetLogMgr.shouldLog(st, MSG_DIRECTION_SC, conn, sess, header.GetMsgid()) {
+		return
+	}
+
+	var connID, userID uint64
+	if conn != nil {
+		connID = conn.GetConnID()
+	}
+	if sess != nil {
+		userID = sess.GetUserID()
+	}
+
+	_packetLogMgr.output(st, "send sc conn %v user %d msgid %d seqid %d result %d %s", connID, userID,
+		header.GetMsgid(), header.GetSeqid(), header.GetResult(), formatPayload(msg, st.cfg.MaxPayload))
+}
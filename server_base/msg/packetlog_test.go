@@ -0,0 +1,65 @@
+package msg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+// recordLogger 记录包日志的输出.
+type recordLogger struct {
+	log.MockLogger
+	lines []string
+}
+
+func (l *recordLogger) Output(depth int, logType string, a log.AcntLogger, format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestPacketLog(t *testing.T) {
+	logger := &recordLogger{}
+	SetPacketLogger(logger)
+	defer SetPacketLogger(nil)
+	defer SetPacketLogCfg(&PacketLogCfg{})
+
+	conn := &fakeConn{id: 9101}
+	header := &csproto.CSHead{Msgid: 1}
+	login := &csproto.CS_LOGIN{Name: "abcdefgh"}
+
+	SetPacketLogCfg(&PacketLogCfg{CS: true, ExcludeMsgids: []int32{1}})
+	logCSPacket(conn, nil, header, login)
+	logSCPacket(conn, nil, &csproto.SCHead{Msgid: 2}, nil)
+	if len(logger.lines) != 0 {
+		t.Errorf("fail: excluded or disabled logged %v", logger.lines)
+	}
+
+	SetPacketLogCfg(&PacketLogCfg{CS: true, Sample: 3})
+	for i := 0; i < 6; i++ {
+		logCSPacket(conn, nil, header, login)
+	}
+	if len(logger.lines) != 2 {
+		t.Errorf("fail: sample logged %d", len(logger.lines))
+	}
+
+	// 跟踪的连接不受开关和采样限制
+	logger.lines = nil
+	SetPacketLogCfg(&PacketLogCfg{MaxPayload: 12})
+	TraceConn(conn.GetConnID(), true)
+	logCSPacket(conn, nil, header, login)
+	TraceConn(conn.GetConnID(), false)
+	logCSPacket(conn, nil, header, login)
+
+	if len(logger.lines) != 1 || !strings.HasSuffix(logger.lines[0], "bytes truncated)") {
+		t.Errorf("fail: trace logged %v", logger.lines)
+	}
+}
+
+func TestFormatPayloadTruncate(t *testing.T) {
+	s := formatPayload(&csproto.CS_LOGIN{Name: "中文名字"}, 20)
+	if !strings.HasSuffix(s, "bytes truncated)") || strings.ContainsRune(s, '�') {
+		t.Errorf("fail: truncate %s", s)
+	}
+}
@@ -0,0 +1,299 @@
+package msg
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/common/ratelimit"
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"go.uber.org/atomic"
+)
+
+// rate limit policy.
+const (
+	RATE_LIMIT_POLICY_DROP       = "drop"
+	RATE_LIMIT_POLICY_DELAY      = "delay"
+	RATE_LIMIT_POLICY_DISCONNECT = "disconnect"
+)
+
+// rate limit reason.
+const (
+	RATE_LIMIT_REASON_PACKET = 1
+	RATE_LIMIT_REASON_BYTES  = 2
+	RATE_LIMIT_REASON_MSGID  = 3
+)
+
+const (
+	_defaultMaxDelayMs = 100
+)
+
+type MsgRateLimitCfg struct {
+	Msgid  int32   `mapstructure:"msgid"`
+	PerSec float64 `mapstructure:"per_sec"`
+	Burst  int     `mapstructure:"burst"`
+	Policy string  `mapstructure:"policy"`
+}
+
+type RateLimitCfg struct {
+	Enable        bool    `mapstructure:"enable"`
+	PacketsPerSec float64 `mapstructure:"packets_per_sec"`
+	PacketBurst   int     `mapstructure:"packet_burst"`
+	BytesPerSec   float64 `mapstructure:"bytes_per_sec"`
+	BytesBurst    int     `mapstructure:"bytes_burst"`
+	Policy        string  `mapstructure:"policy"`
+	MaxDelayMs    uint32  `mapstructure:"max_delay_ms"`
+	// disconnect策略断开连接时同时封禁对端ip的时长, 0不封禁
+	BanSeconds uint32            `mapstructure:"ban_seconds"`
+	MsgLimits  []MsgRateLimitCfg `mapstructure:"msg_limits"`
+}
+
+// RateLimitHook is called every time a conn exceeds a limit, reason is one of RATE_LIMIT_REASON_*.
+type RateLimitHook func(conn transport.Conn, msgid int32, reason int32)
+
+type RateLimitStats struct {
+	Dropped      int64
+	Delayed      int64
+	Disconnected int64
+}
+
+type connLimiter struct {
+	cfg     *RateLimitCfg
+	packets *ratelimit.TokenBucket
+	bytes   *ratelimit.TokenBucket
+	msgs    map[int32]*ratelimit.TokenBucket
+}
+
+type rateLimitMgr struct {
+	mutex    sync.RWMutex
+	cfg      *RateLimitCfg
+	msgCfg   map[int32]*MsgRateLimitCfg
+	limiters map[transport.Conn]*connLimiter
+	hooks    []RateLimitHook
+
+	dropped      atomic.Int64
+	delayed      atomic.Int64
+	disconnected atomic.Int64
+}
+
+var (
+	_rateLimitMgr = &rateLimitMgr{
+		cfg:      &RateLimitCfg{},
+		msgCfg:   map[int32]*MsgRateLimitCfg{},
+		limiters: map[transport.Conn]*connLimiter{},
+	}
+)
+
+// SetRateLimitCfg 设置限流配置, reload时可重复调用, 已有连接在下一个包时生效.
+func SetRateLimitCfg(cfg *RateLimitCfg) {
+	msgCfg := make(map[int32]*MsgRateLimitCfg, len(cfg.MsgLimits))
+	for i := range cfg.MsgLimits {
+		msgCfg[cfg.MsgLimits[i].Msgid] = &cfg.MsgLimits[i]
+	}
+
+	_rateLimitMgr.mutex.Lock()
+	defer _rateLimitMgr.mutex.Unlock()
+
+	_rateLimitMgr.cfg = cfg
+	_rateLimitMgr.msgCfg = msgCfg
+
+	log.Info("rate limit cfg set, enable %v packets %v bytes %v policy %s msg limits %d",
+		cfg.Enable, cfg.PacketsPerSec, cfg.BytesPerSec, cfg.Policy, len(cfg.MsgLimits))
+}
+
+// RegisterRateLimitHook 注册超限回调, 可用于标记异常账号.
+func RegisterRateLimitHook(hook RateLimitHook) {
+	_rateLimitMgr.mutex.Lock()
+	defer _rateLimitMgr.mutex.Unlock()
+
+	_rateLimitMgr.hooks = append(_rateLimitMgr.hooks, hook)
+}
+
+// GetRateLimitStats 获取限流计数.
+func GetRateLimitStats() RateLimitStats {
+	return RateLimitStats{
+		Dropped:      _rateLimitMgr.dropped.Load(),
+		Delayed:      _rateLimitMgr.delayed.Load(),
+		Disconnected: _rateLimitMgr.disconnected.Load(),
+	}
+}
+
+func newConnLimiter(cfg *RateLimitCfg) *connLimiter {
+	l := &connLimiter{
+		cfg:  cfg,
+		msgs: map[int32]*ratelimit.TokenBucket{},
+	}
+
+	if cfg.PacketsPerSec > 0 {
+		l.packets = ratelimit.NewTokenBucket(cfg.PacketsPerSec, cfg.PacketBurst)
+	}
+
+	if cfg.BytesPerSec > 0 {
+		l.bytes = ratelimit.NewTokenBucket(cfg.BytesPerSec, cfg.BytesBurst)
+	}
+
+	return l
+}
+
+// addLimiter 连接建立时创建limiter, 之后只会在配置变化时替换, 不会在连接关闭后重新创建.
+func (m *rateLimitMgr) addLimiter(conn transport.Conn) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.limiters[conn] = newConnLimiter(m.cfg)
+}
+
+// getLimiter 连接已关闭或未开启限流时返回nil.
+func (m *rateLimitMgr) getLimiter(conn transport.Conn) (*connLimiter, *RateLimitCfg) {
+	m.mutex.RLock()
+	cfg := m.cfg
+	l, ok := m.limiters[conn]
+	m.mutex.RUnlock()
+
+	if !cfg.Enable || !ok {
+		return nil, cfg
+	}
+
+	if l.cfg == cfg {
+		return l, cfg
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// 重新检查, 连接可能已经关闭或被其他协程替换
+	l, ok = m.limiters[conn]
+	if !ok {
+		return nil, m.cfg
+	}
+
+	if l.cfg != m.cfg {
+		l = newConnLimiter(m.cfg)
+		m.limiters[conn] = l
+	}
+
+	return l, l.cfg
+}
+
+func (m *rateLimitMgr) removeLimiter(conn transport.Conn) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.limiters, conn)
+}
+
+func (m *rateLimitMgr) onExceed(conn transport.Conn, msgid int32, reason int32) {
+	m.mutex.RLock()
+	hooks := m.hooks
+	m.mutex.RUnlock()
+
+	for _, h := range hooks {
+		h(conn, msgid, reason)
+	}
+}
+
+// apply 按照策略处理令牌不足的情况, 返回是否继续处理该包.
+func (m *rateLimitMgr) apply(conn transport.Conn, bucket *ratelimit.TokenBucket, n int,
+	policy string, cfg *RateLimitCfg, msgid int32, reason int32) bool {
+	if bucket.Allow(n) {
+		return true
+	}
+
+	switch policy {
+	case RATE_LIMIT_POLICY_DELAY:
+		maxDelayMs := cfg.MaxDelayMs
+		if maxDelayMs == 0 {
+			maxDelayMs = _defaultMaxDelayMs
+		}
+
+		wait, ok := bucket.Reserve(n, time.Duration(maxDelayMs)*time.Millisecond)
+		if ok {
+			m.delayed.Inc()
+			time.Sleep(wait)
+			return true
+		}
+
+		m.dropped.Inc()
+		log.Error("conn %v rate limit exceed max delay, msgid %d reason %d, drop", conn.GetConnID(), msgid, reason)
+	case RATE_LIMIT_POLICY_DISCONNECT:
+		m.disconnected.Inc()
+		log.Error("conn %v rate limit exceed, msgid %d reason %d, disconnect", conn.GetConnID(), msgid, reason)
+		m.onExceed(conn, msgid, reason)
+		if cfg.BanSeconds > 0 {
+			transport.BanIP(transport.GetRemoteIP(conn), time.Duration(cfg.BanSeconds)*time.Second)
+		}
+		_ = conn.Close(true)
+		return false
+	default:
+		m.dropped.Inc()
+		log.Error("conn %v rate limit exceed, msgid %d reason %d, drop", conn.GetConnID(), msgid, reason)
+	}
+
+	m.onExceed(conn, msgid, reason)
+	return false
+}
+
+// checkConnLimit 检查连接的包量和流量限制, 在解码之前调用.
+func checkConnLimit(conn transport.Conn, size int) bool {
+	l, cfg := _rateLimitMgr.getLimiter(conn)
+	if l == nil {
+		return true
+	}
+
+	if l.packets != nil &&
+		!_rateLimitMgr.apply(conn, l.packets, 1, cfg.Policy, cfg, 0, RATE_LIMIT_REASON_PACKET) {
+		return false
+	}
+
+	if l.bytes != nil {
+		// 超过桶容量的大包需要满桶才能通过, 否则永远不会被放行
+		n := size
+		if burst := l.bytes.GetBurst(); n > burst {
+			n = burst
+		}
+
+		if !_rateLimitMgr.apply(conn, l.bytes, n, cfg.Policy, cfg, 0, RATE_LIMIT_REASON_BYTES) {
+			// 包没有被处理, 归还已扣除的包量令牌
+			if l.packets != nil {
+				l.packets.Refund(1)
+			}
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkMsgLimit 检查单个msgid的频率限制, 第二个返回值表示连接已被断开.
+func checkMsgLimit(conn transport.Conn, msgid int32) (bool, bool) {
+	l, cfg := _rateLimitMgr.getLimiter(conn)
+	if l == nil {
+		return true, false
+	}
+
+	_rateLimitMgr.mutex.RLock()
+	msgCfg, ok := _rateLimitMgr.msgCfg[msgid]
+	_rateLimitMgr.mutex.RUnlock()
+
+	if !ok || msgCfg.PerSec <= 0 {
+		return true, false
+	}
+
+	// limiter只会被所属连接的收包协程访问
+	bucket, ok := l.msgs[msgid]
+	if !ok {
+		bucket = ratelimit.NewTokenBucket(msgCfg.PerSec, msgCfg.Burst)
+		l.msgs[msgid] = bucket
+	}
+
+	policy := msgCfg.Policy
+	if policy == "" {
+		policy = cfg.Policy
+	}
+
+	if _rateLimitMgr.apply(conn, bucket, 1, policy, cfg, msgid, RATE_LIMIT_REASON_MSGID) {
+		return true, false
+	}
+
+	return false, policy == RATE_LIMIT_POLICY_DISCONNECT
+}
@@ -0,0 +1,69 @@
+package msg
+
+import (
+	"testing"
+)
+
+func TestConnLimitOversizedPacket(t *testing.T) {
+	SetRateLimitCfg(&RateLimitCfg{Enable: true, BytesPerSec: 100, Policy: RATE_LIMIT_POLICY_DROP})
+	defer SetRateLimitCfg(&RateLimitCfg{})
+
+	conn := &fakeConn{id: 8001}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	// 超过桶容量的包在满桶时放行并清空桶
+	if !checkConnLimit(conn, 1000) {
+		t.Errorf("fail: oversized packet dropped on full bucket")
+	}
+
+	if checkConnLimit(conn, 1000) {
+		t.Errorf("fail: oversized packet allowed on empty bucket")
+	}
+}
+
+func TestConnLimiterRemoved(t *testing.T) {
+	SetRateLimitCfg(&RateLimitCfg{Enable: true, PacketsPerSec: 100})
+	defer SetRateLimitCfg(&RateLimitCfg{})
+
+	conn := &fakeConn{id: 8002}
+	OnClientConnOpened(conn)
+
+	SetRateLimitCfg(&RateLimitCfg{Enable: true, PacketsPerSec: 200})
+	OnClientConnClosed(conn, false)
+
+	// 关闭后还在处理中的包不会重新创建limiter
+	checkConnLimit(conn, 10)
+
+	_rateLimitMgr.mutex.RLock()
+	_, ok := _rateLimitMgr.limiters[conn]
+	_rateLimitMgr.mutex.RUnlock()
+
+	if ok {
+		t.Errorf("fail: limiter leaked after close")
+	}
+}
+
+func TestConnLimitBytesRefundPacket(t *testing.T) {
+	SetRateLimitCfg(&RateLimitCfg{Enable: true, PacketsPerSec: 0.001, PacketBurst: 2, BytesPerSec: 100, Policy: RATE_LIMIT_POLICY_DROP})
+	defer SetRateLimitCfg(&RateLimitCfg{})
+
+	conn := &fakeConn{id: 8003}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	if !checkConnLimit(conn, 100) {
+		t.Fatalf("fail: first packet dropped")
+	}
+
+	// 被流量限制丢弃的包不消耗包量
+	for i := 0; i < 5; i++ {
+		if checkConnLimit(conn, 100) {
+			t.Errorf("fail: packet %d allowed on empty byte bucket", i)
+		}
+	}
+
+	if !checkConnLimit(conn, 0) {
+		t.Errorf("fail: packet budget drained by byte limit")
+	}
+}
@@ -0,0 +1,207 @@
+package msg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// msg direction.
+const (
+	MSG_DIRECTION_CS = 0
+	MSG_DIRECTION_SC = 1
+	MSG_DIRECTION_SS = 2
+
+	_msgDirectionCount = 3
+)
+
+var (
+	ErrMsgDuplicate    = errors.New("msg: duplicate msgid registration")
+	ErrMsgTypeMismatch = errors.New("msg: message type mismatch msgid")
+)
+
+// MsgInfo 消息号与消息类型的对应关系.
+type MsgInfo struct {
+	Msgid     int32
+	Direction int32
+	Type      protoreflect.MessageType
+}
+
+func (i *MsgInfo) GetName() protoreflect.FullName {
+	return i.Type.Descriptor().FullName()
+}
+
+type msgRegistry struct {
+	mutex  sync.RWMutex
+	byID   [_msgDirectionCount]map[int32]*MsgInfo
+	byName [_msgDirectionCount]map[protoreflect.FullName]*MsgInfo
+}
+
+var (
+	_msgRegistry = &msgRegistry{
+		byID:   [_msgDirectionCount]map[int32]*MsgInfo{{}, {}, {}},
+		byName: [_msgDirectionCount]map[protoreflect.FullName]*MsgInfo{{}, {}, {}},
+	}
+)
+
+// register 同一方向上msgid与消息类型一一对应, 重复注册相同的对应关系不报错.
+func (r *msgRegistry) register(dir int32, msgid int32, mt protoreflect.MessageType) error {
+	name := mt.Descriptor().FullName()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if info, ok := r.byID[dir][msgid]; ok {
+		if info.GetName() == name {
+			return nil
+		}
+		return fmt.Errorf("%w: dir %d msgid %d already register by %s, new %s",
+			ErrMsgDuplicate, dir, msgid, info.GetName(), name)
+	}
+
+	if info, ok := r.byName[dir][name]; ok {
+		return fmt.Errorf("%w: dir %d message %s already register by msgid %d, new %d",
+			ErrMsgDuplicate, dir, name, info.Msgid, msgid)
+	}
+
+	info := &MsgInfo{Msgid: msgid, Direction: dir, Type: mt}
+	r.byID[dir][msgid] = info
+	r.byName[dir][name] = info
+
+	return nil
+}
+
+func (r *msgRegistry) getByID(dir int32, msgid int32) *MsgInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.byID[dir][msgid]
+}
+
+func (r *msgRegistry) getByName(dir int32, name protoreflect.FullName) *MsgInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.byName[dir][name]
+}
+
+// RegisterCSMsg 声明客户端请求的消息号.
+func RegisterCSMsg(msgid int32, msg proto.Message) error {
+	return _msgRegistry.register(MSG_DIRECTION_CS, msgid, msg.ProtoReflect().Type())
+}
+
+// RegisterSCMsg 声明服务器下发的消息号.
+func RegisterSCMsg(msgid int32, msg proto.Message) error {
+	return _msgRegistry.register(MSG_DIRECTION_SC, msgid, msg.ProtoReflect().Type())
+}
+
+// RegisterSSMsg 声明服务器之间的消息号, 请求和回包使用各自的消息号.
+func RegisterSSMsg(msgid int32, msg proto.Message) error {
+	return _msgRegistry.register(MSG_DIRECTION_SS, msgid, msg.ProtoReflect().Type())
+}
+
+// RegisterMsg 声明同一消息号的请求和回包, 没有回包时rsp传nil.
+func RegisterMsg(msgid int32, req proto.Message, rsp proto.Message) error {
+	err := RegisterCSMsg(msgid, req)
+	if err != nil {
+		return err
+	}
+
+	if rsp == nil {
+		return nil
+	}
+
+	return RegisterSCMsg(msgid, rsp)
+}
+
+// GetMsgInfo 根据方向和消息号获取消息类型, 未注册时返回nil.
+func GetMsgInfo(dir int32, msgid int32) *MsgInfo {
+	return _msgRegistry.getByID(dir, msgid)
+}
+
+// GetMsgInfoByMessage 根据消息类型反查消息号, 未注册时返回nil.
+func GetMsgInfoByMessage(dir int32, msg proto.Message) *MsgInfo {
+	return _msgRegistry.getByName(dir, msg.ProtoReflect().Descriptor().FullName())
+}
+
+// GetAllMsgInfo 按消息号排序返回某个方向的所有注册, 供代码生成和校验使用.
+func GetAllMsgInfo(dir int32) []*MsgInfo {
+	_msgRegistry.mutex.RLock()
+	result := make([]*MsgInfo, 0, len(_msgRegistry.byID[dir]))
+	for _, info := range _msgRegistry.byID[dir] {
+		result = append(result, info)
+	}
+	_msgRegistry.mutex.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Msgid < result[j].Msgid
+	})
+
+	return result
+}
+
+// LoadMsgRegistryFromOptions 扫描已链接的proto消息, 注册带有cs_msgid/sc_msgid选项的消息.
+// 需要在所有proto包初始化之后调用, 返回遇到的第一个冲突.
+func LoadMsgRegistryFromOptions() error {
+	var firstErr error
+
+	protoregistry.GlobalTypes.RangeMessages(func(mt protoreflect.MessageType) bool {
+		err := registerFromOptions(mt)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return true
+	})
+
+	return firstErr
+}
+
+func registerFromOptions(mt protoreflect.MessageType) error {
+	opts := mt.Descriptor().Options()
+	if opts == nil {
+		return nil
+	}
+
+	if proto.HasExtension(opts, csproto.E_CsMsgid) {
+		msgid := proto.GetExtension(opts, csproto.E_CsMsgid).(int32)
+		err := _msgRegistry.register(MSG_DIRECTION_CS, msgid, mt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if proto.HasExtension(opts, csproto.E_ScMsgid) {
+		msgid := proto.GetExtension(opts, csproto.E_ScMsgid).(int32)
+		err := _msgRegistry.register(MSG_DIRECTION_SC, msgid, mt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSCMsg 校验下发的消息与SCHead中的消息号一致, 未注册的消息号不做校验.
+func checkSCMsg(msgid int32, msg proto.Message) error {
+	if msg == nil {
+		return nil
+	}
+
+	info := GetMsgInfo(MSG_DIRECTION_SC, msgid)
+	if info == nil {
+		return nil
+	}
+
+	name := msg.ProtoReflect().Descriptor().FullName()
+	if info.GetName() != name {
+		return fmt.Errorf("%w: msgid %d expect %s got %s", ErrMsgTypeMismatch, msgid, info.GetName(), name)
+	}
+
+	return nil
+}
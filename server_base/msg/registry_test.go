@@ -0,0 +1,42 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+func TestMsgRegistry(t *testing.T) {
+	msgid := int32(csproto.CSMessageID_cs_login)
+
+	info := GetMsgInfo(MSG_DIRECTION_CS, msgid)
+	if info == nil || info.GetName() != "proto.CS_LOGIN" {
+		t.Fatalf("fail: option not loaded")
+	}
+
+	if GetMsgInfoByMessage(MSG_DIRECTION_SC, &csproto.SC_LOGIN{}).Msgid != msgid {
+		t.Errorf("fail: sc reverse lookup")
+	}
+
+	// 重复声明相同的对应关系
+	if err := RegisterMsg(msgid, &csproto.CS_LOGIN{}, &csproto.SC_LOGIN{}); err != nil {
+		t.Errorf("fail: same registration err %v", err)
+	}
+
+	if err := RegisterCSMsg(msgid, &csproto.Account{}); !errors.Is(err, ErrMsgDuplicate) {
+		t.Errorf("fail: duplicate msgid err %v", err)
+	}
+
+	if err := RegisterCSMsg(msgid+1000, &csproto.CS_LOGIN{}); !errors.Is(err, ErrMsgDuplicate) {
+		t.Errorf("fail: duplicate message err %v", err)
+	}
+
+	if err := checkSCMsg(msgid, &csproto.CS_LOGIN{}); !errors.Is(err, ErrMsgTypeMismatch) {
+		t.Errorf("fail: sc mismatch err %v", err)
+	}
+
+	if len(GetAllMsgInfo(MSG_DIRECTION_SC)) == 0 {
+		t.Errorf("fail: sc list empty")
+	}
+}
@@ -0,0 +1,399 @@
+package msg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+)
+
+// session event.
+const (
+	SESSION_EVENT_DETACHED = 1
+	SESSION_EVENT_RESUMED  = 2
+	SESSION_EVENT_EXPIRED  = 3
+)
+
+const (
+	_defaultResumeGraceSeconds = 60
+	_defaultResumeBufPackets   = 256
+	_defaultResumeBufBytes     = 1024 * 1024
+	_resumeTokenSize           = 16
+)
+
+var (
+	ErrResumeDisabled     = errors.New("msg: session resume disabled")
+	ErrResumeInvalidToken = errors.New("msg: invalid resume token")
+	ErrResumeGap          = errors.New("msg: resume buffer missing unacked sc seq")
+)
+
+type ResumeCfg struct {
+	Enable       bool   `mapstructure:"enable"`
+	GraceSeconds uint32 `mapstructure:"grace_seconds"`
+	BufPackets   int    `mapstructure:"buf_packets"`
+	BufBytes     int    `mapstructure:"buf_bytes"`
+}
+
+// SessionEventHandler 会话断线保留、恢复、过期时回调, 过期后会话彻底销毁.
+type SessionEventHandler func(s *ClientSession)
+
+type resumeEntry struct {
+	seq  uint32
+	data []byte
+}
+
+// resumeBuffer 最近发出的SC包, 按sc_seq递增排列, 超出包数或字节数时淘汰最旧的.
+type resumeBuffer struct {
+	entries    []resumeEntry
+	bytes      int
+	evictedSeq uint32
+}
+
+func (b *resumeBuffer) push(seq uint32, data []byte, maxPackets int, maxBytes int) {
+	b.entries = append(b.entries, resumeEntry{seq: seq, data: data})
+	b.bytes += len(data)
+
+	for len(b.entries) > maxPackets || (b.bytes > maxBytes && len(b.entries) > 1) {
+		b.evictedSeq = b.entries[0].seq
+		b.bytes -= len(b.entries[0].data)
+		b.entries[0].data = nil
+		b.entries = b.entries[1:]
+	}
+}
+
+// ack 丢弃客户端已确认的包.
+func (b *resumeBuffer) ack(seq uint32) {
+	n := 0
+	for n < len(b.entries) && b.entries[n].seq <= seq {
+		b.bytes -= len(b.entries[n].data)
+		b.entries[n].data = nil
+		n++
+	}
+	b.entries = b.entries[n:]
+}
+
+// since 获取seq之后的所有包, 淘汰过未确认的包时返回ErrResumeGap.
+func (b *resumeBuffer) since(seq uint32) ([][]byte, error) {
+	if seq < b.evictedSeq {
+		return nil, fmt.Errorf("%w: ack %d evicted %d", ErrResumeGap, seq, b.evictedSeq)
+	}
+
+	var result [][]byte
+	for _, e := range b.entries {
+		if e.seq > seq {
+			result = append(result, e.data)
+		}
+	}
+
+	return result, nil
+}
+
+type resumeMgr struct {
+	mutex    sync.RWMutex
+	cfg      ResumeCfg
+	byToken  map[string]*ClientSession
+	handlers map[int32]SessionEventHandler
+}
+
+var (
+	_resumeMgr = &resumeMgr{
+		byToken:  make(map[string]*ClientSession),
+		handlers: make(map[int32]SessionEventHandler),
+	}
+)
+
+func SetResumeCfg(cfg *ResumeCfg) {
+	c := *cfg
+	if c.GraceSeconds == 0 {
+		c.GraceSeconds = _defaultResumeGraceSeconds
+	}
+
+	if c.BufPackets <= 0 {
+		c.BufPackets = _defaultResumeBufPackets
+	}
+
+	if c.BufBytes <= 0 {
+		c.BufBytes = _defaultResumeBufBytes
+	}
+
+	_resumeMgr.mutex.Lock()
+	defer _resumeMgr.mutex.Unlock()
+
+	_resumeMgr.cfg = c
+
+	log.Info("session resume cfg set, enable %v grace %ds buf packets %d bytes %d",
+		c.Enable, c.GraceSeconds, c.BufPackets, c.BufBytes)
+}
+
+func getResumeCfg() ResumeCfg {
+	_resumeMgr.mutex.RLock()
+	defer _resumeMgr.mutex.RUnlock()
+
+	return _resumeMgr.cfg
+}
+
+func RegisterSessionEventHandler(event int32, handler SessionEventHandler) error {
+	_resumeMgr.mutex.Lock()
+	defer _resumeMgr.mutex.Unlock()
+
+	_, ok := _resumeMgr.handlers[event]
+	if ok {
+		return errors.New("already find session event")
+	}
+
+	_resumeMgr.handlers[event] = handler
+	return nil
+}
+
+func onSessionEvent(event int32, s *ClientSession) {
+	_resumeMgr.mutex.RLock()
+	h, ok := _resumeMgr.handlers[event]
+	_resumeMgr.mutex.RUnlock()
+
+	if ok {
+		h(s)
+	}
+}
+
+// IssueResumeToken 为已认证的会话生成恢复凭证, 之后发出的SC包带上递增的sc_seq并被缓存用于断线重发.
+// 业务在登录回包中把凭证带给客户端, 已有凭证时返回原凭证.
+func IssueResumeToken(s *ClientSession) (string, error) {
+	if !getResumeCfg().Enable {
+		return "", ErrResumeDisabled
+	}
+
+	if !s.IsAuthed() {
+		return "", fmt.Errorf("%w: session not authed", ErrResumeInvalidToken)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.resumeToken != "" {
+		return s.resumeToken, nil
+	}
+
+	raw := make([]byte, _resumeTokenSize)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+
+	s.resumeToken = hex.EncodeToString(raw)
+	s.resumeBuf = &resumeBuffer{}
+
+	_resumeMgr.mutex.Lock()
+	_resumeMgr.byToken[s.resumeToken] = s
+	_resumeMgr.mutex.Unlock()
+
+	return s.resumeToken, nil
+}
+
+// AckSCSeq 客户端确认收到sc_seq及之前的包, 释放缓存. CSHead带有ack_sc_seq时由框架自动调用.
+func AckSCSeq(s *ClientSession, seq uint32) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.resumeBuf != nil {
+		s.resumeBuf.ack(seq)
+	}
+}
+
+// GetSCSeq 最近一次发出的sc_seq.
+func (s *ClientSession) GetSCSeq() uint32 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.scSeq
+}
+
+// ResumeSession 客户端重连后携带凭证恢复会话, 会话绑定到新连接并重发lastSCSeq之后的包.
+// 新连接上原有的会话被丢弃并解除用户绑定、凭证和分组, 旧连接仍然存在时将被关闭.
+func ResumeSession(conn transport.Conn, token string, lastSCSeq uint32) (*ClientSession, error) {
+	_resumeMgr.mutex.RLock()
+	s, ok := _resumeMgr.byToken[token]
+	_resumeMgr.mutex.RUnlock()
+
+	if !ok {
+		return nil, ErrResumeInvalidToken
+	}
+
+	// 重发完成前阻塞该会话的其他发送, 新包不会先于重发的包到达
+	s.sendMutex.Lock()
+
+	// 查找凭证后会话可能已经过期或登出
+	s.mutex.Lock()
+	if s.resumeToken != token || s.resumeBuf == nil {
+		s.mutex.Unlock()
+		s.sendMutex.Unlock()
+		return nil, ErrResumeInvalidToken
+	}
+
+	pending, err := s.resumeBuf.since(lastSCSeq)
+	if err != nil {
+		s.mutex.Unlock()
+		s.sendMutex.Unlock()
+		return nil, err
+	}
+	s.resumeBuf.ack(lastSCSeq)
+
+	oldConn := s.conn
+	s.conn = conn
+	if s.detachTimer != nil {
+		s.detachTimer.Stop()
+		s.detachTimer = nil
+	}
+	s.mutex.Unlock()
+
+	_sessionMgr.mutex.Lock()
+	if oldConn != nil {
+		delete(_sessionMgr.byConn, oldConn.GetConnID())
+	}
+	prev := _sessionMgr.byConn[conn.GetConnID()]
+	if prev == s {
+		prev = nil
+	}
+	_sessionMgr.byConn[conn.GetConnID()] = s
+	if prev != nil {
+		if userID := prev.GetUserID(); userID != 0 && _sessionMgr.byUser[userID] == prev {
+			delete(_sessionMgr.byUser, userID)
+		}
+	}
+	_sessionMgr.mutex.Unlock()
+
+	if prev != nil {
+		// 新连接上已经握手时以新上报的版本为准
+		if prev.IsHandshaked() {
+			s.setClientVersion(prev.GetClientVersion())
+		}
+
+		// 与连接关闭时相同, 清理被丢弃会话的绑定、凭证和组
+		prev.unbind()
+		removeResumeToken(prev)
+		prev.leaveAllGroups()
+	}
+
+	if oldConn != nil {
+		_ = oldConn.Close(true)
+	}
+
+	log.Info("session of user %d resume on conn %v, resend %d packets", s.GetUserID(), conn.GetConnID(), len(pending))
+
+	for _, data := range pending {
+		err = conn.Send(data)
+		if err != nil {
+			log.Error("conn %v resend failed for %v", conn.GetConnID(), err)
+			s.sendMutex.Unlock()
+			return s, err
+		}
+	}
+	s.sendMutex.Unlock()
+
+	onSessionEvent(SESSION_EVENT_RESUMED, s)
+
+	return s, nil
+}
+
+// detach 连接断开时保留可恢复的会话, 超过保留时间未恢复则销毁.
+func (s *ClientSession) detach(grace time.Duration) {
+	s.mutex.Lock()
+	s.conn = nil
+	s.detachTimer = time.AfterFunc(grace, func() {
+		expireSession(s)
+	})
+	s.mutex.Unlock()
+
+	onSessionEvent(SESSION_EVENT_DETACHED, s)
+}
+
+func (s *ClientSession) isResumable() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.resumeToken != "" && s.state == SESSION_STATE_AUTHED
+}
+
+// sendResumable 为包分配sc_seq, 缓存后发往当前连接, 断线期间只缓存.
+// sendMutex保证sc_seq的顺序和发送顺序一致. 返回实际发送的连接和header, 未发送时连接为nil.
+func (s *ClientSession) sendResumable(header *csproto.SCHead, msg proto.Message) (transport.Conn, *csproto.SCHead, error) {
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+
+	h := proto.Clone(header).(*csproto.SCHead)
+
+	s.mutex.Lock()
+	s.scSeq++
+	h.ScSeq = s.scSeq
+	conn := s.conn
+	s.mutex.Unlock()
+
+	data, err := getCodec(getSendCodecName(s, conn)).Encode(h, msg)
+	if err != nil {
+		return nil, h, err
+	}
+
+	cfg := getResumeCfg()
+	s.mutex.Lock()
+	if s.resumeBuf != nil {
+		s.resumeBuf.push(h.ScSeq, data, cfg.BufPackets, cfg.BufBytes)
+	}
+	s.mutex.Unlock()
+
+	if conn == nil {
+		return nil, h, nil
+	}
+
+	return conn, h, conn.Send(data)
+}
+
+func expireSession(s *ClientSession) {
+	s.mutex.Lock()
+	if s.conn != nil {
+		s.mutex.Unlock()
+		return
+	}
+	token := s.resumeToken
+	s.detachTimer = nil
+	s.resumeBuf = nil
+	s.mutex.Unlock()
+
+	_resumeMgr.mutex.Lock()
+	delete(_resumeMgr.byToken, token)
+	_resumeMgr.mutex.Unlock()
+
+	_sessionMgr.mutex.Lock()
+	userID := s.GetUserID()
+	if userID != 0 && _sessionMgr.byUser[userID] == s {
+		delete(_sessionMgr.byUser, userID)
+	}
+	_sessionMgr.mutex.Unlock()
+
+	s.leaveAllGroups()
+
+	log.Info("session of user %d expired", userID)
+
+	onSessionEvent(SESSION_EVENT_EXPIRED, s)
+}
+
+func removeResumeToken(s *ClientSession) {
+	s.mutex.Lock()
+	token := s.resumeToken
+	s.resumeToken = ""
+	s.resumeBuf = nil
+	s.mutex.Unlock()
+
+	if token == "" {
+		return
+	}
+
+	_resumeMgr.mutex.Lock()
+	delete(_resumeMgr.byToken, token)
+	_resumeMgr.mutex.Unlock()
+}
@@ -0,0 +1,157 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+type recordConn struct {
+	fakeConn
+	sent   [][]byte
+	closed bool
+}
+
+func (c *recordConn) Send(data []byte) error {
+	c.sent = append(c.sent, data)
+	return nil
+}
+
+func (c *recordConn) Close(active bool) error {
+	c.closed = true
+	return nil
+}
+
+func TestResumeSession(t *testing.T) {
+	SetResumeCfg(&ResumeCfg{Enable: true, GraceSeconds: 60, BufPackets: 2})
+	defer SetResumeCfg(&ResumeCfg{})
+
+	c1 := &recordConn{fakeConn: fakeConn{id: 3001}}
+	OnClientConnOpened(c1)
+
+	sess := GetSession(c1)
+	BindUser(sess, 30, 0)
+
+	token, err := IssueResumeToken(sess)
+	if err != nil || token == "" {
+		t.Fatalf("issue token failed: %v", err)
+	}
+
+	// 推送的seqid为0, 缓存和重发按sc_seq
+	for i := int32(1); i <= 3; i++ {
+		_ = SendToClient(c1, &csproto.SCHead{Msgid: 1}, &csproto.SC_LOGIN{Success: i})
+	}
+
+	header, _, err := decodeSC(c1.sent[2])
+	if err != nil || header.GetScSeq() != 3 || sess.GetSCSeq() != 3 {
+		t.Fatalf("fail: sc seq %d err %v", header.GetScSeq(), err)
+	}
+
+	OnClientConnClosed(c1, false)
+	if GetSessionByUserID(30) != sess || sess.GetConn() != nil {
+		t.Fatalf("fail: session not detached")
+	}
+
+	// 断线期间发出的包只缓存
+	_ = SendToSession(sess, &csproto.SCHead{Msgid: 1}, &csproto.SC_LOGIN{Success: 4})
+
+	c2 := &recordConn{fakeConn: fakeConn{id: 3002}}
+	OnClientConnOpened(c2)
+
+	// sc_seq 2已被淘汰
+	_, err = ResumeSession(c2, token, 1)
+	if !errors.Is(err, ErrResumeGap) {
+		t.Errorf("fail: expect gap, err %v", err)
+	}
+
+	resumed, err := ResumeSession(c2, token, 3)
+	if err != nil || resumed != sess || GetSession(c2) != sess {
+		t.Fatalf("fail: resume err %v", err)
+	}
+
+	if len(c2.sent) != 1 {
+		t.Fatalf("fail: resend %d packets", len(c2.sent))
+	}
+
+	header, body, err := decodeSC(c2.sent[0])
+	if err != nil || header.GetScSeq() != 4 || body.(*csproto.SC_LOGIN).GetSuccess() != 4 {
+		t.Errorf("fail: resend sc seq %d err %v", header.GetScSeq(), err)
+	}
+
+	// 客户端在CSHead中确认后释放缓存
+	RecvClientMsg(c2, buildCSData(t, &csproto.CSHead{Msgid: 1, Seqid: 5, AckScSeq: 4}, &csproto.CS_LOGIN{}))
+	sess.mutex.RLock()
+	buffered := len(sess.resumeBuf.entries)
+	sess.mutex.RUnlock()
+	if buffered != 1 || sess.GetSCSeq() != 5 {
+		t.Errorf("fail: buffered %d sc seq %d after ack", buffered, sess.GetSCSeq())
+	}
+
+	UnbindUser(sess)
+	if _, err = ResumeSession(c2, token, 5); !errors.Is(err, ErrResumeInvalidToken) {
+		t.Errorf("fail: token valid after logout")
+	}
+
+	// 查找凭证和会话过期并发时, 会话上的凭证已清除
+	_resumeMgr.mutex.Lock()
+	_resumeMgr.byToken[token] = sess
+	_resumeMgr.mutex.Unlock()
+	if _, err = ResumeSession(c2, token, 5); !errors.Is(err, ErrResumeInvalidToken) {
+		t.Errorf("fail: stale token lookup err %v", err)
+	}
+	_resumeMgr.mutex.Lock()
+	delete(_resumeMgr.byToken, token)
+	_resumeMgr.mutex.Unlock()
+
+	OnClientConnClosed(c2, false)
+}
+
+func TestResumeDropsNewConnSession(t *testing.T) {
+	SetResumeCfg(&ResumeCfg{Enable: true, GraceSeconds: 60, BufPackets: 2})
+	defer SetResumeCfg(&ResumeCfg{})
+
+	c1 := &recordConn{fakeConn: fakeConn{id: 3101}}
+	OnClientConnOpened(c1)
+
+	sess := GetSession(c1)
+	BindUser(sess, 31, 0)
+
+	token, err := IssueResumeToken(sess)
+	if err != nil {
+		t.Fatalf("issue token failed: %v", err)
+	}
+	OnClientConnClosed(c1, false)
+
+	// 新连接上先以其他用户登录并加入分组, 再恢复旧会话
+	c2 := &recordConn{fakeConn: fakeConn{id: 3102}}
+	OnClientConnOpened(c2)
+	defer OnClientConnClosed(c2, false)
+
+	prev := GetSession(c2)
+	BindUser(prev, 32, 0)
+	prevToken, _ := IssueResumeToken(prev)
+
+	g, err := CreateGroup("resume_prev")
+	if err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	defer DestroyGroup("resume_prev")
+	_ = g.Join(prev)
+
+	if _, err := ResumeSession(c2, token, 0); err != nil {
+		t.Fatalf("fail: resume err %v", err)
+	}
+
+	if GetSession(c2) != sess || GetSessionByUserID(32) != nil || GetSessionByUserID(31) != sess {
+		t.Errorf("fail: user binding of dropped session remains")
+	}
+
+	if g.HasMember(prev) || g.GetMemberCount() != 0 {
+		t.Errorf("fail: dropped session still in group")
+	}
+
+	if _, err := ResumeSession(c2, prevToken, 0); !errors.Is(err, ErrResumeInvalidToken) {
+		t.Errorf("fail: dropped session token still valid, err %v", err)
+	}
+}
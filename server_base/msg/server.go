@@ -0,0 +1,652 @@
+package msg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/ssproto"
+	"github.com/nearmeng/mango-go/server_base/event"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+)
+
+// ss msg kind.
+const (
+	SS_KIND_NOTIFY   = 0
+	SS_KIND_REQUEST  = 1
+	SS_KIND_RESPONSE = 2
+)
+
+const (
+	_defaultSSRequestTimeoutMs = 3000
+	_serverCallbackTopic       = "msg.server_callback"
+)
+
+var (
+	ErrServerIDInvalid   = errors.New("msg: invalid server id")
+	ErrServerNoRoute     = errors.New("msg: no route to server")
+	ErrServerCallTimeout = errors.New("msg: server call timeout")
+)
+
+type ServerPeerCfg struct {
+	ServerID string `mapstructure:"serverid"`
+	Addr     string `mapstructure:"addr"`
+}
+
+// ServerBusCfg 服务器间通信配置, peers为静态路由表, 收发都使用listener指定的监听器.
+type ServerBusCfg struct {
+	Listener         string          `mapstructure:"listener"`
+	RequestTimeoutMs uint32          `mapstructure:"request_timeout_ms"`
+	Peers            []ServerPeerCfg `mapstructure:"peers"`
+}
+
+// ServerSender 发往对端服务器的通道, 如tcp.TcpConnector.
+type ServerSender interface {
+	Send(data []byte) error
+}
+
+// ServerCallback CallServerAsync的回调, 由主循环在逻辑goroutine上执行.
+type ServerCallback func(rsp proto.Message, err error)
+
+type pendingCall struct {
+	cb    ServerCallback
+	timer *time.Timer
+	// 同步调用直接在收包或定时器协程上回调, 不经过主循环
+	direct bool
+}
+
+// pendingKey 回包需要来自请求的目标服务器, 其他服务器复用seqid不能完成该请求.
+type pendingKey struct {
+	serverID string
+	seqid    uint64
+}
+
+type serverCallbackEvent struct {
+	cb  ServerCallback
+	rsp proto.Message
+	err error
+}
+
+// done 异步调用的回调投递到主循环执行.
+func (c *pendingCall) done(rsp proto.Message, err error) {
+	if c.direct {
+		c.cb(rsp, err)
+		return
+	}
+
+	e := event.PublishAsync(_serverCallbackTopic, &serverCallbackEvent{cb: c.cb, rsp: rsp, err: err})
+	if e != nil {
+		log.Error("server callback publish failed, drop, err %v", e)
+	}
+}
+
+func init() {
+	_, err := event.Subscribe(_serverCallbackTopic, 0, func(e *serverCallbackEvent) {
+		e.cb(e.rsp, e.err)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// serverBus 静态路由优先, 没有静态路由时使用对端主动连入的连接.
+type serverBus struct {
+	mutex    sync.RWMutex
+	serverID string
+	timeout  time.Duration
+	peers    map[string]ServerSender
+	learned  map[string]transport.Conn
+	// 每个连接只能学习一个服务器id
+	learnedBy map[transport.Conn]string
+	pending   map[pendingKey]*pendingCall
+	seqid     atomic.Uint64
+}
+
+var (
+	_serverBus = &serverBus{
+		timeout:   _defaultSSRequestTimeoutMs * time.Millisecond,
+		peers:     make(map[string]ServerSender),
+		learned:   make(map[string]transport.Conn),
+		learnedBy: make(map[transport.Conn]string),
+		pending:   make(map[pendingKey]*pendingCall),
+	}
+)
+
+// SetServerID 设置本服务器id, 作为发出消息的src.
+func SetServerID(serverID string) {
+	_serverBus.mutex.Lock()
+	defer _serverBus.mutex.Unlock()
+
+	_serverBus.serverID = serverID
+}
+
+func GetServerID() string {
+	_serverBus.mutex.RLock()
+	defer _serverBus.mutex.RUnlock()
+
+	return _serverBus.serverID
+}
+
+// GetServerType 服务器id的第一段为服务器类型, 如101.0.0.1的类型为101.
+func GetServerType(serverID string) (int32, error) {
+	seg := serverID
+	if i := strings.IndexByte(serverID, '.'); i >= 0 {
+		seg = serverID[:i]
+	}
+
+	t, err := strconv.ParseInt(seg, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrServerIDInvalid, serverID)
+	}
+
+	return int32(t), nil
+}
+
+// SetServerBusCfg 设置请求超时, reload时可重复调用, 静态路由由AddServerPeer添加.
+func SetServerBusCfg(cfg *ServerBusCfg) {
+	timeout := time.Duration(cfg.RequestTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = _defaultSSRequestTimeoutMs * time.Millisecond
+	}
+
+	_serverBus.mutex.Lock()
+	defer _serverBus.mutex.Unlock()
+
+	_serverBus.timeout = timeout
+
+	log.Info("server bus cfg set, request timeout %v peers %d", timeout, len(cfg.Peers))
+}
+
+// AddServerPeer 添加静态路由, 发往serverID的消息通过sender发送.
+func AddServerPeer(serverID string, sender ServerSender) error {
+	_, err := GetServerType(serverID)
+	if err != nil {
+		return err
+	}
+
+	_serverBus.mutex.Lock()
+	defer _serverBus.mutex.Unlock()
+
+	_serverBus.peers[serverID] = sender
+	return nil
+}
+
+func RemoveServerPeer(serverID string) {
+	_serverBus.mutex.Lock()
+	defer _serverBus.mutex.Unlock()
+
+	delete(_serverBus.peers, serverID)
+}
+
+// GetServerPeers 按id排序返回所有静态路由.
+func GetServerPeers() []string {
+	_serverBus.mutex.RLock()
+	result := make([]string, 0, len(_serverBus.peers))
+	for id := range _serverBus.peers {
+		result = append(result, id)
+	}
+	_serverBus.mutex.RUnlock()
+
+	sort.Strings(result)
+	return result
+}
+
+func (b *serverBus) getTimeout() time.Duration {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.timeout
+}
+
+func (b *serverBus) getRoute(serverID string) ServerSender {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if sender, ok := b.peers[serverID]; ok {
+		return sender
+	}
+
+	if conn, ok := b.learned[serverID]; ok {
+		return conn
+	}
+
+	return nil
+}
+
+// getRoutesByType 获取所有该类型服务器的路由, 不包括自己.
+func (b *serverBus) getRoutesByType(serverType int32) map[string]ServerSender {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	result := make(map[string]ServerSender)
+
+	for id, conn := range b.learned {
+		if t, err := GetServerType(id); err == nil && t == serverType && id != b.serverID {
+			result[id] = conn
+		}
+	}
+
+	for id, sender := range b.peers {
+		if t, err := GetServerType(id); err == nil && t == serverType && id != b.serverID {
+			result[id] = sender
+		}
+	}
+
+	return result
+}
+
+// learn 记录对端服务器所在的连接, 用于回复没有静态路由的服务器.
+// 有静态路由的id不学习, 每个连接只学习第一次声明的id, 已被其他连接学习的id在该连接断开前不会被覆盖.
+func (b *serverBus) learn(serverID string, conn transport.Conn) {
+	b.mutex.RLock()
+	id, bound := b.learnedBy[conn]
+	b.mutex.RUnlock()
+
+	if bound {
+		if id != serverID {
+			log.Error("conn %v learned as server %s, ignore src %s", conn.GetConnID(), id, serverID)
+		}
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.learnedBy[conn]; ok {
+		return
+	}
+
+	if _, ok := b.peers[serverID]; ok {
+		return
+	}
+
+	if old, ok := b.learned[serverID]; ok && old != conn {
+		log.Error("server %s already learned on conn %v, ignore conn %v", serverID, old.GetConnID(), conn.GetConnID())
+		return
+	}
+
+	b.learned[serverID] = conn
+	b.learnedBy[conn] = serverID
+}
+
+// addPending 在锁内启动超时定时器, 保证超时回调一定能取到请求.
+func (b *serverBus) addPending(key pendingKey, cb ServerCallback, direct bool, timeout time.Duration, onTimeout func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.pending[key] = &pendingCall{
+		cb:     cb,
+		timer:  time.AfterFunc(timeout, onTimeout),
+		direct: direct,
+	}
+}
+
+func (b *serverBus) takePending(key pendingKey) *pendingCall {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	call, ok := b.pending[key]
+	if !ok {
+		return nil
+	}
+
+	delete(b.pending, key)
+	return call
+}
+
+func OnServerConnOpened(conn transport.Conn) {
+	log.Info("server connect by connid %v", conn.GetConnID())
+}
+
+// OnServerConnClosed 移除通过该连接学习到的路由.
+func OnServerConnClosed(conn transport.Conn, active bool) {
+	log.Info("server disconnect of connid %v active %v", conn.GetConnID(), active)
+
+	_serverBus.mutex.Lock()
+	defer _serverBus.mutex.Unlock()
+
+	id, ok := _serverBus.learnedBy[conn]
+	if !ok {
+		return
+	}
+
+	delete(_serverBus.learnedBy, conn)
+	if _serverBus.learned[id] == conn {
+		delete(_serverBus.learned, id)
+	}
+}
+
+//=====================================================
+
+// encodeSS 与客户端消息格式相同, 4字节header长度后接SSHead和消息体.
+func encodeSS(header *ssproto.SSHead, body proto.Message) ([]byte, error) {
+	headerSize := proto.Size(header)
+	bodySize := proto.Size(body)
+
+	if 4+headerSize+bodySize > _maxBuffSize {
+		return nil, fmt.Errorf("%w: size %d max buff size %d", ErrMsgTooLarge, 4+headerSize+bodySize, _maxBuffSize)
+	}
+
+	buff := make([]byte, 4, 4+headerSize+bodySize)
+	binary.LittleEndian.PutUint32(buff, uint32(headerSize))
+
+	buff, err := _marshalOpt.MarshalAppend(buff, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return _marshalOpt.MarshalAppend(buff, body)
+}
+
+// decodeSS 错误回包和消息号为0的回包没有消息体, header解析成功而消息体失败时同时返回header.
+func decodeSS(data []byte) (*ssproto.SSHead, proto.Message, error) {
+	var header ssproto.SSHead
+
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("%w: data size %d", ErrMsgTruncated, len(data))
+	}
+
+	headerSize := binary.LittleEndian.Uint32(data[0:4])
+	if uint64(headerSize) > uint64(len(data)-4) {
+		return nil, nil, fmt.Errorf("%w: header size %d data size %d", ErrMsgTruncated, headerSize, len(data))
+	}
+
+	err := proto.Unmarshal(data[4:4+headerSize], &header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: header unmarshal failed, %v", ErrMsgMalformed, err)
+	}
+
+	if header.GetKind() == SS_KIND_RESPONSE && (header.GetResult() != RESULT_OK || header.GetMsgid() == 0) {
+		return &header, nil, nil
+	}
+
+	msgid := header.GetMsgid()
+	info := GetMsgInfo(MSG_DIRECTION_SS, msgid)
+	if info == nil {
+		return &header, nil, fmt.Errorf("%w: ss msgid %d is not register", ErrMsgUnknownMsgID, msgid)
+	}
+
+	msg := info.Type.New().Interface()
+	err = proto.Unmarshal(data[4+headerSize:], msg)
+	if err != nil {
+		return &header, nil, fmt.Errorf("%w: body unmarshal failed, %v", ErrMsgMalformed, err)
+	}
+
+	return &header, msg, nil
+}
+
+func sendSS(header *ssproto.SSHead, msg proto.Message) error {
+	sender := _serverBus.getRoute(header.GetDst())
+	if sender == nil {
+		return fmt.Errorf("%w: %s", ErrServerNoRoute, header.GetDst())
+	}
+
+	data, err := encodeSS(header, msg)
+	if err != nil {
+		return err
+	}
+
+	return sender.Send(data)
+}
+
+// SendToServer 向指定id的服务器发送通知.
+func SendToServer(dst string, msgid int32, msg proto.Message) error {
+	header := &ssproto.SSHead{
+		Msgid: msgid,
+		Src:   GetServerID(),
+		Dst:   dst,
+		Kind:  SS_KIND_NOTIFY,
+	}
+
+	err := sendSS(header, msg)
+	if err != nil {
+		log.Error("send to server %s msgid %d failed, err %v", dst, msgid, err)
+	}
+
+	return err
+}
+
+// BroadcastToServerType 向路由中所有该类型的服务器发送通知, 不包括自己, 返回发送成功的数量.
+func BroadcastToServerType(serverType int32, msgid int32, msg proto.Message) (int, error) {
+	header := &ssproto.SSHead{
+		Msgid:   msgid,
+		Src:     GetServerID(),
+		DstType: serverType,
+		Kind:    SS_KIND_NOTIFY,
+	}
+
+	data, err := encodeSS(header, msg)
+	if err != nil {
+		log.Error("broadcast to server type %d msgid %d encode failed, err %v", serverType, msgid, err)
+		return 0, err
+	}
+
+	count := 0
+	for id, sender := range _serverBus.getRoutesByType(serverType) {
+		err = sender.Send(data)
+		if err != nil {
+			log.Error("broadcast to server %s msgid %d failed, err %v", id, msgid, err)
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// CallServerAsync 向指定id的服务器发送请求, 收到回包或超时后在主循环中回调cb, timeout为0时使用配置的超时.
+// 对端返回错误码时err为*ResultError.
+func CallServerAsync(dst string, msgid int32, req proto.Message, timeout time.Duration, cb ServerCallback) error {
+	return callServer(dst, msgid, req, timeout, cb, false)
+}
+
+func callServer(dst string, msgid int32, req proto.Message, timeout time.Duration, cb ServerCallback, direct bool) error {
+	if timeout <= 0 {
+		timeout = _serverBus.getTimeout()
+	}
+
+	seqid := _serverBus.seqid.Inc()
+	header := &ssproto.SSHead{
+		Msgid: msgid,
+		Seqid: seqid,
+		Src:   GetServerID(),
+		Dst:   dst,
+		Kind:  SS_KIND_REQUEST,
+	}
+
+	key := pendingKey{serverID: dst, seqid: seqid}
+	_serverBus.addPending(key, cb, direct, timeout, func() {
+		if c := _serverBus.takePending(key); c != nil {
+			c.done(nil, fmt.Errorf("%w: dst %s msgid %d seqid %d", ErrServerCallTimeout, dst, msgid, seqid))
+		}
+	})
+
+	err := sendSS(header, req)
+	if err != nil {
+		if c := _serverBus.takePending(key); c != nil {
+			c.timer.Stop()
+		}
+		log.Error("call server %s msgid %d failed, err %v", dst, msgid, err)
+		return err
+	}
+
+	return nil
+}
+
+// CallServer 同步请求, 阻塞到收到回包或超时.
+// 回包由收包协程分发, 在服务器消息处理中调用可能阻塞回包所在的连接, 此时应使用CallServerAsync.
+func CallServer(dst string, msgid int32, req proto.Message, timeout time.Duration) (proto.Message, error) {
+	type result struct {
+		rsp proto.Message
+		err error
+	}
+
+	ch := make(chan result, 1)
+
+	// 回调直接写入channel, 在主循环中调用时不会等待主循环自己
+	err := callServer(dst, msgid, req, timeout, func(rsp proto.Message, err error) {
+		ch <- result{rsp: rsp, err: err}
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := <-ch
+	return r.rsp, r.err
+}
+
+// RecvServerMsg 处理其他服务器发来的消息, 目标不是本服务器的消息被丢弃.
+func RecvServerMsg(conn transport.Conn, data []byte) {
+	header, msg, err := decodeSS(data)
+	if header == nil {
+		log.Error("conn %v server msg decode failed, err %v", conn.GetConnID(), err)
+		return
+	}
+
+	if src := header.GetSrc(); src != "" {
+		_serverBus.learn(src, conn)
+	}
+
+	if dst := header.GetDst(); dst != "" && dst != GetServerID() {
+		log.Error("server msg from %s msgid %d dst %s is not self, drop", header.GetSrc(), header.GetMsgid(), dst)
+		return
+	}
+
+	switch header.GetKind() {
+	case SS_KIND_RESPONSE:
+		onServerResponse(header, msg, err)
+	case SS_KIND_REQUEST:
+		if err != nil {
+			log.Error("server %s request msgid %d decode failed, err %v", header.GetSrc(), header.GetMsgid(), err)
+			replyServer(conn, header, nil, decodeResultError(err))
+			return
+		}
+		onServerRequest(conn, header, msg)
+	default:
+		if err != nil {
+			log.Error("server %s msg msgid %d decode failed, err %v", header.GetSrc(), header.GetMsgid(), err)
+			return
+		}
+		onServerNotify(header, msg)
+	}
+}
+
+func decodeResultError(err error) error {
+	if errors.Is(err, ErrMsgUnknownMsgID) {
+		return NewResultError(RESULT_ERR_UNKNOWN_MSGID, err.Error())
+	}
+	return NewResultError(RESULT_ERR_DECODE, err.Error())
+}
+
+func onServerResponse(header *ssproto.SSHead, msg proto.Message, err error) {
+	call := _serverBus.takePending(pendingKey{serverID: header.GetSrc(), seqid: header.GetSeqid()})
+	if call == nil {
+		log.Error("server %s response msgid %d seqid %d has no request to it, maybe timeout",
+			header.GetSrc(), header.GetMsgid(), header.GetSeqid())
+		return
+	}
+	call.timer.Stop()
+
+	if err == nil && header.GetResult() != RESULT_OK {
+		err = NewResultError(header.GetResult(), header.GetErrmsg())
+	}
+
+	call.done(msg, err)
+}
+
+func onServerNotify(header *ssproto.SSHead, msg proto.Message) {
+	msgHandlerMgr.mutex.RLock()
+	h, ok := msgHandlerMgr.serverMsgHandler[header.GetMsgid()]
+	msgHandlerMgr.mutex.RUnlock()
+
+	if !ok {
+		log.Error("server msgid %d is not register", header.GetMsgid())
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_panicCount.Inc()
+			log.Error("server msgid %d handler panic: %v\n%s", header.GetMsgid(), r, debug.Stack())
+		}
+	}()
+
+	h(header, msg)
+}
+
+func onServerRequest(conn transport.Conn, header *ssproto.SSHead, req proto.Message) {
+	msgHandlerMgr.mutex.RLock()
+	h, ok := msgHandlerMgr.serverReqHandler[header.GetMsgid()]
+	msgHandlerMgr.mutex.RUnlock()
+
+	if !ok {
+		log.Error("server request msgid %d is not register", header.GetMsgid())
+		replyServer(conn, header, nil, NewResultError(RESULT_ERR_NO_HANDLER, GetErrCodeDesc(RESULT_ERR_NO_HANDLER)))
+		return
+	}
+
+	rsp, err := callServerRequestHandler(h, header, req)
+	replyServer(conn, header, rsp, err)
+}
+
+func callServerRequestHandler(h ServerRequestHandler, header *ssproto.SSHead, req proto.Message) (rsp proto.Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			_panicCount.Inc()
+			log.Error("server request msgid %d handler panic: %v\n%s", header.GetMsgid(), r, debug.Stack())
+			rsp, err = nil, NewResultError(RESULT_ERR_INTERNAL, GetErrCodeDesc(RESULT_ERR_INTERNAL))
+		}
+	}()
+
+	return h(header, req)
+}
+
+// replyServer 回包从请求所在的连接发回, 回包为nil时消息号为0.
+func replyServer(conn transport.Conn, reqHeader *ssproto.SSHead, rsp proto.Message, err error) {
+	header := &ssproto.SSHead{
+		Seqid: reqHeader.GetSeqid(),
+		Src:   GetServerID(),
+		Dst:   reqHeader.GetSrc(),
+		Kind:  SS_KIND_RESPONSE,
+	}
+
+	if err == nil && rsp != nil {
+		info := GetMsgInfoByMessage(MSG_DIRECTION_SS, rsp)
+		if info != nil {
+			header.Msgid = info.Msgid
+		} else {
+			err = fmt.Errorf("response %s is not register", rsp.ProtoReflect().Descriptor().FullName())
+		}
+	}
+
+	if err != nil {
+		header.Msgid = reqHeader.GetMsgid()
+		header.Result = GetResultCode(err)
+		header.Errmsg = err.Error()
+
+		var re *ResultError
+		if errors.As(err, &re) {
+			header.Errmsg = re.Msg
+		}
+
+		rsp = nil
+	}
+
+	data, err := encodeSS(header, rsp)
+	if err == nil {
+		err = conn.Send(data)
+	}
+
+	if err != nil {
+		log.Error("reply server %s msgid %d failed, err %v", reqHeader.GetSrc(), reqHeader.GetMsgid(), err)
+	}
+}
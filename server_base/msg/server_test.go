@@ -0,0 +1,278 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"github.com/nearmeng/mango-go/proto/ssproto"
+	"github.com/nearmeng/mango-go/server_base/event"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	_testSSReq = 101
+	_testSSRsp = 102
+)
+
+// peerSender 模拟对端服务器, 收到请求后异步回包.
+type peerSender struct {
+	id      string
+	conn    *recordConn
+	headers []*ssproto.SSHead
+	drop    bool
+}
+
+func (p *peerSender) Send(data []byte) error {
+	header, req, err := decodeSS(data)
+	if err != nil {
+		return err
+	}
+	p.headers = append(p.headers, header)
+
+	if header.GetKind() != SS_KIND_REQUEST || p.drop {
+		return nil
+	}
+
+	rspHeader := &ssproto.SSHead{Msgid: _testSSRsp, Seqid: header.GetSeqid(), Src: p.id, Dst: header.GetSrc(), Kind: SS_KIND_RESPONSE}
+	rsp := &csproto.SC_LOGIN{Success: int32(len(req.(*csproto.CS_LOGIN).GetName()))}
+	rspData, err := encodeSS(rspHeader, rsp)
+	if err != nil {
+		return err
+	}
+
+	go RecvServerMsg(p.conn, rspData)
+	return nil
+}
+
+func TestServerBus(t *testing.T) {
+	_ = RegisterSSMsg(_testSSReq, &csproto.CS_LOGIN{})
+	_ = RegisterSSMsg(_testSSRsp, &csproto.SC_LOGIN{})
+
+	SetServerID("101.0.0.1")
+	defer SetServerID("")
+
+	p1 := &peerSender{id: "102.0.0.1", conn: &recordConn{fakeConn: fakeConn{id: 8001}}}
+	p2 := &peerSender{id: "102.0.0.2", conn: &recordConn{fakeConn: fakeConn{id: 8002}}, drop: true}
+	p3 := &peerSender{id: "103.0.0.1", conn: &recordConn{fakeConn: fakeConn{id: 8003}}}
+	for _, p := range []*peerSender{p1, p2, p3} {
+		if err := AddServerPeer(p.id, p); err != nil {
+			t.Fatalf("add peer failed: %v", err)
+		}
+		defer RemoveServerPeer(p.id)
+	}
+
+	rsp, err := CallServer("102.0.0.1", _testSSReq, &csproto.CS_LOGIN{Name: "abcd"}, time.Second)
+	if err != nil || rsp.(*csproto.SC_LOGIN).GetSuccess() != 4 {
+		t.Errorf("fail: call rsp %v err %v", rsp, err)
+	}
+
+	_, err = CallServer("102.0.0.2", _testSSReq, &csproto.CS_LOGIN{}, 10*time.Millisecond)
+	if !errors.Is(err, ErrServerCallTimeout) {
+		t.Errorf("fail: expect timeout, err %v", err)
+	}
+
+	err = SendToServer("104.0.0.1", _testSSReq, &csproto.CS_LOGIN{})
+	if !errors.Is(err, ErrServerNoRoute) {
+		t.Errorf("fail: expect no route, err %v", err)
+	}
+
+	n, err := BroadcastToServerType(102, _testSSReq, &csproto.CS_LOGIN{})
+	if err != nil || n != 2 || len(p3.headers) != 0 {
+		t.Errorf("fail: broadcast count %d err %v", n, err)
+	}
+
+	h := p1.headers[len(p1.headers)-1]
+	if h.GetSrc() != "101.0.0.1" || h.GetDst() != "" || h.GetDstType() != 102 {
+		t.Errorf("fail: broadcast header %v", h)
+	}
+}
+
+func TestRecvServerRequest(t *testing.T) {
+	_ = RegisterSSMsg(_testSSReq, &csproto.CS_LOGIN{})
+	_ = RegisterSSMsg(_testSSRsp, &csproto.SC_LOGIN{})
+
+	SetServerID("101.0.0.1")
+	defer SetServerID("")
+
+	err := RegisterServerRequestHandler(_testSSReq, func(header *ssproto.SSHead, req proto.Message) (proto.Message, error) {
+		if req.(*csproto.CS_LOGIN).GetName() == "" {
+			return nil, NewResultError(RESULT_USER_BEGIN+3, "empty name")
+		}
+		return &csproto.SC_LOGIN{Success: 1}, nil
+	})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	defer delete(msgHandlerMgr.serverReqHandler, _testSSReq)
+
+	conn := &recordConn{fakeConn: fakeConn{id: 8101}}
+	defer OnServerConnClosed(conn, false)
+
+	send := func(name string) {
+		data, err := encodeSS(&ssproto.SSHead{Msgid: _testSSReq, Seqid: 5, Src: "105.0.0.1", Dst: "101.0.0.1", Kind: SS_KIND_REQUEST},
+			&csproto.CS_LOGIN{Name: name})
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		RecvServerMsg(conn, data)
+	}
+
+	send("a")
+	send("")
+
+	if len(conn.sent) != 2 {
+		t.Fatalf("fail: sent %d", len(conn.sent))
+	}
+
+	header, body, err := decodeSS(conn.sent[0])
+	if err != nil || header.GetMsgid() != _testSSRsp || header.GetSeqid() != 5 || header.GetDst() != "105.0.0.1" ||
+		body.(*csproto.SC_LOGIN).GetSuccess() != 1 {
+		t.Errorf("fail: rsp header %v body %v err %v", header, body, err)
+	}
+
+	header, _, _ = decodeSS(conn.sent[1])
+	if header.GetResult() != RESULT_USER_BEGIN+3 || header.GetErrmsg() != "empty name" {
+		t.Errorf("fail: err rsp header %v", header)
+	}
+
+	// 请求方没有静态路由, 通过来源连接回复
+	if _serverBus.getRoute("105.0.0.1") != conn {
+		t.Errorf("fail: route not learned")
+	}
+}
+
+func TestCallServerAsyncMainLoop(t *testing.T) {
+	_ = RegisterSSMsg(_testSSReq, &csproto.CS_LOGIN{})
+	_ = RegisterSSMsg(_testSSRsp, &csproto.SC_LOGIN{})
+
+	SetServerID("101.0.0.1")
+	defer SetServerID("")
+
+	p := &peerSender{id: "102.0.0.3", conn: &recordConn{fakeConn: fakeConn{id: 8201}}}
+	if err := AddServerPeer(p.id, p); err != nil {
+		t.Fatalf("add peer failed: %v", err)
+	}
+	defer RemoveServerPeer(p.id)
+
+	var (
+		done    bool
+		success int32
+	)
+
+	err := CallServerAsync(p.id, _testSSReq, &csproto.CS_LOGIN{Name: "abc"}, time.Second, func(rsp proto.Message, err error) {
+		done = true
+		if err == nil {
+			success = rsp.(*csproto.SC_LOGIN).GetSuccess()
+		}
+	})
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	// 回调只在主循环Dispatch时执行
+	deadline := time.Now().Add(time.Second)
+	for !done && time.Now().Before(deadline) {
+		select {
+		case <-event.Notify():
+			event.Dispatch()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if !done || success != 3 {
+		t.Errorf("fail: async callback done %v success %d", done, success)
+	}
+}
+
+func TestServerRouteLearn(t *testing.T) {
+	_ = RegisterSSMsg(_testSSReq, &csproto.CS_LOGIN{})
+
+	SetServerID("101.0.0.1")
+	defer SetServerID("")
+
+	static := &peerSender{id: "102.0.0.9"}
+	if err := AddServerPeer(static.id, static); err != nil {
+		t.Fatalf("add peer failed: %v", err)
+	}
+	defer RemoveServerPeer(static.id)
+
+	c1 := &recordConn{fakeConn: fakeConn{id: 8301}}
+	c2 := &recordConn{fakeConn: fakeConn{id: 8302}}
+	defer OnServerConnClosed(c1, false)
+	defer OnServerConnClosed(c2, false)
+
+	notify := func(conn *recordConn, src string) {
+		data, err := encodeSS(&ssproto.SSHead{Msgid: _testSSReq, Src: src, Dst: "101.0.0.1", Kind: SS_KIND_NOTIFY}, &csproto.CS_LOGIN{})
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		RecvServerMsg(conn, data)
+	}
+
+	notify(c1, static.id)
+	notify(c1, "106.0.0.1")
+	notify(c1, "106.0.0.2")
+	notify(c2, "106.0.0.1")
+
+	if _serverBus.getRoute(static.id) != static {
+		t.Errorf("fail: static route overwritten")
+	}
+
+	if _serverBus.getRoute("106.0.0.1") != c1 || _serverBus.getRoute("106.0.0.2") != nil {
+		t.Errorf("fail: conn should learn only its first id")
+	}
+
+	OnServerConnClosed(c1, false)
+	notify(c2, "106.0.0.1")
+	if _serverBus.getRoute("106.0.0.1") != c2 {
+		t.Errorf("fail: route not relearned after close")
+	}
+}
+
+func TestServerResponseSrcMismatch(t *testing.T) {
+	_ = RegisterSSMsg(_testSSReq, &csproto.CS_LOGIN{})
+	_ = RegisterSSMsg(_testSSRsp, &csproto.SC_LOGIN{})
+
+	SetServerID("101.0.0.1")
+	defer SetServerID("")
+
+	p := &peerSender{id: "102.0.0.4", drop: true}
+	if err := AddServerPeer(p.id, p); err != nil {
+		t.Fatalf("add peer failed: %v", err)
+	}
+	defer RemoveServerPeer(p.id)
+
+	var results []error
+	err := CallServerAsync(p.id, _testSSReq, &csproto.CS_LOGIN{}, time.Second, func(rsp proto.Message, err error) {
+		results = append(results, err)
+	})
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	conn := &recordConn{fakeConn: fakeConn{id: 8401}}
+	defer OnServerConnClosed(conn, false)
+
+	respond := func(src string) {
+		data, err := encodeSS(&ssproto.SSHead{Msgid: _testSSRsp, Seqid: p.headers[0].GetSeqid(), Src: src, Dst: "101.0.0.1", Kind: SS_KIND_RESPONSE},
+			&csproto.SC_LOGIN{})
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		RecvServerMsg(conn, data)
+		event.Dispatch()
+	}
+
+	// 其他服务器复用seqid的回包不能完成请求
+	respond("102.0.0.5")
+	if len(results) != 0 {
+		t.Errorf("fail: call completed by other server")
+	}
+
+	respond(p.id)
+	if len(results) != 1 || results[0] != nil {
+		t.Errorf("fail: call results %v", results)
+	}
+}
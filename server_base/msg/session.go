@@ -1,4 +1,308 @@
 package msg
 
+import (
+	"sync"
+	"time"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+)
+
+// session state.
+const (
+	SESSION_STATE_CONNECTED = 0
+	SESSION_STATE_AUTHED    = 1
+)
+
+// ClientSession 客户端连接对应的会话, 连接建立时创建, 断开时销毁.
 type ClientSession struct {
+	mutex     sync.RWMutex
+	conn      transport.Conn
+	state     int32
+	userID    uint64
+	roleID    uint64
+	loginTime time.Time
+	lastSeqid int32
+	attrs     map[string]interface{}
+	codec     string
+
+	version    ClientVersion
+	handshaked bool
+
+	resumeToken string
+	resumeBuf   *resumeBuffer
+	detachTimer *time.Timer
+	// 可恢复会话的下行序号, 由sendMutex保证和发送顺序一致
+	scSeq     uint32
+	sendMutex sync.Mutex
+
+	// 所在的分组, 由_groupMgr.mutex保护
+	groups       map[*Group]struct{}
+	groupsClosed bool
+}
+
+func newClientSession(conn transport.Conn) *ClientSession {
+	return &ClientSession{
+		conn:  conn,
+		state: SESSION_STATE_CONNECTED,
+	}
+}
+
+// GetConn 获取会话当前的连接, 断线等待恢复期间返回nil.
+func (s *ClientSession) GetConn() transport.Conn {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.conn
+}
+
+func (s *ClientSession) GetConnID() uint64 {
+	conn := s.GetConn()
+	if conn == nil {
+		return 0
+	}
+	return conn.GetConnID()
+}
+
+func (s *ClientSession) GetState() int32 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.state
+}
+
+func (s *ClientSession) IsAuthed() bool {
+	return s.GetState() == SESSION_STATE_AUTHED
+}
+
+func (s *ClientSession) GetUserID() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.userID
+}
+
+func (s *ClientSession) GetRoleID() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.roleID
+}
+
+// SetRoleID 选角或切换角色时更新, 不影响用户绑定.
+func (s *ClientSession) SetRoleID(roleID uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.roleID = roleID
+}
+
+// GetCodecName 会话使用的codec, 收到第一个包之前为空.
+func (s *ClientSession) GetCodecName() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.codec
+}
+
+func (s *ClientSession) GetLoginTime() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.loginTime
+}
+
+// GetLastSeqid 最近一次收到的客户端消息序号.
+func (s *ClientSession) GetLastSeqid() int32 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.lastSeqid
+}
+
+func (s *ClientSession) setLastSeqid(seqid int32) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastSeqid = seqid
+}
+
+func (s *ClientSession) GetAttr(key string) (interface{}, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, ok := s.attrs[key]
+	return v, ok
+}
+
+func (s *ClientSession) SetAttr(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.attrs == nil {
+		s.attrs = make(map[string]interface{})
+	}
+	s.attrs[key] = value
+}
+
+// getOrSetAttr 属性不存在时用create的结果初始化.
+func (s *ClientSession) getOrSetAttr(key string, create func() interface{}) interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, ok := s.attrs[key]
+	if ok {
+		return v
+	}
+
+	if s.attrs == nil {
+		s.attrs = make(map[string]interface{})
+	}
+
+	v = create()
+	s.attrs[key] = v
+	return v
+}
+
+func (s *ClientSession) DelAttr(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.attrs, key)
+}
+
+//=====================================================
+
+type sessionMgr struct {
+	mutex  sync.RWMutex
+	byConn map[uint64]*ClientSession
+	byUser map[uint64]*ClientSession
+}
+
+var (
+	_sessionMgr = &sessionMgr{
+		byConn: make(map[uint64]*ClientSession),
+		byUser: make(map[uint64]*ClientSession),
+	}
+)
+
+func (m *sessionMgr) add(conn transport.Conn) *ClientSession {
+	s := newClientSession(conn)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.byConn[conn.GetConnID()] = s
+	return s
+}
+
+// remove 连接断开时销毁会话, 可恢复的会话保留到恢复或过期.
+func (m *sessionMgr) remove(conn transport.Conn) {
+	m.mutex.Lock()
+
+	s, ok := m.byConn[conn.GetConnID()]
+	if !ok || s.GetConn() != conn {
+		m.mutex.Unlock()
+		return
+	}
+
+	delete(m.byConn, conn.GetConnID())
+
+	cfg := getResumeCfg()
+	if cfg.Enable && s.isResumable() {
+		m.mutex.Unlock()
+		s.detach(time.Duration(cfg.GraceSeconds) * time.Second)
+		return
+	}
+
+	userID := s.GetUserID()
+	if userID != 0 && m.byUser[userID] == s {
+		delete(m.byUser, userID)
+	}
+
+	m.mutex.Unlock()
+
+	removeResumeToken(s)
+	s.leaveAllGroups()
+}
+
+// GetSession 获取连接对应的会话, 连接已断开时返回nil.
+func GetSession(conn transport.Conn) *ClientSession {
+	return GetSessionByConnID(conn.GetConnID())
+}
+
+func GetSessionByConnID(connID uint64) *ClientSession {
+	_sessionMgr.mutex.RLock()
+	defer _sessionMgr.mutex.RUnlock()
+
+	return _sessionMgr.byConn[connID]
+}
+
+// GetSessionByUserID 获取用户当前绑定的会话, 未登录时返回nil.
+func GetSessionByUserID(userID uint64) *ClientSession {
+	_sessionMgr.mutex.RLock()
+	defer _sessionMgr.mutex.RUnlock()
+
+	return _sessionMgr.byUser[userID]
+}
+
+func GetSessionCount() int {
+	_sessionMgr.mutex.RLock()
+	defer _sessionMgr.mutex.RUnlock()
+
+	return len(_sessionMgr.byConn)
+}
+
+// BindUser 登录成功后绑定用户, 会话进入已认证状态.
+// 用户已绑定在其他会话上时, 旧会话被解绑并返回, 由调用方决定是否踢下线.
+func BindUser(s *ClientSession, userID uint64, roleID uint64) *ClientSession {
+	_sessionMgr.mutex.Lock()
+	defer _sessionMgr.mutex.Unlock()
+
+	old := _sessionMgr.byUser[userID]
+	if old == s {
+		old = nil
+	}
+
+	if old != nil {
+		old.unbind()
+		removeResumeToken(old)
+	}
+
+	if prevUserID := s.GetUserID(); prevUserID != 0 && prevUserID != userID {
+		delete(_sessionMgr.byUser, prevUserID)
+	}
+
+	s.mutex.Lock()
+	s.state = SESSION_STATE_AUTHED
+	s.userID = userID
+	s.roleID = roleID
+	s.loginTime = time.Now()
+	s.mutex.Unlock()
+
+	_sessionMgr.byUser[userID] = s
+
+	return old
+}
+
+// UnbindUser 登出时解绑用户, 会话回到未认证状态但连接保持.
+func UnbindUser(s *ClientSession) {
+	_sessionMgr.mutex.Lock()
+	defer _sessionMgr.mutex.Unlock()
+
+	userID := s.GetUserID()
+	if userID != 0 && _sessionMgr.byUser[userID] == s {
+		delete(_sessionMgr.byUser, userID)
+	}
+
+	s.unbind()
+	removeResumeToken(s)
+}
+
+func (s *ClientSession) unbind() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state = SESSION_STATE_CONNECTED
+	s.userID = 0
+	s.roleID = 0
 }
@@ -0,0 +1,91 @@
+package msg
+
+import (
+	"net"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+)
+
+type fakeConn struct {
+	id uint64
+}
+
+func (c *fakeConn) GetConnID() uint64              { return c.id }
+func (c *fakeConn) GetLocalAddr() (addr net.Addr)  { return &net.TCPAddr{} }
+func (c *fakeConn) GetRemoteAddr() (addr net.Addr) { return &net.TCPAddr{} }
+func (c *fakeConn) Send(data []byte) error         { return nil }
+func (c *fakeConn) Read(targetBuff []byte) (int, error) {
+	return 0, nil
+}
+func (c *fakeConn) Close(active bool) error { return nil }
+
+func TestSessionBind(t *testing.T) {
+	c1 := &fakeConn{id: 1001}
+	c2 := &fakeConn{id: 1002}
+
+	OnClientConnOpened(c1)
+	OnClientConnOpened(c2)
+
+	s1 := GetSession(c1)
+	s2 := GetSessionByConnID(c2.GetConnID())
+	if s1 == nil || s2 == nil {
+		t.Fatalf("fail: session not created")
+	}
+
+	if BindUser(s1, 10, 100) != nil || !s1.IsAuthed() || GetSessionByUserID(10) != s1 {
+		t.Errorf("fail: bind s1")
+	}
+
+	// 同一用户在新连接登录, 旧会话被解绑
+	old := BindUser(s2, 10, 101)
+	if old != s1 || s1.IsAuthed() || GetSessionByUserID(10) != s2 {
+		t.Errorf("fail: rebind s2")
+	}
+
+	OnClientConnClosed(c1, false)
+	if GetSession(c1) != nil || GetSessionByUserID(10) != s2 {
+		t.Errorf("fail: close c1")
+	}
+
+	OnClientConnClosed(c2, false)
+	if GetSessionByUserID(10) != nil {
+		t.Errorf("fail: close c2")
+	}
+}
+
+func TestAuthClientMsgHandler(t *testing.T) {
+	msgid := int32(csproto.CSMessageID_cs_login)
+	called := 0
+
+	err := RegisterAuthClientMsgHandler(msgid, func(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+		called++
+	})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	defer func() {
+		delete(msgHandlerMgr.clientMsgHandler, msgid)
+		delete(_middlewareMgr.msg, msgid)
+		_middlewareMgr.reset(msgid)
+	}()
+
+	conn := &fakeConn{id: 2001}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	data := buildCSData(t, &csproto.CSHead{Msgid: msgid, Seqid: 7}, &csproto.CS_LOGIN{Name: "a"})
+
+	RecvClientMsg(conn, data)
+	if called != 0 {
+		t.Errorf("fail: unauthed session called handler")
+	}
+
+	BindUser(GetSession(conn), 20, 0)
+	RecvClientMsg(conn, data)
+	if called != 1 || GetSession(conn).GetLastSeqid() != 7 {
+		t.Errorf("fail: called %d seqid %d", called, GetSession(conn).GetLastSeqid())
+	}
+}
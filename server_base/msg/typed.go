@@ -0,0 +1,166 @@
+package msg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/nearmeng/mango-go/plugin/log"
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrHandlerSignature = errors.New("msg: invalid typed handler signature")
+
+	_connType    = reflect.TypeOf((*transport.Conn)(nil)).Elem()
+	_csHeadType  = reflect.TypeOf((*csproto.CSHead)(nil))
+	_messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	_errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// typedHandler 注册时解析出的处理函数信息.
+type typedHandler struct {
+	fn         reflect.Value
+	withHeader bool
+	reqInfo    *MsgInfo
+	rspInfo    *MsgInfo
+	withErr    bool
+}
+
+// RegisterTypedHandler 注册参数为具体请求类型的处理函数, 消息号由注册表中请求类型对应的消息号确定.
+// 支持的函数签名, Req/Rsp为proto消息的指针类型:
+//
+//	func(conn transport.Conn, req *Req)
+//	func(conn transport.Conn, header *csproto.CSHead, req *Req)
+//	func(conn transport.Conn, req *Req) *Rsp
+//	func(conn transport.Conn, req *Req) (*Rsp, error)
+//
+// 返回的回包不为nil时由框架填充SCHead后发送, 回包消息号取自注册表, seqid与请求一致.
+// 返回error时以其错误码(见ResultError, 其他错误为RESULT_ERR_INTERNAL)回复不带消息体的错误包.
+func RegisterTypedHandler(handler interface{}) error {
+	th, err := parseTypedHandler(handler)
+	if err != nil {
+		return err
+	}
+
+	return RegisterClientMsgHandler(th.reqInfo.Msgid, th.handle)
+}
+
+// RegisterAuthTypedHandler 同RegisterTypedHandler, 只允许已认证会话调用.
+func RegisterAuthTypedHandler(handler interface{}) error {
+	th, err := parseTypedHandler(handler)
+	if err != nil {
+		return err
+	}
+
+	return RegisterAuthClientMsgHandler(th.reqInfo.Msgid, th.handle)
+}
+
+func parseTypedHandler(handler interface{}) (*typedHandler, error) {
+	fn := reflect.ValueOf(handler)
+	ft := fn.Type()
+
+	if ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%w: %s is not func", ErrHandlerSignature, ft)
+	}
+
+	th := &typedHandler{fn: fn}
+
+	if ft.NumIn() < 2 || ft.NumIn() > 3 || ft.In(0) != _connType {
+		return nil, fmt.Errorf("%w: %s first param must be transport.Conn", ErrHandlerSignature, ft)
+	}
+
+	if ft.NumIn() == 3 {
+		if ft.In(1) != _csHeadType {
+			return nil, fmt.Errorf("%w: %s second param must be *csproto.CSHead", ErrHandlerSignature, ft)
+		}
+		th.withHeader = true
+	}
+
+	reqType := ft.In(ft.NumIn() - 1)
+	reqInfo, err := getTypedMsgInfo(MSG_DIRECTION_CS, reqType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s request %v", ErrHandlerSignature, ft, err)
+	}
+	th.reqInfo = reqInfo
+
+	switch ft.NumOut() {
+	case 0:
+		return th, nil
+	case 2:
+		if ft.Out(1) != _errorType {
+			return nil, fmt.Errorf("%w: %s second result must be error", ErrHandlerSignature, ft)
+		}
+		th.withErr = true
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: %s too many results", ErrHandlerSignature, ft)
+	}
+
+	rspInfo, err := getTypedMsgInfo(MSG_DIRECTION_SC, ft.Out(0))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s response %v", ErrHandlerSignature, ft, err)
+	}
+	th.rspInfo = rspInfo
+
+	return th, nil
+}
+
+// getTypedMsgInfo 类型必须是proto消息的指针并且已在注册表中声明.
+func getTypedMsgInfo(dir int32, t reflect.Type) (*MsgInfo, error) {
+	if t.Kind() != reflect.Ptr || !t.Implements(_messageType) {
+		return nil, fmt.Errorf("%s is not proto message", t)
+	}
+
+	msg := reflect.Zero(t).Interface().(proto.Message)
+	info := GetMsgInfoByMessage(dir, msg)
+	if info == nil {
+		return nil, fmt.Errorf("%s is not register in direction %d", t, dir)
+	}
+
+	return info, nil
+}
+
+func (th *typedHandler) handle(conn transport.Conn, header *csproto.CSHead, msg proto.Message) {
+	args := make([]reflect.Value, 0, 3)
+	args = append(args, reflect.ValueOf(conn))
+	if th.withHeader {
+		args = append(args, reflect.ValueOf(header))
+	}
+	args = append(args, reflect.ValueOf(msg))
+
+	results := th.fn.Call(args)
+	if th.rspInfo == nil {
+		return
+	}
+
+	if th.withErr && !results[1].IsNil() {
+		err := results[1].Interface().(error)
+		log.Error("conn %v msgid %d handler return err %v", conn.GetConnID(), header.GetMsgid(), err)
+
+		var re *ResultError
+		errmsg := ""
+		if errors.As(err, &re) {
+			errmsg = re.Msg
+		}
+
+		err = SendErrorToClient(conn, th.rspInfo.Msgid, header.GetSeqid(), GetResultCode(err), errmsg)
+		if err != nil {
+			log.Error("conn %v msgid %d reply error failed, err %v", conn.GetConnID(), header.GetMsgid(), err)
+		}
+		return
+	}
+
+	if results[0].IsNil() {
+		return
+	}
+
+	rspHeader := &csproto.SCHead{
+		Msgid: th.rspInfo.Msgid,
+		Seqid: header.GetSeqid(),
+	}
+
+	_ = SendToClient(conn, rspHeader, results[0].Interface().(proto.Message))
+}
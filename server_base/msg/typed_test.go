@@ -0,0 +1,70 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nearmeng/mango-go/plugin/transport"
+	"github.com/nearmeng/mango-go/proto/csproto"
+)
+
+func TestParseTypedHandler(t *testing.T) {
+	valid := []interface{}{
+		func(conn transport.Conn, req *csproto.CS_LOGIN) {},
+		func(conn transport.Conn, header *csproto.CSHead, req *csproto.CS_LOGIN) {},
+		func(conn transport.Conn, req *csproto.CS_LOGIN) *csproto.SC_LOGIN { return nil },
+		func(conn transport.Conn, req *csproto.CS_LOGIN) (*csproto.SC_LOGIN, error) { return nil, nil },
+	}
+
+	for i, h := range valid {
+		th, err := parseTypedHandler(h)
+		if err != nil || th.reqInfo.Msgid != int32(csproto.CSMessageID_cs_login) {
+			t.Errorf("fail: valid handler %d err %v", i, err)
+		}
+	}
+
+	invalid := []interface{}{
+		1,
+		func(req *csproto.CS_LOGIN) {},
+		func(conn transport.Conn, req *csproto.Account) {},
+		func(conn transport.Conn, req *csproto.CS_LOGIN) *csproto.CS_LOGIN { return nil },
+		func(conn transport.Conn, req *csproto.CS_LOGIN) (*csproto.SC_LOGIN, int) { return nil, 0 },
+	}
+
+	for i, h := range invalid {
+		_, err := parseTypedHandler(h)
+		if !errors.Is(err, ErrHandlerSignature) {
+			t.Errorf("fail: invalid handler %d err %v", i, err)
+		}
+	}
+}
+
+func TestTypedHandlerResponse(t *testing.T) {
+	msgid := int32(csproto.CSMessageID_cs_login)
+
+	err := RegisterTypedHandler(func(conn transport.Conn, req *csproto.CS_LOGIN) *csproto.SC_LOGIN {
+		return &csproto.SC_LOGIN{Success: int32(len(req.GetName()))}
+	})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	defer func() {
+		delete(msgHandlerMgr.clientMsgHandler, msgid)
+		_middlewareMgr.reset(msgid)
+	}()
+
+	conn := &recordConn{fakeConn: fakeConn{id: 5001}}
+	OnClientConnOpened(conn)
+	defer OnClientConnClosed(conn, false)
+
+	RecvClientMsg(conn, buildCSData(t, &csproto.CSHead{Msgid: msgid, Seqid: 9}, &csproto.CS_LOGIN{Name: "abc"}))
+
+	if len(conn.sent) != 1 {
+		t.Fatalf("fail: sent %d", len(conn.sent))
+	}
+
+	header, body, err := decodeSC(conn.sent[0])
+	if err != nil || header.GetMsgid() != msgid || header.GetSeqid() != 9 || body.(*csproto.SC_LOGIN).GetSuccess() != 3 {
+		t.Errorf("fail: header %v body %v err %v", header, body, err)
+	}
+}
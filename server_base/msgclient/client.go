@@ -0,0 +1,178 @@
+package msgclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"github.com/nearmeng/mango-go/server_base/msg"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	_frameHeadSize  = 8
+	_defaultTimeout = 5 * time.Second
+	_maxFrameSize   = 4 * 1024 * 1024
+)
+
+var (
+	ErrFrameTooLarge = errors.New("msgclient: frame too large")
+)
+
+// packet 已收到但还没有被取走的服务器推送.
+type packet struct {
+	header *csproto.SCHead
+	body   []byte
+}
+
+// Client 用于测试和机器人的Go客户端, 同步收发, 不支持压缩和加密.
+// 等待回包期间收到的推送被缓存, 由Recv取走, 不能在多个goroutine中同时使用.
+type Client struct {
+	conn    net.Conn
+	seqid   int32
+	timeout time.Duration
+	pushes  []*packet
+}
+
+func NewClient(conn net.Conn) *Client {
+	return &Client{
+		conn:    conn,
+		timeout: _defaultTimeout,
+	}
+}
+
+func Dial(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, _defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(conn), nil
+}
+
+// SetTimeout 设置等待回包和推送的超时时间.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Send 发送请求不等待回包, 返回请求的seqid.
+func (c *Client) Send(msgid int32, req proto.Message) (int32, error) {
+	header, err := proto.Marshal(&csproto.CSHead{Msgid: msgid, Seqid: c.seqid + 1})
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+
+	frame := make([]byte, _frameHeadSize, _frameHeadSize+len(header)+len(body))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(header)))
+	binary.LittleEndian.PutUint32(frame[4:8], uint32(len(body)))
+	frame = append(frame, header...)
+	frame = append(frame, body...)
+
+	_, err = c.conn.Write(frame)
+	if err != nil {
+		return 0, err
+	}
+
+	c.seqid++
+	return c.seqid, nil
+}
+
+// Call 发送请求并等待seqid相同的回包, 回包带错误码时返回*msg.ResultError.
+func (c *Client) Call(msgid int32, req proto.Message, rsp proto.Message) error {
+	seqid, err := c.Send(msgid, req)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	for {
+		p, err := c.read(deadline)
+		if err != nil {
+			return err
+		}
+
+		if p.header.GetSeqid() != seqid || p.header.GetMsgid() != msgid {
+			c.pushes = append(c.pushes, p)
+			continue
+		}
+
+		return p.unmarshal(rsp)
+	}
+}
+
+// Recv 等待消息号为msgid的推送, 先从已缓存的推送中查找.
+func (c *Client) Recv(msgid int32, m proto.Message) error {
+	for i, p := range c.pushes {
+		if p.header.GetMsgid() == msgid {
+			c.pushes = append(c.pushes[:i], c.pushes[i+1:]...)
+			return p.unmarshal(m)
+		}
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	for {
+		p, err := c.read(deadline)
+		if err != nil {
+			return err
+		}
+
+		if p.header.GetMsgid() != msgid {
+			c.pushes = append(c.pushes, p)
+			continue
+		}
+
+		return p.unmarshal(m)
+	}
+}
+
+func (c *Client) read(deadline time.Time) (*packet, error) {
+	err := c.conn.SetReadDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, _frameHeadSize)
+	if _, err = io.ReadFull(c.conn, head); err != nil {
+		return nil, err
+	}
+
+	headerSize := binary.LittleEndian.Uint32(head[0:4])
+	bodySize := binary.LittleEndian.Uint32(head[4:8])
+	if uint64(headerSize)+uint64(bodySize) > _maxFrameSize {
+		return nil, fmt.Errorf("%w: header size %d body size %d", ErrFrameTooLarge, headerSize, bodySize)
+	}
+
+	data := make([]byte, headerSize+bodySize)
+	if _, err = io.ReadFull(c.conn, data); err != nil {
+		return nil, err
+	}
+
+	header := &csproto.SCHead{}
+	err = proto.Unmarshal(data[:headerSize], header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packet{header: header, body: data[headerSize:]}, nil
+}
+
+func (p *packet) unmarshal(m proto.Message) error {
+	if p.header.GetResult() != msg.RESULT_OK {
+		return msg.NewResultError(p.header.GetResult(), p.header.GetErrmsg())
+	}
+
+	return proto.Unmarshal(p.body, m)
+}
@@ -0,0 +1,81 @@
+package msgclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"github.com/nearmeng/mango-go/server_base/msg"
+	"google.golang.org/protobuf/proto"
+)
+
+func writeSC(conn net.Conn, header *csproto.SCHead, body proto.Message) {
+	headerData, _ := proto.Marshal(header)
+	bodyData, _ := proto.Marshal(body)
+
+	frame := make([]byte, _frameHeadSize)
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(headerData)))
+	binary.LittleEndian.PutUint32(frame[4:8], uint32(len(bodyData)))
+	frame = append(frame, headerData...)
+	_, _ = conn.Write(append(frame, bodyData...))
+}
+
+// serve 每收到一个请求先推送一条消息号100的消息再回包, 名字为空时回复错误.
+func serve(conn net.Conn) {
+	for {
+		head := make([]byte, _frameHeadSize)
+		if _, err := io.ReadFull(conn, head); err != nil {
+			return
+		}
+
+		headerSize := binary.LittleEndian.Uint32(head[0:4])
+		data := make([]byte, headerSize+binary.LittleEndian.Uint32(head[4:8]))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+
+		var header csproto.CSHead
+		var req csproto.CS_LOGIN
+		_ = proto.Unmarshal(data[:headerSize], &header)
+		_ = proto.Unmarshal(data[headerSize:], &req)
+
+		writeSC(conn, &csproto.SCHead{Msgid: 100}, &csproto.SC_LOGIN{Success: 7})
+
+		rspHeader := &csproto.SCHead{Msgid: header.GetMsgid(), Seqid: header.GetSeqid()}
+		if req.GetName() == "" {
+			rspHeader.Result = msg.RESULT_USER_BEGIN
+		}
+		writeSC(conn, rspHeader, &csproto.SC_LOGIN{Success: int32(len(req.GetName()))})
+	}
+}
+
+func TestClient(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	go serve(serverConn)
+
+	c := NewClient(clientConn)
+	defer c.Close()
+
+	var rsp csproto.SC_LOGIN
+	err := c.Call(1, &csproto.CS_LOGIN{Name: "abc"}, &rsp)
+	if err != nil || rsp.GetSuccess() != 3 {
+		t.Errorf("fail: call rsp %v err %v", &rsp, err)
+	}
+
+	var re *msg.ResultError
+	err = c.Call(1, &csproto.CS_LOGIN{}, &rsp)
+	if !errors.As(err, &re) || re.Code != msg.RESULT_USER_BEGIN {
+		t.Errorf("fail: expect result error, err %v", err)
+	}
+
+	// 两次调用期间收到的推送被缓存
+	var push csproto.SC_LOGIN
+	for i := 0; i < 2; i++ {
+		if err = c.Recv(100, &push); err != nil || push.GetSuccess() != 7 {
+			t.Errorf("fail: recv push %v err %v", &push, err)
+		}
+	}
+}
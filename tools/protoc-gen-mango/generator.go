@@ -0,0 +1,684 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	_msgPkgPath       = "github.com/nearmeng/mango-go/server_base/msg"
+	_msgClientPkgPath = "github.com/nearmeng/mango-go/server_base/msgclient"
+	_transportPkgPath = "github.com/nearmeng/mango-go/plugin/transport"
+
+	_defaultDocName = "msgid.md"
+
+	// SourceCodeInfo路径中的字段号
+	_pathMessage       = 4
+	_pathService       = 6
+	_pathNestedMessage = 3
+	_pathMethod        = 2
+)
+
+var (
+	ErrNoGoPackage     = errors.New("no go_package option")
+	ErrNoMethodMsgid   = errors.New("method has no msgid option")
+	ErrMsgidDuplicate  = errors.New("duplicate msgid")
+	ErrMessageNotFound = errors.New("message not found")
+	ErrBadParameter    = errors.New("bad parameter")
+)
+
+type genOptions struct {
+	sourceRelative bool
+	msgidOnly      bool
+	doc            string
+}
+
+type goPackage struct {
+	path string
+	name string
+}
+
+// message proto消息对应的go类型.
+type message struct {
+	fullName string
+	goName   string
+	pkg      goPackage
+	file     string
+	desc     *descriptorpb.DescriptorProto
+	comment  string
+}
+
+// msgidEntry 一个方向上的一个消息号, 用于生成文档和检查冲突.
+type msgidEntry struct {
+	msgid   int32
+	dir     string
+	message string
+	method  string
+	auth    bool
+	comment string
+}
+
+type method struct {
+	goName  string
+	constID string
+	msgid   int32
+	auth    bool
+	input   *message
+	output  *message
+	comment string
+}
+
+type service struct {
+	goName  string
+	methods []*method
+	comment string
+}
+
+// optionMsg 带有cs_msgid/sc_msgid选项的消息.
+type optionMsg struct {
+	msg     *message
+	constID string
+	msgid   int32
+	dir     string
+}
+
+type generator struct {
+	opts     genOptions
+	messages map[string]*message
+	ordered  []*message
+	entries  []*msgidEntry
+	seen     map[string]*msgidEntry
+}
+
+func parseOptions(parameter string) (genOptions, error) {
+	opts := genOptions{doc: _defaultDocName}
+
+	for _, param := range strings.Split(parameter, ",") {
+		if param == "" {
+			continue
+		}
+
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return opts, fmt.Errorf("%w: %s", ErrBadParameter, param)
+		}
+
+		switch kv[0] {
+		case "paths":
+			opts.sourceRelative = kv[1] == "source_relative"
+		case "msgid_only":
+			v, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return opts, fmt.Errorf("%w: %s", ErrBadParameter, param)
+			}
+			opts.msgidOnly = v
+		case "doc":
+			opts.doc = kv[1]
+		default:
+			return opts, fmt.Errorf("%w: %s", ErrBadParameter, param)
+		}
+	}
+
+	return opts, nil
+}
+
+func generate(req *request) ([]*outputFile, error) {
+	opts, err := parseOptions(req.parameter)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		opts:     opts,
+		messages: make(map[string]*message),
+		seen:     make(map[string]*msgidEntry),
+	}
+
+	byName := make(map[string]*descriptorpb.FileDescriptorProto, len(req.protoFiles))
+	for _, fd := range req.protoFiles {
+		byName[fd.GetName()] = fd
+		g.indexFile(fd)
+	}
+
+	var files []*outputFile
+	for _, name := range req.fileToGenerate {
+		fd, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("file %s not found in request", name)
+		}
+
+		f, err := g.genFile(fd)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+
+	if g.opts.doc != "" && len(g.entries) > 0 {
+		files = append(files, &outputFile{name: g.opts.doc, content: g.genDoc()})
+	}
+
+	return files, nil
+}
+
+func getGoPackage(fd *descriptorpb.FileDescriptorProto) (goPackage, bool) {
+	opt := fd.GetOptions().GetGoPackage()
+	if opt == "" {
+		return goPackage{}, false
+	}
+
+	pkg := goPackage{path: opt}
+	if i := strings.Index(opt, ";"); i >= 0 {
+		pkg.path, pkg.name = opt[:i], opt[i+1:]
+	}
+
+	if pkg.name == "" {
+		pkg.name = path.Base(pkg.path)
+	}
+	pkg.name = strings.NewReplacer("-", "_", ".", "_").Replace(pkg.name)
+
+	return pkg, true
+}
+
+func getComments(fd *descriptorpb.FileDescriptorProto) map[string]string {
+	comments := make(map[string]string)
+
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		c := strings.TrimSpace(loc.GetLeadingComments())
+		if c == "" {
+			continue
+		}
+		comments[pathKey(loc.GetPath())] = c
+	}
+
+	return comments
+}
+
+func pathKey(p []int32) string {
+	parts := make([]string, len(p))
+	for i, v := range p {
+		parts[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(parts, ".")
+}
+
+// indexFile 记录文件中的所有消息, 嵌套消息的go类型名以下划线连接.
+func (g *generator) indexFile(fd *descriptorpb.FileDescriptorProto) {
+	pkg, _ := getGoPackage(fd)
+	comments := getComments(fd)
+
+	prefix := "."
+	if fd.GetPackage() != "" {
+		prefix += fd.GetPackage() + "."
+	}
+
+	var walk func(msgs []*descriptorpb.DescriptorProto, fullPrefix string, goPrefix string, p []int32)
+	walk = func(msgs []*descriptorpb.DescriptorProto, fullPrefix string, goPrefix string, p []int32) {
+		for i, m := range msgs {
+			mp := append(append([]int32(nil), p...), int32(i))
+			msg := &message{
+				fullName: fullPrefix + m.GetName(),
+				goName:   goPrefix + goCamelCase(m.GetName()),
+				pkg:      pkg,
+				file:     fd.GetName(),
+				desc:     m,
+				comment:  comments[pathKey(mp)],
+			}
+			g.messages[msg.fullName] = msg
+			g.ordered = append(g.ordered, msg)
+
+			walk(m.GetNestedType(), msg.fullName+".", msg.goName+"_", append(mp, _pathNestedMessage))
+		}
+	}
+
+	walk(fd.GetMessageType(), prefix, "", []int32{_pathMessage})
+}
+
+// addEntry 同一方向上的消息号只能对应一个消息, 相同的对应关系可以重复出现.
+func (g *generator) addEntry(e *msgidEntry) error {
+	key := e.dir + ":" + strconv.Itoa(int(e.msgid))
+
+	if old, ok := g.seen[key]; ok {
+		if old.message == e.message {
+			return nil
+		}
+		return fmt.Errorf("%w: %s msgid %d used by %s and %s", ErrMsgidDuplicate, e.dir, e.msgid, old.message, e.message)
+	}
+
+	g.seen[key] = e
+	g.entries = append(g.entries, e)
+
+	return nil
+}
+
+func (g *generator) getMessage(name string) (*message, error) {
+	m, ok := g.messages[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, name)
+	}
+	return m, nil
+}
+
+func (g *generator) collectServices(fd *descriptorpb.FileDescriptorProto, comments map[string]string) ([]*service, error) {
+	var services []*service
+
+	for si, sd := range fd.GetService() {
+		svc := &service{
+			goName:  goCamelCase(sd.GetName()),
+			comment: comments[pathKey([]int32{_pathService, int32(si)})],
+		}
+
+		for mi, md := range sd.GetMethod() {
+			opts := md.GetOptions()
+			if opts == nil || !proto.HasExtension(opts, csproto.E_Msgid) {
+				return nil, fmt.Errorf("%w: %s.%s", ErrNoMethodMsgid, sd.GetName(), md.GetName())
+			}
+
+			input, err := g.getMessage(md.GetInputType())
+			if err != nil {
+				return nil, err
+			}
+
+			output, err := g.getMessage(md.GetOutputType())
+			if err != nil {
+				return nil, err
+			}
+
+			m := &method{
+				goName:  goCamelCase(md.GetName()),
+				constID: "MSGID_" + upperSnake(sd.GetName()) + "_" + upperSnake(md.GetName()),
+				msgid:   proto.GetExtension(opts, csproto.E_Msgid).(int32),
+				auth:    proto.GetExtension(opts, csproto.E_Auth).(bool),
+				input:   input,
+				output:  output,
+				comment: comments[pathKey([]int32{_pathService, int32(si), _pathMethod, int32(mi)})],
+			}
+			svc.methods = append(svc.methods, m)
+
+			name := sd.GetName() + "." + md.GetName()
+			err = g.addEntry(&msgidEntry{msgid: m.msgid, dir: "CS", message: input.fullName[1:], method: name, auth: m.auth, comment: m.comment})
+			if err != nil {
+				return nil, err
+			}
+
+			err = g.addEntry(&msgidEntry{msgid: m.msgid, dir: "SC", message: output.fullName[1:], method: name, comment: m.comment})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		services = append(services, svc)
+	}
+
+	return services, nil
+}
+
+// collectOptionMsgs 按消息在文件中定义的顺序收集带消息号选项的消息.
+func (g *generator) collectOptionMsgs(fd *descriptorpb.FileDescriptorProto) ([]*optionMsg, error) {
+	var result []*optionMsg
+
+	for _, m := range g.ordered {
+		opts := m.desc.GetOptions()
+		if m.file != fd.GetName() || opts == nil {
+			continue
+		}
+
+		for _, xt := range []protoreflect.ExtensionType{csproto.E_CsMsgid, csproto.E_ScMsgid} {
+			if !proto.HasExtension(opts, xt) {
+				continue
+			}
+
+			dir := "CS"
+			if xt == csproto.E_ScMsgid {
+				dir = "SC"
+			}
+
+			om := &optionMsg{
+				msg:     m,
+				constID: "MSGID_" + upperSnake(m.goName),
+				msgid:   proto.GetExtension(opts, xt).(int32),
+				dir:     dir,
+			}
+			result = append(result, om)
+
+			err := g.addEntry(&msgidEntry{msgid: om.msgid, dir: dir, message: m.fullName[1:], comment: m.comment})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (g *generator) outputName(fd *descriptorpb.FileDescriptorProto, pkg goPackage) string {
+	name := strings.TrimSuffix(fd.GetName(), ".proto") + ".mango.go"
+	if g.opts.sourceRelative {
+		return name
+	}
+
+	return path.Join(strings.TrimPrefix(pkg.path, "/"), path.Base(name))
+}
+
+func (g *generator) genFile(fd *descriptorpb.FileDescriptorProto) (*outputFile, error) {
+	pkg, ok := getGoPackage(fd)
+	if !ok {
+		return nil, ErrNoGoPackage
+	}
+
+	services, err := g.collectServices(fd, getComments(fd))
+	if err != nil {
+		return nil, err
+	}
+
+	optMsgs, err := g.collectOptionMsgs(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(services) == 0 && len(optMsgs) == 0 {
+		return nil, nil
+	}
+
+	w := newFileWriter(pkg)
+	w.genMsgids(services, optMsgs)
+
+	if !g.opts.msgidOnly {
+		for _, svc := range services {
+			w.genServer(svc)
+			w.genClient(svc)
+		}
+		w.genOptionHelpers(optMsgs)
+	}
+
+	src, err := w.finish(fd.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return &outputFile{name: g.outputName(fd, pkg), content: src}, nil
+}
+
+func (g *generator) genDoc() string {
+	entries := append([]*msgidEntry(nil), g.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].msgid != entries[j].msgid {
+			return entries[i].msgid < entries[j].msgid
+		}
+		return entries[i].dir < entries[j].dir
+	})
+
+	var b strings.Builder
+	b.WriteString("<!-- Code generated by protoc-gen-mango. DO NOT EDIT. -->\n\n")
+	b.WriteString("| 消息号 | 方向 | 消息 | 接口 | 认证 | 说明 |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+
+	for _, e := range entries {
+		auth := ""
+		if e.auth {
+			auth = "是"
+		}
+		comment := strings.Replace(e.comment, "\n", " ", -1)
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s | %s |\n", e.msgid, e.dir, e.message, e.method, auth, comment)
+	}
+
+	return b.String()
+}
+
+// fileWriter 生成单个go文件, 引用其他go包的消息时自动添加import.
+type fileWriter struct {
+	pkg     goPackage
+	imports map[string]string
+	body    strings.Builder
+}
+
+func newFileWriter(pkg goPackage) *fileWriter {
+	return &fileWriter{
+		pkg:     pkg,
+		imports: make(map[string]string),
+	}
+}
+
+func (w *fileWriter) p(format string, v ...interface{}) {
+	fmt.Fprintf(&w.body, format, v...)
+	w.body.WriteByte('\n')
+}
+
+func (w *fileWriter) use(pkgPath string, name string) string {
+	w.imports[pkgPath] = name
+	return name
+}
+
+func (w *fileWriter) typeName(m *message) string {
+	if m.pkg.path == w.pkg.path {
+		return m.goName
+	}
+	return w.use(m.pkg.path, m.pkg.name) + "." + m.goName
+}
+
+// comment 注释以name开头, proto中没有注释时使用fallback.
+func (w *fileWriter) comment(name string, c string, fallback string) {
+	if c == "" {
+		c = fallback
+	}
+	if c == "" {
+		return
+	}
+
+	for i, line := range strings.Split(c, "\n") {
+		if i == 0 {
+			line = name + " " + line
+		}
+		w.p("// %s", strings.TrimSpace(line))
+	}
+}
+
+func (w *fileWriter) genMsgids(services []*service, optMsgs []*optionMsg) {
+	w.p("// msgid.")
+	w.p("const (")
+	for _, svc := range services {
+		for _, m := range svc.methods {
+			w.p("%s = %d", m.constID, m.msgid)
+		}
+	}
+	for _, om := range optMsgs {
+		w.p("%s = %d", om.constID, om.msgid)
+	}
+	w.p(")")
+	w.p("")
+}
+
+func (w *fileWriter) genServer(svc *service) {
+	msgPkg := w.use(_msgPkgPath, "msg")
+	transportPkg := w.use(_transportPkgPath, "transport")
+
+	w.comment(svc.goName+"Server", svc.comment, svc.goName+"服务的处理接口.")
+	w.p("type %sServer interface {", svc.goName)
+	for _, m := range svc.methods {
+		w.comment(m.goName, m.comment, "")
+		w.p("%s(conn %s.Conn, req *%s) (*%s, error)", m.goName, transportPkg, w.typeName(m.input), w.typeName(m.output))
+	}
+	w.p("}")
+	w.p("")
+
+	w.p("// Register%sServer 注册%s服务的消息号和处理函数.", svc.goName, svc.goName)
+	w.p("func Register%sServer(srv %sServer) error {", svc.goName, svc.goName)
+	for _, m := range svc.methods {
+		register := "RegisterTypedHandler"
+		if m.auth {
+			register = "RegisterAuthTypedHandler"
+		}
+
+		w.p("if err := %s.RegisterMsg(%s, &%s{}, &%s{}); err != nil {", msgPkg, m.constID, w.typeName(m.input), w.typeName(m.output))
+		w.p("return err")
+		w.p("}")
+		w.p("if err := %s.%s(srv.%s); err != nil {", msgPkg, register, m.goName)
+		w.p("return err")
+		w.p("}")
+	}
+	w.p("return nil")
+	w.p("}")
+	w.p("")
+}
+
+func (w *fileWriter) genClient(svc *service) {
+	clientPkg := w.use(_msgClientPkgPath, "msgclient")
+
+	w.p("// %sClient %s服务的测试客户端.", svc.goName, svc.goName)
+	w.p("type %sClient struct {", svc.goName)
+	w.p("c *%s.Client", clientPkg)
+	w.p("}")
+	w.p("")
+
+	w.p("func New%sClient(c *%s.Client) *%sClient {", svc.goName, clientPkg, svc.goName)
+	w.p("return &%sClient{c: c}", svc.goName)
+	w.p("}")
+	w.p("")
+
+	for _, m := range svc.methods {
+		w.comment(m.goName, m.comment, "")
+		w.p("func (x *%sClient) %s(req *%s) (*%s, error) {", svc.goName, m.goName, w.typeName(m.input), w.typeName(m.output))
+		w.p("rsp := &%s{}", w.typeName(m.output))
+		w.p("if err := x.c.Call(%s, req, rsp); err != nil {", m.constID)
+		w.p("return nil, err")
+		w.p("}")
+		w.p("return rsp, nil")
+		w.p("}")
+		w.p("")
+	}
+}
+
+// genOptionHelpers 带cs_msgid的消息生成发送函数, 带sc_msgid的消息生成等待推送的函数.
+func (w *fileWriter) genOptionHelpers(optMsgs []*optionMsg) {
+	for _, om := range optMsgs {
+		clientPkg := w.use(_msgClientPkgPath, "msgclient")
+		name := w.typeName(om.msg)
+
+		if om.dir == "CS" {
+			w.p("// Send%s 发送%s, 不等待回包.", om.msg.goName, om.msg.goName)
+			w.p("func Send%s(c *%s.Client, req *%s) error {", om.msg.goName, clientPkg, name)
+			w.p("_, err := c.Send(%s, req)", om.constID)
+			w.p("return err")
+			w.p("}")
+			w.p("")
+			continue
+		}
+
+		w.p("// Recv%s 等待服务器下发的%s.", om.msg.goName, om.msg.goName)
+		w.p("func Recv%s(c *%s.Client) (*%s, error) {", om.msg.goName, clientPkg, name)
+		w.p("m := &%s{}", name)
+		w.p("if err := c.Recv(%s, m); err != nil {", om.constID)
+		w.p("return nil, err")
+		w.p("}")
+		w.p("return m, nil")
+		w.p("}")
+		w.p("")
+	}
+}
+
+func (w *fileWriter) finish(source string) (string, error) {
+	var b strings.Builder
+	b.WriteString("// Code generated by protoc-gen-mango. DO NOT EDIT.\n")
+	fmt.Fprintf(&b, "// source: %s\n\n", source)
+	fmt.Fprintf(&b, "package %s\n\n", w.pkg.name)
+
+	if len(w.imports) > 0 {
+		paths := make([]string, 0, len(w.imports))
+		for p := range w.imports {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		b.WriteString("import (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&b, "%s %q\n", w.imports[p], p)
+		}
+		b.WriteString(")\n\n")
+	}
+
+	b.WriteString(w.body.String())
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", fmt.Errorf("format generated code failed, %v", err)
+	}
+
+	return string(src), nil
+}
+
+// goCamelCase 与protoc-gen-go的命名规则一致.
+func goCamelCase(s string) string {
+	var b []byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isASCIILower(s[i+1]):
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+
+	return string(b)
+}
+
+// upperSnake GetUserInfo转为GET_USER_INFO, 已有的下划线保留.
+func upperSnake(s string) string {
+	var b []byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isASCIIUpper(c) && i > 0 && s[i-1] != '_' {
+			prev := s[i-1]
+			nextLower := i+1 < len(s) && isASCIILower(s[i+1])
+			if isASCIILower(prev) || isASCIIDigit(prev) || (isASCIIUpper(prev) && nextLower) {
+				b = append(b, '_')
+			}
+		}
+
+		if isASCIILower(c) {
+			c -= 'a' - 'A'
+		}
+		b = append(b, c)
+	}
+
+	return string(b)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIUpper(c byte) bool {
+	return 'A' <= c && c <= 'Z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
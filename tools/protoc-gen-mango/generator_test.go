@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nearmeng/mango-go/proto/csproto"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func buildTestFile(loginMsgid int32, kickMsgid int32) *descriptorpb.FileDescriptorProto {
+	methodOpts := &descriptorpb.MethodOptions{}
+	proto.SetExtension(methodOpts, csproto.E_Msgid, loginMsgid)
+	proto.SetExtension(methodOpts, csproto.E_Auth, true)
+
+	kickOpts := &descriptorpb.MessageOptions{}
+	proto.SetExtension(kickOpts, csproto.E_ScMsgid, kickMsgid)
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("game/login.proto"),
+		Package: proto.String("game"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/test/game")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("LoginReq")},
+			{Name: proto.String("LoginRsp")},
+			{Name: proto.String("SC_KICK"), Options: kickOpts},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Account"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("LoginGame"),
+				InputType:  proto.String(".game.LoginReq"),
+				OutputType: proto.String(".game.LoginRsp"),
+				Options:    methodOpts,
+			}},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{
+				{Path: []int32{_pathService, 0, _pathMethod, 0}, LeadingComments: proto.String(" 登录游戏\n")},
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	files, err := generate(&request{
+		fileToGenerate: []string{"game/login.proto"},
+		protoFiles:     []*descriptorpb.FileDescriptorProto{buildTestFile(10, 11)},
+	})
+	if err != nil || len(files) != 2 {
+		t.Fatalf("fail: files %d err %v", len(files), err)
+	}
+
+	if files[0].name != "github.com/test/game/login.mango.go" || files[1].name != _defaultDocName {
+		t.Errorf("fail: file names %s %s", files[0].name, files[1].name)
+	}
+
+	src := files[0].content
+	for _, s := range []string{
+		"MSGID_ACCOUNT_LOGIN_GAME = 10",
+		"MSGID_SC_KICK            = 11",
+		"LoginGame(conn transport.Conn, req *LoginReq) (*LoginRsp, error)",
+		"msg.RegisterAuthTypedHandler(srv.LoginGame)",
+		"func (x *AccountClient) LoginGame(req *LoginReq) (*LoginRsp, error)",
+		"func RecvSC_KICK(c *msgclient.Client) (*SC_KICK, error)",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("fail: generated code missing %q\n%s", s, src)
+		}
+	}
+
+	if !strings.Contains(files[1].content, "| 10 | CS | game.LoginReq | Account.LoginGame | 是 | 登录游戏 |") {
+		t.Errorf("fail: doc\n%s", files[1].content)
+	}
+}
+
+func TestGenerateMsgidDuplicate(t *testing.T) {
+	_, err := generate(&request{
+		fileToGenerate: []string{"game/login.proto"},
+		protoFiles:     []*descriptorpb.FileDescriptorProto{buildTestFile(10, 10)},
+	})
+	if !errors.Is(err, ErrMsgidDuplicate) {
+		t.Errorf("fail: expect duplicate, err %v", err)
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	fd, _ := proto.Marshal(buildTestFile(1, 2))
+
+	var data []byte
+	data = protowire.AppendTag(data, _reqFileToGenerate, protowire.BytesType)
+	data = protowire.AppendString(data, "game/login.proto")
+	data = protowire.AppendTag(data, _reqParameter, protowire.BytesType)
+	data = protowire.AppendString(data, "msgid_only=true")
+	data = protowire.AppendTag(data, _reqProtoFile, protowire.BytesType)
+	data = protowire.AppendBytes(data, fd)
+
+	req, err := parseRequest(data)
+	if err != nil || len(req.protoFiles) != 1 || req.parameter != "msgid_only=true" {
+		t.Fatalf("fail: req %v err %v", req, err)
+	}
+
+	files, err := generate(req)
+	if err != nil || strings.Contains(files[0].content, "RegisterAccountServer") {
+		t.Errorf("fail: msgid only err %v", err)
+	}
+}
+
+func TestUpperSnake(t *testing.T) {
+	cases := map[string]string{
+		"GetUserInfo": "GET_USER_INFO",
+		"CS_LOGIN":    "CS_LOGIN",
+		"HTTPServer":  "HTTP_SERVER",
+		"login2Game":  "LOGIN2_GAME",
+	}
+
+	for in, expect := range cases {
+		if out := upperSnake(in); out != expect {
+			t.Errorf("fail: %s -> %s, expect %s", in, out, expect)
+		}
+	}
+}
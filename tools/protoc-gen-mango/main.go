@@ -0,0 +1,39 @@
+// protoc-gen-mango 根据proto中的service和消息号选项生成消息号常量、服务器注册代码、
+// Go测试客户端代码和消息号文档.
+//
+//	protoc *.proto --mango_out=. --plugin=protoc-gen-mango=path/to/protoc-gen-mango
+//
+// 参数以逗号分隔:
+//
+//	paths=source_relative  输出文件与proto文件在同一目录, 默认按go_package的路径输出
+//	msgid_only=true        只生成消息号常量和文档, 用于不能依赖server_base/msg的proto包
+//	doc=msgid.md           消息号文档的文件名, 为空时不生成
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-mango: read request failed, %v\n", err)
+		os.Exit(1)
+	}
+
+	req, err := parseRequest(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-mango: parse request failed, %v\n", err)
+		os.Exit(1)
+	}
+
+	files, err := generate(req)
+
+	_, err = os.Stdout.Write(encodeResponse(files, err))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-mango: write response failed, %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// pluginpb未随vendor引入, 按google/protobuf/compiler/plugin.proto的字段号手动编解码.
+const (
+	_reqFileToGenerate = 1
+	_reqParameter      = 2
+	_reqProtoFile      = 15
+
+	_rspError             = 1
+	_rspSupportedFeatures = 2
+	_rspFile              = 15
+
+	_rspFileName    = 1
+	_rspFileContent = 15
+
+	_featureProto3Optional = 1
+)
+
+// request CodeGeneratorRequest中用到的字段.
+type request struct {
+	fileToGenerate []string
+	parameter      string
+	protoFiles     []*descriptorpb.FileDescriptorProto
+}
+
+// outputFile CodeGeneratorResponse.File.
+type outputFile struct {
+	name    string
+	content string
+}
+
+func parseRequest(data []byte) (*request, error) {
+	req := &request{}
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch num {
+		case _reqFileToGenerate:
+			req.fileToGenerate = append(req.fileToGenerate, string(v))
+		case _reqParameter:
+			req.parameter = string(v)
+		case _reqProtoFile:
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(v, fd); err != nil {
+				return nil, fmt.Errorf("unmarshal proto file failed, %v", err)
+			}
+			req.protoFiles = append(req.protoFiles, fd)
+		}
+	}
+
+	return req, nil
+}
+
+func encodeResponse(files []*outputFile, genErr error) []byte {
+	var b []byte
+
+	if genErr != nil {
+		b = protowire.AppendTag(b, _rspError, protowire.BytesType)
+		b = protowire.AppendString(b, genErr.Error())
+		return b
+	}
+
+	b = protowire.AppendTag(b, _rspSupportedFeatures, protowire.VarintType)
+	b = protowire.AppendVarint(b, _featureProto3Optional)
+
+	for _, f := range files {
+		var fb []byte
+		fb = protowire.AppendTag(fb, _rspFileName, protowire.BytesType)
+		fb = protowire.AppendString(fb, f.name)
+		fb = protowire.AppendTag(fb, _rspFileContent, protowire.BytesType)
+		fb = protowire.AppendString(fb, f.content)
+
+		b = protowire.AppendTag(b, _rspFile, protowire.BytesType)
+		b = protowire.AppendBytes(b, fb)
+	}
+
+	return b
+}